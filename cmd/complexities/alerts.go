@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"process_data/pkg/complexity"
+)
+
+// Metrics alert rules check, see [alertRule]
+const (
+	alertOnFee      = "fee"
+	alertOnGasPrice = "gas_price"
+)
+
+var alertMetrics = []string{alertOnFee, alertOnGasPrice}
+
+// Payload formats webhooks are posted with
+const (
+	webhookSlack = "slack"
+	webhookJSON  = "json"
+)
+
+var webhookFormats = []string{webhookSlack, webhookJSON}
+
+// webhookTimeout bounds each webhook call, so that a slow endpoint
+// does not hold blocks ingestion for long
+const webhookTimeout = 10 * time.Second
+
+// alertRule fires once [Metric] stays above [Above] for more than [Blocks] consecutive blocks.
+// Fees are expressed in the chosen denomination, gas prices in nAvax.
+type alertRule struct {
+	Name   string  `json:"name"   yaml:"name"`
+	Metric string  `json:"metric" yaml:"metric"`
+	Above  float64 `json:"above"  yaml:"above"`
+	Blocks int     `json:"blocks" yaml:"blocks"`
+}
+
+type webhook struct {
+	URL    string `json:"url"    yaml:"url"`
+	Format string `json:"format" yaml:"format"`
+}
+
+// alertRules is the content of an -alerts file
+type alertRules struct {
+	Rules    []alertRule `json:"rules"    yaml:"rules"`
+	Webhooks []webhook   `json:"webhooks" yaml:"webhooks"`
+}
+
+// loadAlertRules reads and validates the JSON or YAML alert rules at [path].
+// Rules with no name are named after their metric, webhooks with no format are posted as JSON.
+func loadAlertRules(path string) (*alertRules, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading alert rules %s: %w", path, err)
+	}
+	res := &alertRules{}
+	if err := unmarshalConfig(path, b, res); err != nil {
+		return nil, fmt.Errorf("failed parsing alert rules %s: %w", path, err)
+	}
+	if len(res.Rules) == 0 {
+		return nil, fmt.Errorf("no alert rules in %s", path)
+	}
+	for i := range res.Rules {
+		r := &res.Rules[i]
+		if !slices.Contains(alertMetrics, r.Metric) {
+			return nil, fmt.Errorf("unsupported alert metric %q, supported values are %v", r.Metric, alertMetrics)
+		}
+		if r.Blocks < 0 {
+			return nil, fmt.Errorf("alert rule blocks must not be negative, got %d", r.Blocks)
+		}
+		if r.Name == "" {
+			r.Name = r.Metric
+		}
+	}
+	for i := range res.Webhooks {
+		h := &res.Webhooks[i]
+		if h.Format == "" {
+			h.Format = webhookJSON
+		}
+		if !slices.Contains(webhookFormats, h.Format) {
+			return nil, fmt.Errorf("unsupported webhook format %q, supported values are %v", h.Format, webhookFormats)
+		}
+		if h.URL == "" {
+			return nil, fmt.Errorf("webhook with no url in %s", path)
+		}
+	}
+	return res, nil
+}
+
+// alertPeak is the peak of the weighted total gas an alert fires amid, if any
+type alertPeak struct {
+	StartHeight         uint64 `json:"start_height"`
+	StartTime           uint64 `json:"start_time"`
+	Blocks              int    `json:"blocks"`
+	DurationSeconds     uint64 `json:"duration_seconds"`
+	CumulatedComplexity uint64 `json:"cumulated_complexity"`
+	URL                 string `json:"url,omitempty"`
+}
+
+// alertEvent is posted as is to JSON webhooks
+type alertEvent struct {
+	Rule         string     `json:"rule"`
+	Metric       string     `json:"metric"`
+	Above        float64    `json:"above"`
+	Config       string     `json:"config"`
+	Denomination string     `json:"denomination"`
+	StartHeight  uint64     `json:"start_height"`
+	StartTime    uint64     `json:"start_time"`
+	Height       uint64     `json:"height"`
+	Time         uint64     `json:"time"`
+	Blocks       int        `json:"blocks"`
+	Value        float64    `json:"value"`
+	MaxValue     float64    `json:"max_value"`
+	GasPrice     uint64     `json:"gas_price"`
+	Fee          float64    `json:"fee"`
+	URL          string     `json:"url,omitempty"`
+	Peak         *alertPeak `json:"peak,omitempty"`
+}
+
+// breach tracks the blocks a rule has been breached over in a row
+type breach struct {
+	start    complexity.Record
+	blocks   int
+	maxValue float64
+	fired    bool
+}
+
+// alerter checks alert rules against the fee data of each block, as simulated
+// with the first fee config, and posts an alert to all webhooks once per breach
+type alerter struct {
+	rules  *alertRules
+	o      *options
+	client *http.Client
+
+	breaches []breach // indexed as rules
+}
+
+func newAlerter(o *options) *alerter {
+	return &alerter{
+		rules:    o.alerts,
+		o:        o,
+		client:   &http.Client{Timeout: webhookTimeout},
+		breaches: make([]breach, len(o.alerts.Rules)),
+	}
+}
+
+// observe checks rules against [fee], the fee data of block [r], firing rules breached
+// for long enough. [peak], if not nil, is the total gas peak [r] belongs to.
+// Blocks are expected in height order.
+func (al *alerter) observe(ctx context.Context, r complexity.Record, fee complexity.FeeData, peak *complexity.Peak) {
+	for i, rule := range al.rules.Rules {
+		value := fee.Fee
+		if rule.Metric == alertOnGasPrice {
+			value = float64(fee.GasPrice)
+		}
+		b := &al.breaches[i]
+		if value <= rule.Above {
+			*b = breach{}
+			continue
+		}
+		if b.blocks == 0 {
+			b.start = r
+		}
+		b.blocks++
+		b.maxValue = max(b.maxValue, value)
+		if b.fired || b.blocks <= rule.Blocks {
+			continue
+		}
+		b.fired = true
+
+		e := alertEvent{
+			Rule:         rule.Name,
+			Metric:       rule.Metric,
+			Above:        rule.Above,
+			Config:       al.o.feeCfgs[0].name,
+			Denomination: al.o.denom.name,
+			StartHeight:  b.start.Height,
+			StartTime:    b.start.Time,
+			Height:       r.Height,
+			Time:         r.Time,
+			Blocks:       b.blocks,
+			Value:        value,
+			MaxValue:     b.maxValue,
+			GasPrice:     uint64(fee.GasPrice),
+			Fee:          fee.Fee,
+			URL:          blockURL(al.o.explorerURL, b.start.ID),
+		}
+		if peak != nil {
+			e.Peak = &alertPeak{
+				StartHeight:         peak.StartHeight,
+				StartTime:           peak.LowTimestamp,
+				Blocks:              peak.BlocksCount,
+				DurationSeconds:     peak.ElapsedTime,
+				CumulatedComplexity: peak.CumulatedComplexity,
+				URL:                 peakURL(al.o.explorerURL, *peak),
+			}
+		}
+		al.fire(ctx, e)
+	}
+}
+
+// fire logs [e] and posts it to each webhook. Failing webhooks are
+// reported and skipped, so that monitoring carries on.
+func (al *alerter) fire(ctx context.Context, e alertEvent) {
+	slog.Warn("alert fired", "rule", e.Rule, "height", e.Height, "blocks", e.Blocks, "value", e.Value, "above", e.Above)
+	for _, h := range al.rules.Webhooks {
+		if err := al.post(ctx, h, e); err != nil {
+			slog.Error("failed posting alert", "rule", e.Rule, "url", h.URL, "err", err)
+		}
+	}
+}
+
+func (al *alerter) post(ctx context.Context, h webhook, e alertEvent) error {
+	var payload any = e
+	if h.Format == webhookSlack {
+		payload = map[string]string{"text": al.summary(e)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed marshalling alert: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(fixedNotation(body)))
+	if err != nil {
+		return fmt.Errorf("failed building request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := al.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// summary describes [e] in a line, as posted to Slack
+func (al *alerter) summary(e alertEvent) string {
+	var (
+		unit        = "nAvax"
+		above, peak = strconv.FormatFloat(e.Above, 'f', -1, 64), strconv.FormatFloat(e.MaxValue, 'f', -1, 64)
+	)
+	if e.Metric == alertOnFee {
+		unit = al.o.denom.label
+		above, peak = al.o.denom.format(e.Above), al.o.denom.format(e.MaxValue)
+	}
+	s := fmt.Sprintf("%s: %s above %s %s for %d blocks, from height %d to %d, up to %s %s (config %s)%s",
+		e.Rule, e.Metric, above, unit, e.Blocks, e.StartHeight, e.Height, peak, unit, e.Config, formatLink(e.URL))
+	if e.Peak != nil {
+		s += fmt.Sprintf("; total gas peak from height %d, %d blocks over %ds, cumulated complexity %d%s",
+			e.Peak.StartHeight, e.Peak.Blocks, e.Peak.DurationSeconds, e.Peak.CumulatedComplexity, formatLink(e.Peak.URL))
+	}
+	return s
+}
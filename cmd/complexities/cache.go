@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// cacheVersion is part of all cache keys, so that entries written by
+// releases encoding results differently are never read back
+const cacheVersion = 1
+
+// resultsCache stores parsed records and fee replays on disk, keyed by
+// digests of their inputs, so that repeated runs over the same data skip work.
+// Cache failures are logged and never fail a run. A nil cache stores nothing.
+type resultsCache struct {
+	dir string
+}
+
+func newResultsCache(dir string) (*resultsCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed creating cache dir %s: %w", dir, err)
+	}
+	return &resultsCache{dir: dir}, nil
+}
+
+// cacheKey digests [kind] and [parts], formatted with their Go syntax
+// so that configs and column layouts are keyed field by field
+func cacheKey(kind string, parts ...any) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%s", cacheVersion, kind)
+	for _, p := range parts {
+		fmt.Fprintf(h, "|%#v", p)
+	}
+	return kind + "-" + hex.EncodeToString(h.Sum(nil))
+}
+
+// load decodes the entry stored at [key] into [v] and returns whether it was found
+func (c *resultsCache) load(key string, v any) bool {
+	if c == nil {
+		return false
+	}
+	path := filepath.Join(c.dir, key+".gob")
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("failed reading cache entry", "path", path, "err", err)
+		}
+		return false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(v); err != nil {
+		slog.Warn("failed decoding cache entry", "path", path, "err", err)
+		return false
+	}
+	slog.Debug("cache hit", "key", key)
+	return true
+}
+
+// store encodes [v] at [key]. Entries are written to a temporary file first,
+// so that concurrent runs never read partial entries.
+func (c *resultsCache) store(key string, v any) {
+	if c == nil {
+		return
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		slog.Warn("failed encoding cache entry", "key", key, "err", err)
+		return
+	}
+
+	path := filepath.Join(c.dir, key+".gob")
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		slog.Warn("failed creating cache entry", "path", path, "err", err)
+		return
+	}
+	defer os.Remove(f.Name()) // no-op once renamed
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		slog.Warn("failed writing cache entry", "path", path, "err", err)
+		return
+	}
+	if err := f.Close(); err != nil {
+		slog.Warn("failed writing cache entry", "path", path, "err", err)
+		return
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		slog.Warn("failed storing cache entry", "path", path, "err", err)
+	}
+}
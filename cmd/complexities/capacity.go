@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+)
+
+// capacityEntry summarizes the capacity utilization of the dataset under a fee config
+type capacityEntry struct {
+	Config          string             `json:"config"`
+	MaxGasPerSecond uint64             `json:"max_gas_per_second"`
+	Blocks          int                `json:"blocks"`
+	Mean            float64            `json:"mean"`
+	Max             float64            `json:"max"`
+	Quantiles       map[string]float64 `json:"quantiles"`
+	OverCapacity    int                `json:"over_capacity"`
+	OverCapacityPct float64            `json:"over_capacity_pct"`
+}
+
+// runCapacity reports, for each fee config, how much of the gas budget granted by
+// MaxGasPerSecond blocks consume and how often they would have been over capacity
+func runCapacity(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+
+	entries := make([]capacityEntry, 0, len(o.feeCfgs))
+	for i, c := range o.feeCfgs {
+		u := complexity.ComputeCapacityUtilization(a.records, c.cfg, o.capacityQuantiles)
+		e := capacityEntry{
+			Config:          c.name,
+			MaxGasPerSecond: uint64(c.cfg.MaxGasPerSecond),
+			Blocks:          len(u.Blocks),
+			Mean:            u.Mean,
+			Max:             u.Max,
+			Quantiles:       make(map[string]float64, len(o.capacityQuantiles)),
+			OverCapacity:    u.OverCapacity,
+		}
+		if len(u.Blocks) > 0 {
+			e.OverCapacityPct = 100 * float64(u.OverCapacity) / float64(len(u.Blocks))
+		}
+		for j, q := range o.capacityQuantiles {
+			e.Quantiles[quantileLabel(q)] = u.Quantiles[j]
+		}
+		entries = append(entries, e)
+
+		if i == 0 && o.capacityOutPath != "" {
+			if err := writeCapacityCSV(o.capacityOutPath, u.Blocks); err != nil {
+				fatal(err)
+			}
+		}
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(entries); err != nil {
+			fatal(err)
+		}
+		return
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	header := []string{"config", "max gas/s", "blocks", "mean"}
+	for _, q := range o.capacityQuantiles {
+		header = append(header, quantileLabel(q))
+	}
+	header = append(header, "max", "over capacity")
+	fmt.Fprintf(w, "%s\n", strings.Join(header, "\t"))
+	for _, e := range entries {
+		row := []string{e.Config, strconv.FormatUint(e.MaxGasPerSecond, 10), strconv.Itoa(e.Blocks), formatRatio(e.Mean)}
+		for _, q := range o.capacityQuantiles {
+			row = append(row, formatRatio(e.Quantiles[quantileLabel(q)]))
+		}
+		row = append(row, formatRatio(e.Max), fmt.Sprintf("%d (%.2f%%)", e.OverCapacity, e.OverCapacityPct))
+		fmt.Fprintf(w, "%s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintf(a.stdout, "utilization of MaxGasPerSecond * block delay, in percent\n")
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+// quantileLabel names quantile [q], e.g. p99 for 0.99
+func quantileLabel(q float64) string {
+	return "p" + strconv.FormatFloat(100*q, 'g', -1, 64)
+}
+
+// formatRatio prints utilization ratio [r] as a percentage
+func formatRatio(r float64) string {
+	return strconv.FormatFloat(100*r, 'f', 2, 64) + "%"
+}
+
+// writeCapacityCSV writes one row per block with its gas, budget and utilization,
+// preceded by a header
+func writeCapacityCSV(path string, blocks []complexity.BlockCapacity) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height", "time", "gas", "budget", "utilization_pct"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, b := range blocks {
+		row := []string{
+			strconv.FormatUint(b.Height, 10),
+			strconv.FormatUint(b.Time, 10),
+			strconv.FormatUint(b.Gas, 10),
+			strconv.FormatUint(b.Budget, 10),
+			strconv.FormatFloat(100*b.Ratio, 'f', 2, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", b.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
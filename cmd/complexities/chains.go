@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"process_data/pkg/complexity"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// chain holds the defaults of a chain whose complexities can be analyzed
+type chain struct {
+	name string
+
+	// csvPath is read unless -csv is set
+	csvPath string
+
+	// columns is the CSV layout of the chain exports, used unless -columns is set
+	columns columns
+
+	// minHeight is the first height whose blocks are accounted for
+	// when computing targets. Blocks below it predate the rules analyzed here.
+	minHeight uint64
+
+	// explorerURL is the block explorer page of the chain blocks,
+	// to be followed by a block ID. Used unless -explorer-url is set.
+	explorerURL string
+}
+
+var chains = []chain{
+	{
+		name:        "P",
+		csvPath:     "./P-chain_complexities.csv",
+		columns:     defaultColumns,
+		minHeight:   complexity.MinBanffHeight,
+		explorerURL: "https://subnets.avax.network/p-chain/block",
+	},
+	{
+		// X-chain blocks are produced since Cortina linearization only,
+		// so all of them are accounted for
+		name:        "X",
+		csvPath:     "./X-chain_complexities.csv",
+		columns:     defaultColumns,
+		minHeight:   0,
+		explorerURL: "https://subnets.avax.network/x-chain/block",
+	},
+}
+
+func getChain(name string) (chain, error) {
+	for _, c := range chains {
+		if strings.EqualFold(c.name, name) {
+			return c, nil
+		}
+	}
+	return chain{}, fmt.Errorf("unsupported chain %q, supported values are P, X", name)
+}
+
+// noExplorer disables explorer links when passed as -explorer-url
+const noExplorer = "none"
+
+// blockURL returns the explorer page of block [id], or an empty string if
+// links are disabled, i.e. [explorerURL] is empty
+func blockURL(explorerURL string, id ids.ID) string {
+	if explorerURL == "" {
+		return ""
+	}
+	return strings.TrimSuffix(explorerURL, "/") + "/" + id.String()
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	idColumn          = "id"
+	heightColumn      = "height"
+	timeColumn        = "time"
+	observedFeeColumn = "observed_fee"
+	txTypeColumn      = "tx_type"
+)
+
+var (
+	// complexityColumns names the complexity columns, indexed by dimension,
+	// after the dimensions of the fee package, e.g. DBRead as db_read
+	complexityColumns = func() [commonfee.NumDimensions]string {
+		var res [commonfee.NumDimensions]string
+		for d := range res {
+			res[d] = snakeCase(complexity.DimensionStrings[d])
+		}
+		return res
+	}()
+
+	errMissingColumn = errors.New("missing column mapping")
+)
+
+// columns tells at which index of a CSV row each field is found.
+type columns struct {
+	id         int
+	height     int
+	time       int
+	complexity [commonfee.NumDimensions]int // -1 for dimensions missing from the dataset
+
+	// observedFee is -1 if observed fees are not mapped
+	observedFee int
+
+	// txType is -1 if tx types are not mapped
+	txType int
+
+	// fixedLayout rows must be exactly recordsLen fields long,
+	// or recordsWithFeeLen if they carry the observed fee
+	fixedLayout bool
+}
+
+// defaultColumns is the layout documented in readCsvFile
+var defaultColumns = columns{
+	id:          0,
+	height:      1,
+	time:        2,
+	complexity:  consecutiveColumns(3),
+	observedFee: recordsLen,
+	txType:      -1,
+	fixedLayout: true,
+}
+
+// consecutiveColumns maps dimensions, in order, to the indexes following [first]
+func consecutiveColumns(first int) [commonfee.NumDimensions]int {
+	var res [commonfee.NumDimensions]int
+	for d := range res {
+		res[d] = first + d
+	}
+	return res
+}
+
+// parseColumns parses a mapping like id=0,height=1,time=2,bandwidth=4,...
+// Fields are id, height, time, the complexities of each dimension, e.g. bandwidth,
+// db_read, db_write, compute, and the optional observed_fee and tx_type.
+// Datasets may lack some dimensions, as long as one is mapped.
+// An empty [spec] returns the default layout.
+func parseColumns(spec string) (columns, error) {
+	if spec == "" {
+		return defaultColumns, nil
+	}
+
+	mapping := make(map[string]int)
+	for _, entry := range strings.Split(spec, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return columns{}, fmt.Errorf("invalid column mapping %q, expected name=index", entry)
+		}
+		if name != idColumn && name != heightColumn && name != timeColumn && name != observedFeeColumn && name != txTypeColumn &&
+			!slices.Contains(complexityColumns[:], name) {
+			return columns{}, fmt.Errorf("unknown column %q", name)
+		}
+		if _, ok := mapping[name]; ok {
+			return columns{}, fmt.Errorf("column %q mapped twice", name)
+		}
+		index, err := strconv.Atoi(value)
+		if err != nil || index < 0 {
+			return columns{}, fmt.Errorf("invalid index %q for column %q", value, name)
+		}
+		mapping[name] = index
+	}
+
+	for _, name := range []string{idColumn, heightColumn, timeColumn} {
+		if _, ok := mapping[name]; !ok {
+			return columns{}, fmt.Errorf("%w: %s", errMissingColumn, name)
+		}
+	}
+
+	res := columns{
+		id:          mapping[idColumn],
+		height:      mapping[heightColumn],
+		time:        mapping[timeColumn],
+		observedFee: -1,
+		txType:      -1,
+	}
+	mapped := false
+	for d, name := range complexityColumns {
+		index, ok := mapping[name]
+		if !ok {
+			index = -1
+		}
+		res.complexity[d] = index
+		mapped = mapped || ok
+	}
+	if !mapped {
+		return columns{}, fmt.Errorf("%w: no complexity dimension, one of %s", errMissingColumn, strings.Join(complexityColumns[:], ", "))
+	}
+	if index, ok := mapping[observedFeeColumn]; ok {
+		res.observedFee = index
+	}
+	if index, ok := mapping[txTypeColumn]; ok {
+		res.txType = index
+	}
+	return res, nil
+}
+
+// minRowLen returns the number of fields a row needs for all mapped required columns
+// to be in it. Observed fee and tx type are optional, rows may lack them.
+func (c columns) minRowLen() int {
+	return max(c.id, c.height, c.time, slices.Max(c.complexity[:])) + 1
+}
+
+// checkRowLen verifies that [row] holds all mapped required columns
+func (c columns) checkRowLen(row []string, ri int) error {
+	if c.fixedLayout {
+		if len(row) != recordsLen && len(row) != recordsWithFeeLen {
+			return fmt.Errorf("unexpected line %d lenght: %d", ri, len(row))
+		}
+		return nil
+	}
+	if len(row) < c.minRowLen() {
+		return fmt.Errorf("unexpected line %d lenght: %d, required columns need at least %d fields", ri, len(row), c.minRowLen())
+	}
+	return nil
+}
+
+// missingDimensions returns the names of dimensions not mapped to any column
+func (c columns) missingDimensions() []string {
+	res := make([]string, 0)
+	for d, index := range c.complexity {
+		if index < 0 {
+			res = append(res, complexityColumns[d])
+		}
+	}
+	return res
+}
+
+// hasObservedFee tells whether [row] carries the observed fee
+func (c columns) hasObservedFee(row []string) bool {
+	return c.observedFee >= 0 && c.observedFee < len(row)
+}
+
+// hasTxType tells whether [row] carries the tx type
+func (c columns) hasTxType(row []string) bool {
+	return c.txType >= 0 && c.txType < len(row)
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/zstd"
+)
+
+// Compressed files are told apart by extension, e.g. blocks.csv.gz,
+// or, for inputs, by their leading magic bytes
+const (
+	gzipExt = ".gz"
+	zstdExt = ".zst"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// compressionExt returns the compression extension of [path], if any
+func compressionExt(path string) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == gzipExt || ext == zstdExt {
+		return ext
+	}
+	return ""
+}
+
+// contentExt returns the extension of [path] content, i.e. .csv for blocks.csv.gz
+func contentExt(path string) string {
+	if ext := compressionExt(path); ext != "" {
+		path = path[:len(path)-len(ext)]
+	}
+	return filepath.Ext(path)
+}
+
+// decompress returns a reader of the content of [in], decompressing it
+// if [path] has a compressed extension or [in] starts with magic bytes
+func decompress(path string, in io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(in)
+	magic, _ := br.Peek(len(zstdMagic)) // short inputs are handled as plain text
+	ext := compressionExt(path)
+	switch {
+	case ext == gzipExt || bytes.HasPrefix(magic, gzipMagic):
+		r, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed decompressing %s as gzip: %w", path, err)
+		}
+		return r, nil
+	case ext == zstdExt || bytes.HasPrefix(magic, zstdMagic):
+		return zstd.NewReader(br), nil
+	default:
+		return io.NopCloser(br), nil
+	}
+}
+
+// compressedFile compresses what is written to it into a file.
+// Closing it flushes the compressor, then closes the file. It can be closed
+// more than once, as outputs are both closed on return and deferred.
+type compressedFile struct {
+	io.WriteCloser
+	f      *os.File
+	closed bool
+}
+
+func (c *compressedFile) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if err := c.WriteCloser.Close(); err != nil {
+		c.f.Close()
+		return err
+	}
+	return c.f.Close()
+}
+
+// createOutput creates the output file [path], compressing what is written to it
+// if [path] has a .gz or .zst extension
+func createOutput(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	switch compressionExt(path) {
+	case gzipExt:
+		return &compressedFile{WriteCloser: gzip.NewWriter(f), f: f}, nil
+	case zstdExt:
+		return &compressedFile{WriteCloser: zstd.NewWriter(f), f: f}, nil
+	default:
+		return f, nil
+	}
+}
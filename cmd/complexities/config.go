@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/utils/units"
+	"gopkg.in/yaml.v3"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var (
+	errZeroUpdateDenominator = errors.New("update denominator must be non-zero")
+	errZeroGasTargetRate     = errors.New("gas target rate must be non-zero")
+
+	// defaultFeeConfig is used whenever no fee config file is provided
+	defaultFeeConfig = commonfee.Config{
+		MinGasPrice:              commonfee.GasPrice(10 * units.NanoAvax),
+		ExcessConversionConstant: commonfee.Gas(100_000),
+		TargetGasPerSecond:       commonfee.Gas(2_500),
+		Weights:                  defaultFeeDimensionWeights(),
+		MaxGasPerSecond:          commonfee.Gas(1_000_000),
+	}
+)
+
+// defaultFeeDimensionWeights weights the dimensions of the fee package by name,
+// so that dimensions it may add or drop do not shift weights of the others.
+// Dimensions not listed weigh 1.
+func defaultFeeDimensionWeights() commonfee.Dimensions {
+	byName := map[string]uint64{
+		"Bandwidth": 6,
+		"DBRead":    10,
+		"DBWrite":   10,
+		"Compute":   1,
+	}
+	var res commonfee.Dimensions
+	for d := range res {
+		w, ok := byName[complexity.DimensionStrings[d]]
+		if !ok {
+			w = 1
+		}
+		res[d] = w
+	}
+	return res
+}
+
+// denomination is the unit fees are expressed in, along with the precision they are printed with
+type denomination struct {
+	name  string
+	label string
+	unit  uint64
+
+	// precision is the number of decimals fees are printed with,
+	// -1 printing the fewest needed to represent them exactly
+	precision int
+}
+
+var denominations = []denomination{
+	{name: "avax", label: "Avax", unit: units.Avax},
+	{name: "milliavax", label: "mAvax", unit: units.MilliAvax},
+	{name: "microavax", label: "µAvax", unit: units.MicroAvax},
+	{name: "nanoavax", label: "nAvax", unit: units.NanoAvax},
+}
+
+// getDenomination returns the denomination named or labeled [name], ignoring case,
+// e.g. nanoavax or nAVAX
+func getDenomination(name string) (denomination, error) {
+	for _, d := range denominations {
+		if strings.EqualFold(d.name, name) || strings.EqualFold(d.label, name) {
+			return d, nil
+		}
+	}
+	return denomination{}, fmt.Errorf("unsupported denomination %q, supported values are avax, milliavax, microavax, nanoavax or their labels Avax, mAvax, µAvax, nAvax", name)
+}
+
+// defaultFeeConfigName labels [defaultFeeConfig] in outputs
+const defaultFeeConfigName = "default"
+
+// namedFeeConfig pairs a fee config with the label used in outputs
+type namedFeeConfig struct {
+	name string
+	cfg  commonfee.Config
+}
+
+// loadFeeConfigs loads each of the comma separated [paths], labeling configs
+// by file name, extension included if names would clash otherwise, so that
+// legends tell configs apart. If [paths] is empty, [defaultFeeConfig] is returned.
+func loadFeeConfigs(paths string) ([]namedFeeConfig, error) {
+	if paths == "" {
+		return []namedFeeConfig{{name: defaultFeeConfigName, cfg: defaultFeeConfig}}, nil
+	}
+
+	var (
+		res   = make([]namedFeeConfig, 0)
+		names = make(map[string]int)
+	)
+	for _, path := range strings.Split(paths, ",") {
+		names[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))]++
+	}
+	for _, path := range strings.Split(paths, ",") {
+		cfg, err := loadFeeConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if names[name] > 1 {
+			name = filepath.Base(path)
+		}
+		res = append(res, namedFeeConfig{
+			name: name,
+			cfg:  cfg,
+		})
+	}
+	return res, nil
+}
+
+// feeConfigFile mirrors commonfee.Config with our own JSON and YAML keys,
+// so that config files do not depend on upstream struct tags.
+// The update denominator is the excess conversion constant of the fee package.
+// Fields missing from the file keep their value from [defaultFeeConfig].
+type feeConfigFile struct {
+	MinGasPrice              uint64               `json:"min_gas_price"         yaml:"min_gas_price"`
+	ExcessConversionConstant uint64               `json:"update_denominator"    yaml:"update_denominator"`
+	TargetGasPerSecond       uint64               `json:"gas_target_rate"       yaml:"gas_target_rate"`
+	Weights                  commonfee.Dimensions `json:"fee_dimension_weights" yaml:"fee_dimension_weights"`
+	MaxGasPerSecond          uint64               `json:"max_gas_per_second"    yaml:"max_gas_per_second"`
+}
+
+// unmarshalConfig decodes [b] as YAML if [path] has a .yaml or .yml extension, as JSON otherwise
+func unmarshalConfig(path string, b []byte, v any) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(b, v)
+	default:
+		return json.Unmarshal(b, v)
+	}
+}
+
+func loadFeeConfig(path string) (commonfee.Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return commonfee.Config{}, fmt.Errorf("failed reading fee config %s: %w", path, err)
+	}
+
+	f := feeConfigFile{
+		MinGasPrice:              uint64(defaultFeeConfig.MinGasPrice),
+		ExcessConversionConstant: uint64(defaultFeeConfig.ExcessConversionConstant),
+		TargetGasPerSecond:       uint64(defaultFeeConfig.TargetGasPerSecond),
+		Weights:                  defaultFeeConfig.Weights,
+		MaxGasPerSecond:          uint64(defaultFeeConfig.MaxGasPerSecond),
+	}
+	if err := unmarshalConfig(path, b, &f); err != nil {
+		return commonfee.Config{}, fmt.Errorf("failed parsing fee config %s: %w", path, err)
+	}
+
+	cfg := commonfee.Config{
+		MinGasPrice:              commonfee.GasPrice(f.MinGasPrice),
+		ExcessConversionConstant: commonfee.Gas(f.ExcessConversionConstant),
+		TargetGasPerSecond:       commonfee.Gas(f.TargetGasPerSecond),
+		Weights:                  f.Weights,
+		MaxGasPerSecond:          commonfee.Gas(f.MaxGasPerSecond),
+	}
+	if err := validateFeeConfig(cfg); err != nil {
+		return commonfee.Config{}, fmt.Errorf("invalid fee config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// The update denominator and gas target rate are used as divisors
+// while updating gas prices, so they must be non-zero
+func validateFeeConfig(cfg commonfee.Config) error {
+	if cfg.ExcessConversionConstant == 0 {
+		return errZeroUpdateDenominator
+	}
+	if cfg.TargetGasPerSecond == 0 {
+		return errZeroGasTargetRate
+	}
+	return nil
+}
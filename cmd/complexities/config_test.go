@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+func TestLoadFeeConfigRoundTrip(t *testing.T) {
+	cfg := commonfee.Config{
+		MinGasPrice:              commonfee.GasPrice(25),
+		ExcessConversionConstant: commonfee.Gas(50_000),
+		TargetGasPerSecond:       commonfee.Gas(4_000),
+		MaxGasPerSecond:          commonfee.Gas(2_000_000),
+	}
+	for d := range cfg.Weights {
+		cfg.Weights[d] = uint64(d + 2)
+	}
+	f := feeConfigFile{
+		MinGasPrice:              uint64(cfg.MinGasPrice),
+		ExcessConversionConstant: uint64(cfg.ExcessConversionConstant),
+		TargetGasPerSecond:       uint64(cfg.TargetGasPerSecond),
+		Weights:                  cfg.Weights,
+		MaxGasPerSecond:          uint64(cfg.MaxGasPerSecond),
+	}
+
+	for _, name := range []string{"fee_config.json", "fee_config.yaml"} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				b   []byte
+				err error
+			)
+			if filepath.Ext(name) == ".json" {
+				b, err = json.Marshal(f)
+			} else {
+				b, err = yaml.Marshal(f)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, b, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := loadFeeConfig(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded != cfg {
+				t.Fatalf("expected %+v, got %+v", cfg, loaded)
+			}
+		})
+	}
+}
+
+func TestLoadFeeConfigSamples(t *testing.T) {
+	// sample files shipped along the tool hold the default config
+	for _, path := range []string{"../../fee_config.json", "../../fee_config.yaml"} {
+		cfg, err := loadFeeConfig(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg != defaultFeeConfig {
+			t.Fatalf("%s: expected %+v, got %+v", path, defaultFeeConfig, cfg)
+		}
+	}
+}
+
+func TestLoadFeeConfigDefaults(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fee_config.json")
+	if err := os.WriteFile(path, []byte(`{"gas_target_rate": 4000}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := loadFeeConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := defaultFeeConfig
+	expected.TargetGasPerSecond = 4_000
+	if cfg != expected {
+		t.Fatalf("expected fields missing from the file to keep their default, got %+v", cfg)
+	}
+}
+
+func TestLoadFeeConfigRejectsZeroDivisors(t *testing.T) {
+	tests := []struct {
+		content string
+		err     error
+	}{
+		{content: `{"update_denominator": 0}`, err: errZeroUpdateDenominator},
+		{content: `{"gas_target_rate": 0}`, err: errZeroGasTargetRate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "fee_config.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := loadFeeConfig(path); !errors.Is(err, tt.err) {
+				t.Fatalf("expected %v, got %v", tt.err, err)
+			}
+		})
+	}
+}
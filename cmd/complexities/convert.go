@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// runConvert writes records read from any input, once filtered, to -records-out,
+// e.g. to turn a large CSV export into a Parquet file read faster by later runs
+func runConvert(ctx context.Context, o *options) {
+	if o.recordsOutPath == "" {
+		fatal(fmt.Errorf("convert needs an output file, set -records-out"))
+	}
+	a := loadRecords(ctx, o)
+	if err := writeRecords(o.recordsOutPath, a.records); err != nil {
+		fatal(err)
+	}
+	slog.Info("converted records", "path", o.recordsOutPath, "records", len(a.records))
+}
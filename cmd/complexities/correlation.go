@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// scatterMaxPoints bounds the blocks drawn in each scatter plot.
+// Longer samples are thinned out evenly.
+const scatterMaxPoints = 20_000
+
+// correlationMatrix is a correlation matrix as printed in JSON,
+// correlations with a constant dimension being null
+type correlationMatrix struct {
+	Metric string       `json:"metric"`
+	Method string       `json:"method"`
+	Matrix [][]*float64 `json:"matrix"`
+}
+
+type correlationReport struct {
+	Blocks     int                 `json:"blocks"`
+	Dimensions []string            `json:"dimensions"`
+	Matrices   []correlationMatrix `json:"matrices"`
+}
+
+// runCorrelation reports Pearson and Spearman correlations among dimensions, of per block
+// complexities and of complexity rates, and plots each pair of dimensions against each other,
+// to tell whether fee weights can treat dimensions as independent.
+// Blocks are those TargetComplexityRate accounts for.
+func runCorrelation(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+	c, err := complexity.CorrelateDimensions(a.derived, o.chain.minHeight)
+	if err != nil {
+		fatal(err)
+	}
+
+	r := correlationReport{
+		Blocks: c.Blocks,
+		Matrices: []correlationMatrix{
+			newCorrelationMatrix("complexity", "pearson", c.ComplexityPearson),
+			newCorrelationMatrix("complexity", "spearman", c.ComplexitySpearman),
+			newCorrelationMatrix("rate", "pearson", c.RatePearson),
+			newCorrelationMatrix("rate", "spearman", c.RateSpearman),
+		},
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		r.Dimensions = append(r.Dimensions, complexity.DimensionStrings[d])
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(r); err != nil {
+			fatal(err)
+		}
+	} else {
+		fmt.Fprintf(a.stdout, "correlations over %d blocks\n\n", r.Blocks)
+		for _, m := range r.Matrices {
+			printCorrelationMatrix(a.stdout, r.Dimensions, m)
+		}
+	}
+
+	if o.noPlot {
+		return
+	}
+	out, err := newPlotOutput(o.outDir, o.plotFormat)
+	if err != nil {
+		fatal(err)
+	}
+	complexities, rates, err := complexity.CorrelationSamples(a.derived, o.chain.minHeight)
+	if err != nil {
+		fatal(err)
+	}
+	if out.format == htmlFormat {
+		a.tolerate(printHTMLScatters(out, complexities, rates))
+		return
+	}
+	for i := commonfee.Dimension(0); i < commonfee.NumDimensions; i++ {
+		for j := i + 1; j < commonfee.NumDimensions; j++ {
+			a.tolerate(printScatterImage(out, "complexity", complexities, i, j))
+			a.tolerate(printScatterImage(out, "rate", rates, i, j))
+		}
+	}
+}
+
+func newCorrelationMatrix(metric, method string, m complexity.CorrelationMatrix) correlationMatrix {
+	res := correlationMatrix{
+		Metric: metric,
+		Method: method,
+		Matrix: make([][]*float64, len(m)),
+	}
+	for i := range m {
+		res.Matrix[i] = make([]*float64, len(m[i]))
+		for j := range m[i] {
+			if !math.IsNaN(m[i][j]) {
+				res.Matrix[i][j] = &m[i][j]
+			}
+		}
+	}
+	return res
+}
+
+// printCorrelationMatrix prints [m] as an aligned table, one row per dimension
+func printCorrelationMatrix(out io.Writer, dimensions []string, m correlationMatrix) {
+	fmt.Fprintf(out, "%s %s correlation\n", m.Metric, m.Method)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\t%s\n", strings.Join(dimensions, "\t"))
+	for i, row := range m.Matrix {
+		cells := []string{dimensions[i]}
+		for _, v := range row {
+			if v == nil {
+				cells = append(cells, "n/a")
+				continue
+			}
+			cells = append(cells, strconv.FormatFloat(*v, 'f', 3, 64))
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n")
+}
+
+// scatterStride returns the step blocks are picked with, so that
+// at most [scatterMaxPoints] out of [n] are drawn
+func scatterStride(n int) int {
+	return max(1, (n+scatterMaxPoints-1)/scatterMaxPoints)
+}
+
+// printScatterImage plots [samples] of dimension [y] against those of dimension [x]
+// into scatter_<metric>_<x>_<y> file
+func printScatterImage(out plotOutput, metric string, samples [commonfee.NumDimensions][]float64, x, y commonfee.Dimension) error {
+	var (
+		xName  = complexity.DimensionStrings[x]
+		yName  = complexity.DimensionStrings[y]
+		stride = scatterStride(len(samples[x]))
+		pts    = make(plotter.XYs, 0, len(samples[x])/stride+1)
+	)
+	for i := 0; i < len(samples[x]); i += stride {
+		pts = append(pts, plotter.XY{X: samples[x][i], Y: samples[y][i]})
+	}
+	s, err := plotter.NewScatter(pts)
+	if err != nil {
+		return fmt.Errorf("failed plotting %s against %s %s: %w", yName, xName, metric, err)
+	}
+	s.GlyphStyle.Radius = vg.Points(1)
+
+	p := plot.New()
+	p.Title.Text = fmt.Sprintf("%s %s vs %s", strings.ToUpper(metric[:1])+metric[1:], yName, xName)
+	p.X.Label.Text = xName
+	p.Y.Label.Text = yName
+	p.Add(s)
+
+	path := out.path(fmt.Sprintf("scatter_%s_%s_%s", metric, snakeCase(xName), snakeCase(yName)))
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printHTMLScatters renders, for each pair of dimensions, complexities and
+// complexity rates of one against the other into correlation file
+func printHTMLScatters(out plotOutput, complexities, rates [commonfee.NumDimensions][]float64) error {
+	charts := make([]htmlChart, 0)
+	for i := commonfee.Dimension(0); i < commonfee.NumDimensions; i++ {
+		for j := i + 1; j < commonfee.NumDimensions; j++ {
+			xName, yName := complexity.DimensionStrings[i], complexity.DimensionStrings[j]
+			for _, s := range []struct {
+				metric  string
+				samples [commonfee.NumDimensions][]float64
+			}{
+				{metric: "complexity", samples: complexities},
+				{metric: "rate", samples: rates},
+			} {
+				stride := scatterStride(len(s.samples[i]))
+				xs := make([]float64, 0, len(s.samples[i])/stride+1)
+				ys := make([]float64, 0, cap(xs))
+				for k := 0; k < len(s.samples[i]); k += stride {
+					xs = append(xs, s.samples[i][k])
+					ys = append(ys, s.samples[j][k])
+				}
+				charts = append(charts, htmlChart{
+					ID:     fmt.Sprintf("scatter_%s_%s_%s", s.metric, snakeCase(xName), snakeCase(yName)),
+					Title:  fmt.Sprintf("%s %s vs %s", s.metric, yName, xName),
+					XLabel: xName,
+					YLabel: yName,
+					Traces: []htmlTrace{{
+						Name: s.metric,
+						Type: "scattergl",
+						Mode: "markers",
+						X:    xs,
+						Y:    ys,
+					}},
+				})
+			}
+		}
+	}
+	return writeHTMLCharts(out, "correlation", "dimension correlations", charts)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	recordsLen = 3 + commonfee.NumDimensions
+
+	// an optional extra column may carry the fee observed on chain, in nAvax
+	recordsWithFeeLen = recordsLen + 1
+
+	// stdinPath, or an empty path, makes the reader consume standard input
+	stdinPath = "-"
+
+	// rows are parsed in batches of ctxCheckInterval between checks for cancellation
+	ctxCheckInterval = 1024
+)
+
+// CSV structure is assumed to be the following, unless [cols] maps fields differently:
+// [Blk-ID, Blk-Height, Blk-Time, [Complexities], (Observed-Fee)]
+// Where complexities are those of each dimension, i.e. [Bandwitdth, UTXOsRead, UTXOsWrite, Compute]
+// and the optional observed fee is expressed in nAvax
+func readCsvFile(ctx context.Context, filePath string, cols columns, onError string) []complexity.Record {
+	start := time.Now()
+	res := make([]complexity.Record, 0)
+	err := forEachRecord(ctx, filePath, cols, onError, func(r complexity.Record) error {
+		res = append(res, r)
+		return nil
+	})
+	if err != nil {
+		fatal(err)
+	}
+	slog.Info("parsed input file", "path", filePath, "records", len(res), "elapsed", time.Since(start))
+	return res
+}
+
+// expandInputPaths replaces directories among [paths] with the CSV files they contain,
+// compressed or not, and glob patterns with the files they match, both in lexical order,
+// so that chunked exports can be passed at once. Stdin and plain files are kept as they are.
+func expandInputPaths(paths []string) ([]string, error) {
+	res := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == stdinPath || path == "" {
+			res = append(res, path)
+			continue
+		}
+
+		if strings.ContainsAny(path, "*?[") {
+			matches, err := filepath.Glob(path)
+			if err != nil {
+				return nil, fmt.Errorf("invalid input pattern %q: %w", path, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no input file matches %q", path)
+			}
+			res = append(res, matches...)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.IsDir() {
+			// missing files are reported once read
+			res = append(res, path)
+			continue
+		}
+		matches := make([]string, 0)
+		for _, pattern := range []string{"*.csv", "*.csv" + gzipExt, "*.csv" + zstdExt, "*" + parquetExt} {
+			m, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, fmt.Errorf("failed listing %s: %w", path, err)
+			}
+			matches = append(matches, m...)
+		}
+		slices.Sort(matches)
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no CSV or Parquet file in directory %s", path)
+		}
+		res = append(res, matches...)
+	}
+	return res, nil
+}
+
+// readCsvFiles reads all [filePaths] and merges them into a single,
+// height-sorted slice of records, without duplicates.
+// Files are also returned digests of, which are nil if any of them,
+// e.g. stdin, can not be cached.
+func readCsvFiles(ctx context.Context, filePaths []string, cols columns, onError string, cache *resultsCache) ([]complexity.Record, []string) {
+	var (
+		sets    = make([][]complexity.Record, 0, len(filePaths))
+		digests = make([]string, 0, len(filePaths))
+	)
+	for _, filePath := range filePaths {
+		records, digest := readCachedCsvFile(ctx, filePath, cols, onError, cache)
+		sets = append(sets, records)
+		digests = append(digests, digest)
+	}
+	if slices.Contains(digests, "") {
+		digests = nil
+	}
+	if len(sets) == 1 {
+		return sets[0], digests
+	}
+
+	records, duplicates := complexity.MergeRecords(sets...)
+	slog.Info("merged input files", "files", len(filePaths), "records", len(records), "duplicates", duplicates)
+	return records, digests
+}
+
+// readCachedCsvFile reads [filePath], reusing its records from [cache] if it was
+// parsed before with the same content, layout and error handling.
+// It returns the file digest, which is empty if the file is not cached.
+func readCachedCsvFile(ctx context.Context, filePath string, cols columns, onError string, cache *resultsCache) ([]complexity.Record, string) {
+	if cache == nil || filePath == stdinPath || filePath == "" {
+		return readCsvFile(ctx, filePath, cols, onError), ""
+	}
+
+	digest, err := fileDigest(filePath)
+	if err != nil {
+		fatal(fmt.Errorf("unable to read input file %s: %w", filePath, err))
+	}
+	var (
+		key     = cacheKey("records", digest, cols, onError)
+		records []complexity.Record
+	)
+	if cache.load(key, &records) {
+		slog.Info("loaded cached input file", "path", filePath, "records", len(records))
+		return records, digest
+	}
+	records = readCsvFile(ctx, filePath, cols, onError)
+	cache.store(key, records)
+	return records, digest
+}
+
+// forEachRecord parses [filePath] one row at a time and hands each record to [fn],
+// so that rows are never buffered all together. Iteration stops at the first
+// error, either from parsing or returned by [fn].
+// Parquet files, told apart by extension, are read by forEachParquetRecord.
+// A header row, if present, is detected and skipped. Malformed rows stop
+// iteration unless [onError] tells to go on, in which case they are skipped.
+// Iteration is also interrupted, returning the context error, once [ctx] is done.
+func forEachRecord(ctx context.Context, filePath string, cols columns, onError string, fn func(complexity.Record) error) error {
+	if isParquet(filePath) {
+		return forEachParquetRecord(ctx, filePath, fn)
+	}
+
+	var in io.Reader = os.Stdin
+	if filePath != stdinPath && filePath != "" {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("unable to read input file %s: %w", filePath, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	content, err := decompress(filePath, in)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	csvReader := csv.NewReader(content)
+	csvReader.FieldsPerRecord = -1 // row length is checked in parseRecord
+	csvReader.ReuseRecord = true
+
+	for ri := 0; ; ri++ {
+		if ri%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to parse file as CSV for %s: %w", filePath, err)
+		}
+
+		entry, err := parseRecord(row, ri, cols)
+		if err != nil {
+			if ri == 0 && isHeaderRow(row, cols) {
+				continue
+			}
+			if onError == onErrorAbort {
+				return err
+			}
+			handleError(onError, fmt.Errorf("skipping row of %s: %w", filePath, err))
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// isHeaderRow returns true if no field of [row] can be parsed as
+// the value expected at its position, as it is the case for column names.
+func isHeaderRow(row []string, cols columns) bool {
+	if len(row) <= cols.id {
+		return false
+	}
+	if _, err := ids.FromString(row[cols.id]); err == nil {
+		return false
+	}
+	for i, field := range row {
+		if i == cols.id {
+			continue
+		}
+		if _, err := strconv.ParseUint(field, 10, 64); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRecord(row []string, ri int, cols columns) (complexity.Record, error) {
+	if err := cols.checkRowLen(row, ri); err != nil {
+		return complexity.Record{}, err
+	}
+
+	var (
+		entry = complexity.Record{}
+		err   error
+	)
+
+	entry.ID, err = ids.FromString(row[cols.id])
+	if err != nil {
+		return complexity.Record{}, fmt.Errorf("failed processing blkID, line %d: %w", ri, err)
+	}
+
+	entry.Height, err = strconv.ParseUint(row[cols.height], 10, 64)
+	if err != nil {
+		return complexity.Record{}, fmt.Errorf("failed processing blkHeight, line %d: %w", ri, err)
+	}
+
+	entry.Time, err = strconv.ParseUint(row[cols.time], 10, 64)
+	if err != nil {
+		return complexity.Record{}, fmt.Errorf("failed processing blkTime, line %d: %w", ri, err)
+	}
+
+	for d, index := range cols.complexity {
+		// dimensions missing from the dataset are read as no complexity
+		if index < 0 {
+			continue
+		}
+		entry.Complexity[d], err = strconv.ParseUint(row[index], 10, 64)
+		if err != nil {
+			return complexity.Record{}, fmt.Errorf("failed processing %s, line %d: %w", complexityColumns[d], ri, err)
+		}
+	}
+
+	if cols.hasObservedFee(row) {
+		entry.ObservedFee, err = strconv.ParseUint(row[cols.observedFee], 10, 64)
+		if err != nil {
+			return complexity.Record{}, fmt.Errorf("failed processing observed fee, line %d: %w", ri, err)
+		}
+		entry.HasObservedFee = true
+	}
+	if cols.hasTxType(row) {
+		entry.TxType = strings.TrimSpace(row[cols.txType])
+	}
+
+	return entry, nil
+}
+
+// readTimestamps reads a CSV file of height,timestamp rows, timestamps being Unix seconds.
+// A leading header row is skipped.
+func readTimestamps(path string) (map[uint64]uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s: %w", path, err)
+	}
+
+	res := make(map[uint64]uint64, len(rows))
+	for i, row := range rows {
+		if len(row) < 2 {
+			return nil, fmt.Errorf("row %d of %s has %d fields, height and timestamp are expected", i, path, len(row))
+		}
+		height, err := strconv.ParseUint(row[0], 10, 64)
+		if err != nil && i == 0 {
+			continue // header
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing height in row %d of %s: %w", i, path, err)
+		}
+		t, err := strconv.ParseUint(row[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing timestamp, height %d: %w", height, err)
+		}
+		res[height] = t
+	}
+	return res, nil
+}
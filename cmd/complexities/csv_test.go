@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"process_data/pkg/complexity"
+)
+
+// aboveMaxUint64 is math.MaxUint64 + 1
+const aboveMaxUint64 = "18446744073709551616"
+
+// testRow returns a row in the default layout, with complexities 1, 2, 3 and so on
+func testRow(height, time string) []string {
+	row := []string{ids.GenerateTestID().String(), height, time}
+	for d := range complexityColumns {
+		row = append(row, strconv.Itoa(d+1))
+	}
+	return row
+}
+
+func TestParseRecordRejectsOutOfRangeValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		field int
+		value string
+		err   string
+	}{
+		{name: "negative height", field: 1, value: "-1", err: "failed processing blkHeight, line 7"},
+		{name: "overflowing height", field: 1, value: aboveMaxUint64, err: "failed processing blkHeight, line 7"},
+		{name: "negative time", field: 2, value: "-1", err: "failed processing blkTime, line 7"},
+		{name: "overflowing time", field: 2, value: aboveMaxUint64, err: "failed processing blkTime, line 7"},
+		{name: "negative complexity", field: 3, value: "-1", err: "failed processing " + complexityColumns[0] + ", line 7"},
+		{name: "overflowing complexity", field: 3, value: aboveMaxUint64, err: "failed processing " + complexityColumns[0] + ", line 7"},
+		{name: "negative observed fee", field: recordsLen, value: "-1", err: "failed processing observed fee, line 7"},
+		{name: "overflowing observed fee", field: recordsLen, value: aboveMaxUint64, err: "failed processing observed fee, line 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := append(testRow("2723845", "1700000000"), "100")
+			row[tt.field] = tt.value
+
+			_, err := parseRecord(row, 7, defaultColumns)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestParseRecordAcceptsMaxUint64(t *testing.T) {
+	const maxUint64 = "18446744073709551615"
+
+	r, err := parseRecord(testRow(maxUint64, maxUint64), 0, defaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Height != 1<<64-1 || r.Time != 1<<64-1 {
+		t.Fatalf("expected max uint64 height and time, got %d and %d", r.Height, r.Time)
+	}
+}
+
+func TestForEachRecordReportsLineOfRejectedValue(t *testing.T) {
+	for _, value := range []string{"-1", aboveMaxUint64} {
+		t.Run(value, func(t *testing.T) {
+			rows := [][]string{
+				testRow("2723845", "1700000000"),
+				testRow("2723846", "1700000002"),
+				testRow("2723847", value),
+			}
+			lines := make([]string, 0, len(rows))
+			for _, row := range rows {
+				lines = append(lines, strings.Join(row, ","))
+			}
+			path := filepath.Join(t.TempDir(), "complexities.csv")
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			parsed := 0
+			err := forEachRecord(context.Background(), path, defaultColumns, onErrorAbort, func(complexity.Record) error {
+				parsed++
+				return nil
+			})
+			if err == nil || !strings.Contains(err.Error(), "blkTime, line 2") {
+				t.Fatalf("expected line 2 to be rejected, got %v", err)
+			}
+			if parsed != 2 {
+				t.Fatalf("expected 2 records parsed before the rejected one, got %d", parsed)
+			}
+		})
+	}
+}
+
+// benchmarkCSV writes [n] rows in the default layout into a temporary file
+func benchmarkCSV(b *testing.B, n int) string {
+	b.Helper()
+
+	var (
+		path = filepath.Join(b.TempDir(), "complexities.csv")
+		sb   strings.Builder
+	)
+	for i := 0; i < n; i++ {
+		sb.WriteString(strings.Join(testRow(strconv.Itoa(2723845+i), strconv.Itoa(1700000000+2*i)), ","))
+		sb.WriteByte('\n')
+	}
+	if err := os.WriteFile(path, []byte(sb.String()), 0o644); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+// BenchmarkReadCsv compares streaming rows with reading the whole file
+// before parsing it, as the reader used to do
+func BenchmarkReadCsv(b *testing.B) {
+	const rows = 300_000
+	path := benchmarkCSV(b, rows)
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			res := make([]complexity.Record, 0)
+			err := forEachRecord(context.Background(), path, defaultColumns, onErrorAbort, func(r complexity.Record) error {
+				res = append(res, r)
+				return nil
+			})
+			if err != nil || len(res) != rows {
+				b.Fatalf("expected %d records, got %d: %v", rows, len(res), err)
+			}
+		}
+	})
+	b.Run("read_all", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f, err := os.Open(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			all, err := csv.NewReader(f).ReadAll()
+			f.Close()
+			if err != nil {
+				b.Fatal(err)
+			}
+			res := make([]complexity.Record, 0)
+			for ri, row := range all {
+				r, err := parseRecord(row, ri, defaultColumns)
+				if err != nil {
+					b.Fatal(err)
+				}
+				res = append(res, r)
+			}
+		}
+	})
+}
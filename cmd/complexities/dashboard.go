@@ -0,0 +1,492 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	// dashboardMaxPoints bounds the points of each trace sent to the browser.
+	// Longer traces are bucketed, keeping the highest value of each bucket,
+	// so that peaks survive downsampling.
+	dashboardMaxPoints = 5_000
+
+	// dashboardMaxUpload bounds the size of uploaded datasets
+	dashboardMaxUpload = 1 << 30
+)
+
+var errUnknownDataset = errors.New("unknown dataset")
+
+// dashboard serves the analysis of datasets, either loaded at start, found in
+// the datasets dir or uploaded, under fee configs submitted from a form
+type dashboard struct {
+	o *options
+
+	// uploadDir holds uploaded datasets, it is removed once the dashboard stops
+	uploadDir string
+
+	lock sync.Mutex
+	// names lists datasets in the order they are offered in the form
+	names []string
+	// paths locates the datasets not parsed yet
+	paths    map[string]string
+	datasets map[string][]complexity.Record
+}
+
+// runDashboard starts an HTTP server where datasets can be selected or uploaded
+// and fee configs tweaked, re-rendering gas, fees, gas price and excess gas charts
+// on each change, until interrupted
+func runDashboard(ctx context.Context, o *options) {
+	uploadDir, err := os.MkdirTemp("", "complexities-dashboard-*")
+	if err != nil {
+		fatal(fmt.Errorf("failed creating upload dir: %w", err))
+	}
+	defer os.RemoveAll(uploadDir)
+
+	d := &dashboard{
+		o:         o,
+		uploadDir: uploadDir,
+		paths:     make(map[string]string),
+		datasets:  make(map[string][]complexity.Record),
+	}
+	if o.datasetsDir != "" {
+		paths, err := expandInputPaths([]string{o.datasetsDir})
+		if err != nil {
+			fatal(err)
+		}
+		for _, path := range paths {
+			d.add(filepath.Base(path), path, nil)
+		}
+		slog.Info("found datasets", "dir", o.datasetsDir, "count", len(paths))
+	} else {
+		a := loadRecords(ctx, o)
+		name := filepath.Base(o.csvPaths)
+		switch {
+		case o.rpcURI != "":
+			name = o.rpcURI
+		case o.dbPath != "":
+			name = filepath.Base(o.dbPath)
+		}
+		d.add(name, "", a.records)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.serveIndex)
+	mux.HandleFunc("GET /charts", d.serveCharts)
+	mux.HandleFunc("POST /upload", d.serveUpload)
+	server := &http.Server{
+		Addr:              o.dashboardAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		slog.Info("serving dashboard", "addr", o.dashboardAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(fmt.Errorf("failed serving dashboard: %w", err))
+		}
+	}()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fatal(fmt.Errorf("failed shutting down dashboard: %w", err))
+	}
+}
+
+// add offers dataset [name], either already parsed as [records]
+// or to be parsed from [path] once selected. A dataset with the same name is replaced.
+func (d *dashboard) add(name, path string, records []complexity.Record) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if !slices.Contains(d.names, name) {
+		d.names = append(d.names, name)
+	}
+	delete(d.paths, name)
+	delete(d.datasets, name)
+	if records != nil {
+		d.datasets[name] = records
+	} else {
+		d.paths[name] = path
+	}
+}
+
+// records returns dataset [name], parsing it on first use
+func (d *dashboard) records(ctx context.Context, name string) ([]complexity.Record, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if records, ok := d.datasets[name]; ok {
+		return records, nil
+	}
+	path, ok := d.paths[name]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", errUnknownDataset, name)
+	}
+	records, err := parseDataset(ctx, path, d.o)
+	if err != nil {
+		return nil, err
+	}
+	delete(d.paths, name)
+	d.datasets[name] = records
+	return records, nil
+}
+
+// parseDataset reads and validates the records at [path]. Unlike loadRecords,
+// failures are returned, so that a bad upload does not stop the dashboard.
+func parseDataset(ctx context.Context, path string, o *options) ([]complexity.Record, error) {
+	start := time.Now()
+	records := make([]complexity.Record, 0)
+	err := forEachRecord(ctx, path, o.cols, o.onError, func(r complexity.Record) error {
+		records = append(records, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records in %s", path)
+	}
+	if o.cleanMode != complexity.CleanOff {
+		limits, hasLimits := complexity.OutlierLimits(records, o.outlierFactor)
+		var outliers []complexity.Outlier
+		records, outliers = complexity.CleanRecords(records, o.cleanMode, limits, hasLimits)
+		if len(outliers) > 0 {
+			slog.Warn("found implausible records", "path", path, "count", len(outliers), "mode", o.cleanMode)
+		}
+	}
+	if err := complexity.ValidateOrdering(records); err != nil {
+		return nil, err
+	}
+	slog.Info("parsed dataset", "path", path, "records", len(records), "elapsed", time.Since(start))
+	return records, nil
+}
+
+func (d *dashboard) serveIndex(w http.ResponseWriter, r *http.Request) {
+	d.lock.Lock()
+	names := slices.Clone(d.names)
+	d.lock.Unlock()
+
+	selected := r.URL.Query().Get("dataset")
+	if !slices.Contains(names, selected) && len(names) > 0 {
+		selected = names[0]
+	}
+	cfg := d.o.feeCfg()
+	data := struct {
+		Datasets []string
+		Selected string
+		Config   feeConfigFile
+		Denom    string
+	}{
+		Datasets: names,
+		Selected: selected,
+		Config: feeConfigFile{
+			MinGasPrice:              uint64(cfg.MinGasPrice),
+			ExcessConversionConstant: uint64(cfg.ExcessConversionConstant),
+			TargetGasPerSecond:       uint64(cfg.TargetGasPerSecond),
+			Weights:                  cfg.Weights,
+			MaxGasPerSecond:          uint64(cfg.MaxGasPerSecond),
+		},
+		Denom: d.o.denom.label,
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardPage.Execute(w, data); err != nil {
+		slog.Warn("failed rendering dashboard", "err", err)
+	}
+}
+
+func (d *dashboard) serveUpload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, dashboardMaxUpload)
+	f, header, err := r.FormFile("dataset")
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed reading upload: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	name := filepath.Base(header.Filename)
+	path := filepath.Join(d.uploadDir, name)
+	if err := saveUpload(path, f); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	records, err := parseDataset(r.Context(), path, d.o)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid dataset %s: %v", name, err), http.StatusBadRequest)
+		return
+	}
+	d.add(name, "", records)
+	http.Redirect(w, r, "/?dataset="+url.QueryEscape(name), http.StatusSeeOther)
+}
+
+// saveUpload copies [in] to [path]
+func saveUpload(path string, in io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, in); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func (d *dashboard) serveCharts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	cfg, err := parseDashboardConfig(query, d.o.feeCfg())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	records, err := d.records(r.Context(), query.Get("dataset"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errUnknownDataset) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	charts, err := dashboardCharts(r.Context(), records, cfg, d.o)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(charts); err != nil {
+		slog.Warn("failed writing charts", "err", err)
+	}
+}
+
+// parseDashboardConfig reads the fee config submitted in [query], using the keys
+// of fee config files. Missing fields keep their value from [base].
+func parseDashboardConfig(query url.Values, base commonfee.Config) (commonfee.Config, error) {
+	cfg := base
+	fields := []struct {
+		key string
+		dst *uint64
+	}{
+		{key: "min_gas_price", dst: (*uint64)(&cfg.MinGasPrice)},
+		{key: "update_denominator", dst: (*uint64)(&cfg.ExcessConversionConstant)},
+		{key: "gas_target_rate", dst: (*uint64)(&cfg.TargetGasPerSecond)},
+		{key: "max_gas_per_second", dst: (*uint64)(&cfg.MaxGasPerSecond)},
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		fields = append(fields, struct {
+			key string
+			dst *uint64
+		}{key: "weight_" + snakeCase(complexity.DimensionStrings[d]), dst: (*uint64)(&cfg.Weights[d])})
+	}
+	for _, f := range fields {
+		v := query.Get(f.key)
+		if v == "" {
+			continue
+		}
+		parsed, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return commonfee.Config{}, fmt.Errorf("invalid %s %q: %w", f.key, v, err)
+		}
+		*f.dst = parsed
+	}
+	if err := validateFeeConfig(cfg); err != nil {
+		return commonfee.Config{}, err
+	}
+	return cfg, nil
+}
+
+// dashboardCharts replays [records] under [cfg] and returns gas vs target,
+// fees, gas price and excess gas charts, downsampled to [dashboardMaxPoints]
+func dashboardCharts(ctx context.Context, records []complexity.Record, cfg commonfee.Config, o *options) ([]htmlChart, error) {
+	fees, err := complexity.CalculateFeeData(ctx, records, cfg, o.denom.unit)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		gas    = complexity.PullGasFromRecords(records, cfg.Weights)
+		target = complexity.TargetComplexityTrace(records, slices.Max(gas), uint64(cfg.TargetGasPerSecond), o.sameTime)
+		x      = buildXAxis(complexity.PullTimesHeightsFromRecords(records), o.xAxisMode, o.maxXGap)
+		feeY   = make([]float64, len(fees))
+		prices = make([]float64, len(fees))
+		excess = make([]float64, len(fees))
+	)
+	for i, f := range fees {
+		feeY[i] = f.Fee
+		prices[i] = float64(f.GasPrice)
+		excess[i] = float64(f.ExcessGas)
+	}
+
+	buckets := dashboardBuckets(len(records))
+	sampled := xAxis{label: x.label, values: make([]float64, len(buckets))}
+	for i, start := range buckets {
+		sampled.values[i] = x.values[start]
+	}
+	line := func(name string, y []float64) htmlTrace {
+		return htmlLine(name, sampled, bucketMax(y, buckets), nil)
+	}
+	return []htmlChart{
+		{
+			ID:     "gas",
+			Title:  "total gas vs target",
+			XLabel: sampled.label,
+			YLabel: "gas",
+			Traces: []htmlTrace{
+				line("gas", uint64sToFloat64s(gas)),
+				line("target", uint64sToFloat64s(target)),
+			},
+		},
+		{
+			ID:          "fees",
+			Title:       "fees",
+			XLabel:      sampled.label,
+			YLabel:      "fee (" + o.denom.label + ")",
+			YTickFormat: o.denom.tickFormat(),
+			Traces:      []htmlTrace{line("fee", feeY)},
+		},
+		{
+			ID:     "gas_price",
+			Title:  "gas price",
+			XLabel: sampled.label,
+			YLabel: "gas price",
+			Traces: []htmlTrace{line("gas price", prices)},
+		},
+		{
+			ID:     "excess_gas",
+			Title:  "excess gas",
+			XLabel: sampled.label,
+			YLabel: "excess gas",
+			Traces: []htmlTrace{line("excess gas", excess)},
+		},
+	}, nil
+}
+
+// dashboardBuckets splits [n] points into at most [dashboardMaxPoints] buckets
+// of contiguous points, returning the index each bucket starts at
+func dashboardBuckets(n int) []int {
+	size := max(1, (n+dashboardMaxPoints-1)/dashboardMaxPoints)
+	res := make([]int, 0, (n+size-1)/size)
+	for i := 0; i < n; i += size {
+		res = append(res, i)
+	}
+	return res
+}
+
+// bucketMax returns the highest value of [trace] within each of [buckets],
+// as returned by dashboardBuckets
+func bucketMax(trace []float64, buckets []int) []float64 {
+	res := make([]float64, len(buckets))
+	for i, start := range buckets {
+		end := len(trace)
+		if i+1 < len(buckets) {
+			end = buckets[i+1]
+		}
+		res[i] = slices.Max(trace[start:end])
+	}
+	return res
+}
+
+func uint64sToFloat64s(trace []uint64) []float64 {
+	res := make([]float64, len(trace))
+	for i, v := range trace {
+		res[i] = float64(v)
+	}
+	return res
+}
+
+// dashboardWeightKeys lists the form keys of dimension weights, in dimension order
+func dashboardWeightKeys() []string {
+	res := make([]string, 0, commonfee.NumDimensions)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		res = append(res, "weight_"+snakeCase(complexity.DimensionStrings[d]))
+	}
+	return res
+}
+
+var dashboardPage = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"weightKeys": dashboardWeightKeys,
+	"title":      func(key string) string { return strings.ReplaceAll(key, "_", " ") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>complexities dashboard</title>
+<script src="https://cdn.plot.ly/plotly-2.35.2.min.js"></script>
+<style>
+div.chart { height: 420px; }
+form { display: flex; flex-wrap: wrap; gap: 12px; align-items: end; }
+label { display: flex; flex-direction: column; font-size: small; }
+#error { color: darkred; }
+</style>
+</head>
+<body>
+<h1>complexities dashboard</h1>
+<form id="upload" action="/upload" method="post" enctype="multipart/form-data">
+<label>upload dataset<input type="file" name="dataset" accept=".csv,.gz,.zst"></label>
+<button type="submit">upload</button>
+</form>
+<form id="config">
+<label>dataset<select name="dataset">{{range .Datasets}}<option{{if eq . $.Selected}} selected{{end}}>{{.}}</option>{{end}}</select></label>
+<label>min gas price<input type="number" min="0" name="min_gas_price" value="{{.Config.MinGasPrice}}"></label>
+<label>update denominator<input type="number" min="1" name="update_denominator" value="{{.Config.ExcessConversionConstant}}"></label>
+<label>gas target rate<input type="number" min="1" name="gas_target_rate" value="{{.Config.TargetGasPerSecond}}"></label>
+<label>max gas per second<input type="number" min="0" name="max_gas_per_second" value="{{.Config.MaxGasPerSecond}}"></label>
+{{range $i, $key := weightKeys}}<label>{{title $key}}<input type="number" min="0" name="{{$key}}" value="{{index $.Config.Weights $i}}"></label>
+{{end}}</form>
+<p>fees in {{.Denom}}</p>
+<p id="error"></p>
+<div class="chart" id="gas"></div>
+<div class="chart" id="fees"></div>
+<div class="chart" id="gas_price"></div>
+<div class="chart" id="excess_gas"></div>
+<script>
+const form = document.getElementById("config");
+const errorBox = document.getElementById("error");
+let pending;
+
+async function render() {
+	const resp = await fetch("/charts?" + new URLSearchParams(new FormData(form)));
+	if (!resp.ok) {
+		errorBox.textContent = await resp.text();
+		return;
+	}
+	errorBox.textContent = "";
+	for (const c of await resp.json()) {
+		Plotly.react(c.id, c.traces, {
+			title: c.title,
+			xaxis: {title: c.xLabel},
+			yaxis: {title: c.yLabel, type: c.logY ? "log" : "linear", tickformat: c.yTickFormat},
+			dragmode: "pan",
+		}, {scrollZoom: true, responsive: true});
+	}
+}
+
+form.addEventListener("input", () => {
+	clearTimeout(pending);
+	pending = setTimeout(render, 300);
+});
+render();
+</script>
+</body>
+</html>
+`))
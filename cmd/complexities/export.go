@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"process_data/pkg/complexity"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// writeFeeData writes per block fee data as JSON if [path] has a .json
+// extension, as Parquet if it has a .parquet one, as CSV otherwise. Blocks
+// found in [blockIDs], keyed by height, are linked to [explorerURL] unless it is empty.
+func writeFeeData(path string, data []complexity.FeeData, denom denomination, blockIDs map[uint64]ids.ID, explorerURL string) error {
+	entries := make([]feeDataEntry, 0, len(data))
+	for _, d := range data {
+		e := feeDataEntry{FeeData: d}
+		if id, ok := blockIDs[d.Height]; ok {
+			e.Explorer = blockURL(explorerURL, id)
+		}
+		entries = append(entries, e)
+	}
+
+	if strings.EqualFold(contentExt(path), ".json") {
+		return writeJSON(path, feeDataFile{
+			Denomination: denom.name,
+			Blocks:       entries,
+		})
+	}
+	if isParquet(path) {
+		return writeFeeParquet(path, entries, denom)
+	}
+	return writeFeeCSV(path, entries, denom, explorerURL != "")
+}
+
+// feeDataFile is the JSON form of per block fee data
+type feeDataFile struct {
+	Denomination string         `json:"denomination"`
+	Blocks       []feeDataEntry `json:"blocks"`
+}
+
+type feeDataEntry struct {
+	complexity.FeeData
+	Explorer string `json:"explorer,omitempty"`
+}
+
+// writeFeeCSV writes one row per block, preceded by a header.
+// fee is expressed in [denom], as stored in complexity.FeeData.
+// Explorer links are written as last column if [links].
+func writeFeeCSV(path string, data []feeDataEntry, denom denomination, links bool) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height", "time", "gasPrice", "excessGas", "fee_" + denom.name}
+	if links {
+		header = append(header, "explorer")
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, d := range data {
+		row := []string{
+			strconv.FormatUint(d.Height, 10),
+			strconv.FormatUint(d.Time, 10),
+			strconv.FormatUint(uint64(d.GasPrice), 10),
+			strconv.FormatUint(uint64(d.ExcessGas), 10),
+			denom.format(d.Fee),
+		}
+		if links {
+			row = append(row, d.Explorer)
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", d.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// peakEntry is the exported form of a peak, ranked within its trace
+type peakEntry struct {
+	Trace               string   `json:"trace"`
+	Rank                int      `json:"rank"`
+	StartHeight         uint64   `json:"start_height"`
+	EndHeight           uint64   `json:"end_height"`
+	StartTime           uint64   `json:"start_time"`
+	EndTime             uint64   `json:"end_time"`
+	Duration            uint64   `json:"duration"`
+	Blocks              int      `json:"blocks"`
+	CumulatedComplexity uint64   `json:"cumulated_complexity"`
+	Power               float64  `json:"power"`
+	Explorer            string   `json:"explorer,omitempty"` // first block page
+	BlockIDs            []ids.ID `json:"block_ids"`
+}
+
+// peakEntries ranks [peaks] of trace [name], top peak first, linking their first block
+// to [explorerURL] unless it is empty.
+// [peaks] is expected sorted increasingly, as returned by peak detection.
+func peakEntries(name string, peaks []complexity.Peak, explorerURL string) []peakEntry {
+	res := make([]peakEntry, 0, len(peaks))
+	for i, p := range topPeaksFirst(peaks) {
+		res = append(res, peakEntry{
+			Trace:               name,
+			Rank:                i + 1,
+			StartHeight:         p.StartHeight,
+			EndHeight:           p.StartHeight + uint64(p.BlocksCount) - 1,
+			StartTime:           p.LowTimestamp,
+			EndTime:             p.UpTimestamp,
+			Duration:            p.ElapsedTime,
+			Blocks:              p.BlocksCount,
+			CumulatedComplexity: p.CumulatedComplexity,
+			Power:               p.Power(),
+			Explorer:            peakURL(explorerURL, p),
+			BlockIDs:            p.BlockIDs,
+		})
+	}
+	return res
+}
+
+// peakURL returns the explorer page of the first block of [p]
+func peakURL(explorerURL string, p complexity.Peak) string {
+	if len(p.BlockIDs) == 0 {
+		return ""
+	}
+	return blockURL(explorerURL, p.BlockIDs[0])
+}
+
+// writePeaks writes [entries] as JSON if [path] has a .json extension,
+// as CSV otherwise. Block IDs are left out of CSV files.
+func writePeaks(path string, entries []peakEntry) error {
+	if strings.EqualFold(contentExt(path), ".json") {
+		return writeJSON(path, entries)
+	}
+
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"trace", "rank", "start_height", "end_height", "start_time", "end_time", "duration", "blocks", "cumulated_complexity", "power", "explorer"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, e := range entries {
+		row := []string{
+			e.Trace,
+			strconv.Itoa(e.Rank),
+			strconv.FormatUint(e.StartHeight, 10),
+			strconv.FormatUint(e.EndHeight, 10),
+			strconv.FormatUint(e.StartTime, 10),
+			strconv.FormatUint(e.EndTime, 10),
+			strconv.FormatUint(e.Duration, 10),
+			strconv.Itoa(e.Blocks),
+			strconv.FormatUint(e.CumulatedComplexity, 10),
+			strconv.FormatFloat(e.Power, 'g', -1, 64),
+			e.Explorer,
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing peak at height %d to %s: %w", e.StartHeight, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func peaksByDimension(peaks [][]complexity.Peak) map[string][]complexity.Peak {
+	res := make(map[string][]complexity.Peak, len(peaks))
+	for d, dimensionPeaks := range peaks {
+		res[complexity.DimensionStrings[d]] = topPeaksFirst(dimensionPeaks)
+	}
+	return res
+}
+
+// topPeaksFirst reverts the increasing order of [peaks], as returned by peak detection
+func topPeaksFirst(peaks []complexity.Peak) []complexity.Peak {
+	res := slices.Clone(peaks)
+	slices.Reverse(res)
+	return res
+}
+
+func dimensionsByName(d commonfee.Dimensions) map[string]uint64 {
+	res := make(map[string]uint64, len(d))
+	for i, v := range d {
+		res[complexity.DimensionStrings[i]] = v
+	}
+	return res
+}
+
+// writeFeeVerificationCSV writes one row per verified block, preceded by a header.
+// Fees are expressed in [denom].
+func writeFeeVerificationCSV(path string, v complexity.FeeVerification, denom denomination) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height", "time", "computed_" + denom.name, "observed_" + denom.name, "absDiff", "relDiff"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, d := range v.Diffs {
+		row := []string{
+			strconv.FormatUint(d.Height, 10),
+			strconv.FormatUint(d.Time, 10),
+			denom.format(d.Computed),
+			denom.format(d.Observed),
+			denom.format(d.AbsDiff),
+			strconv.FormatFloat(d.RelDiff, 'g', -1, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", d.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Report aggregates the results of a whole run.
+// Results of stages a subcommand does not run are omitted.
+type Report struct {
+	TargetBlockDelay     uint64                           `json:"target_block_delay,omitempty"`
+	TargetComplexityRate map[string]uint64                `json:"target_complexity_rate,omitempty"`
+	TargetsByQuantile    []QuantileReport                 `json:"targets_by_quantile,omitempty"`
+	MaxComplexities      map[string]uint64                `json:"max_complexities,omitempty"`
+	TopBlocks            map[string][]complexity.TopBlock `json:"top_blocks,omitempty"`
+	TopPeaks             map[string][]complexity.Peak     `json:"top_peaks,omitempty"`
+
+	// TopPeakOverlaps holds, for each of TopPeaks, how much every dimension
+	// exceeded its target rate over the peak blocks
+	TopPeakOverlaps map[string][]map[string]complexity.DimensionOverlap `json:"top_peak_overlaps,omitempty"`
+
+	TopTotalGasPeaks []complexity.Peak          `json:"top_total_gas_peaks,omitempty"`
+	Fees             []FeeReport                `json:"fees,omitempty"`
+	Verification     *VerificationReport        `json:"verification,omitempty"`
+	PenaltyPeriods   []complexity.PenaltyPeriod `json:"penalty_periods,omitempty"`
+
+	// FeeTrace holds per block fee data computed with the first fee config,
+	// in the denomination of Fees
+	FeeTrace []complexity.FeeData `json:"fee_trace,omitempty"`
+}
+
+// QuantileReport holds the targets found at a given quantile of historical data
+type QuantileReport struct {
+	Quantile             float64           `json:"quantile"`
+	BlockDelay           uint64            `json:"block_delay"`
+	TargetComplexityRate map[string]uint64 `json:"target_complexity_rate"`
+}
+
+// FeeReport summarizes fees computed over the analyzed window with a fee config
+type FeeReport struct {
+	Config       string  `json:"config"`
+	Denomination string  `json:"denomination"`
+	MaxFee       float64 `json:"max_fee"`
+	TotalFees    float64 `json:"total_fees"`
+	MeanFee      float64 `json:"mean_fee"`
+
+	// InitialExcessGas is the excess gas the replay started from, see -initial-excess
+	InitialExcessGas uint64 `json:"initial_excess_gas,omitempty"`
+}
+
+// VerificationReport compares fees computed with a fee config over the
+// whole dataset with those observed on chain
+type VerificationReport struct {
+	Config        string  `json:"config"`
+	Denomination  string  `json:"denomination"`
+	Blocks        int     `json:"blocks"`
+	RMSE          float64 `json:"rmse"`
+	ComputedTotal float64 `json:"computed_total"`
+	ObservedTotal float64 `json:"observed_total"`
+	RevenueDelta  float64 `json:"revenue_delta"`
+}
+
+func writeReport(path string, r Report) error {
+	return writeJSON(path, r)
+}
+
+// printJSON writes [v] to stdout, indented as files written by writeJSON
+func printJSON(v any) error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling output: %w", err)
+	}
+	if _, err := fmt.Printf("%s\n", fixedNotation(b)); err != nil {
+		return fmt.Errorf("failed writing output: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return fmt.Errorf("failed marshalling %s content: %w", path, err)
+	}
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(fixedNotation(b)); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// writeUtilizationCSV writes one row per record with its height, time and
+// utilization percentage per dimension. [utilizations] is indexed by dimension.
+func writeUtilizationCSV(path string, records []complexity.Record, utilizations [][]float64) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height", "time"}
+	for _, d := range complexity.DimensionStrings {
+		header = append(header, d)
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for i, r := range records {
+		row := []string{
+			strconv.FormatUint(r.Height, 10),
+			strconv.FormatUint(r.Time, 10),
+		}
+		for _, u := range utilizations {
+			row = append(row, strconv.FormatFloat(u[i], 'g', -1, 64))
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", r.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// writeThrottlingCSV writes one row per range of consecutive throttled blocks
+func writeThrottlingCSV(path string, ranges []complexity.ThrottledRange) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"from_height", "to_height", "excess_gas"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, r := range ranges {
+		row := []string{
+			strconv.FormatUint(r.From, 10),
+			strconv.FormatUint(r.To, 10),
+			strconv.FormatUint(r.ExcessGas, 10),
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", r.From, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// writeOutliersCSV writes one row per implausible record with the reason it was flagged,
+// preceded by a header
+func writeOutliersCSV(path string, outliers []complexity.Outlier) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"height", "time", "reason", "detail"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, o := range outliers {
+		row := []string{
+			strconv.FormatUint(o.Height, 10),
+			strconv.FormatUint(o.Time, 10),
+			o.Reason,
+			o.Detail,
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", o.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
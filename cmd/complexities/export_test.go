@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+func TestWriteFeeCSVRoundTrip(t *testing.T) {
+	denom, err := getDenomination("nanoavax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	denom.precision = -1 // as -fee-precision defaults to
+	data := []complexity.FeeData{
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 100, Time: 1_700_000_000}, GasPrice: 10, ExcessGas: 0, Fee: 4_820},
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 101, Time: 1_700_000_002}, GasPrice: 12, ExcessGas: 35_000, Fee: 6_516},
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 102, Time: 1_700_000_002}, GasPrice: 15, ExcessGas: 71_250, Fee: 123_456.5},
+	}
+
+	path := filepath.Join(t.TempDir(), "fees.csv")
+	if err := writeFeeData(path, data, denom, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := []string{"height", "time", "gasPrice", "excessGas", "fee_nanoavax"}
+	if !slices.Equal(rows[0], header) {
+		t.Fatalf("expected header %v, got %v", header, rows[0])
+	}
+	if len(rows)-1 != len(data) {
+		t.Fatalf("expected %d rows, got %d", len(data), len(rows)-1)
+	}
+	for i, row := range rows[1:] {
+		var (
+			parsed = complexity.FeeData{}
+			values = make([]uint64, 4)
+		)
+		for j := range values {
+			if values[j], err = strconv.ParseUint(row[j], 10, 64); err != nil {
+				t.Fatalf("row %d: %v", i, err)
+			}
+		}
+		parsed.Height, parsed.Time = values[0], values[1]
+		parsed.GasPrice, parsed.ExcessGas = commonfee.GasPrice(values[2]), commonfee.Gas(values[3])
+		if parsed.Fee, err = strconv.ParseFloat(row[4], 64); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+		if parsed != data[i] {
+			t.Fatalf("row %d: expected %+v, got %+v", i, data[i], parsed)
+		}
+	}
+}
+
+func TestWriteFeeCSVExplorerLinks(t *testing.T) {
+	denom, err := getDenomination("avax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		explorerURL = chains[0].explorerURL
+		linked      = ids.GenerateTestID()
+		data        = []complexity.FeeData{
+			{BlkHeightTime: complexity.BlkHeightTime{Height: 100, Time: 1_700_000_000}, GasPrice: 10},
+			{BlkHeightTime: complexity.BlkHeightTime{Height: 101, Time: 1_700_000_001}, GasPrice: 10},
+		}
+	)
+
+	path := filepath.Join(t.TempDir(), "fees.csv")
+	if err := writeFeeData(path, data, denom, map[uint64]ids.ID{101: linked}, explorerURL); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows[0][len(rows[0])-1] != "explorer" {
+		t.Fatalf("expected explorer as last column, got header %v", rows[0])
+	}
+	if rows[1][5] != "" || rows[2][5] != blockURL(explorerURL, linked) {
+		t.Fatalf("expected only height 101 linked, got %q and %q", rows[1][5], rows[2][5])
+	}
+}
+
+// jsonKeys returns the sorted keys of the JSON object [b]
+func jsonKeys(t *testing.T, b []byte) []string {
+	t.Helper()
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	res := make([]string, 0, len(fields))
+	for k := range fields {
+		res = append(res, k)
+	}
+	slices.Sort(res)
+	return res
+}
+
+func TestWritePeaksJSONShape(t *testing.T) {
+	var (
+		explorerURL = chains[0].explorerURL
+		// peaks sorted increasingly, as returned by peak detection
+		bandwidth = []complexity.Peak{
+			{StartHeight: 200, BlocksCount: 2, LowTimestamp: 1_000, UpTimestamp: 1_002, ElapsedTime: 4, CumulatedComplexity: 400, BlockIDs: []ids.ID{ids.GenerateTestID(), ids.GenerateTestID()}},
+			{StartHeight: 100, BlocksCount: 3, LowTimestamp: 500, UpTimestamp: 504, ElapsedTime: 5, CumulatedComplexity: 900, BlockIDs: []ids.ID{ids.GenerateTestID(), ids.GenerateTestID(), ids.GenerateTestID()}},
+		}
+		gas = []complexity.Peak{
+			{StartHeight: 150, BlocksCount: 1, LowTimestamp: 700, UpTimestamp: 700, ElapsedTime: 1, CumulatedComplexity: 50, BlockIDs: []ids.ID{ids.GenerateTestID()}},
+		}
+		entries = append(peakEntries(complexity.DimensionStrings[0], bandwidth, explorerURL), peakEntries(totalGasName, gas, explorerURL)...)
+	)
+
+	path := filepath.Join(t.TempDir(), "peaks.json")
+	if err := writePeaks(path, entries); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal(b, &objects); err != nil {
+		t.Fatalf("expected a JSON array of peaks: %v", err)
+	}
+	if len(objects) != 3 {
+		t.Fatalf("expected 3 peaks, got %d", len(objects))
+	}
+	keys := []string{"block_ids", "blocks", "cumulated_complexity", "duration", "end_height", "end_time", "explorer", "power", "rank", "start_height", "start_time", "trace"}
+	for i, o := range objects {
+		if got := jsonKeys(t, o); !slices.Equal(got, keys) {
+			t.Fatalf("peak %d: expected keys %v, got %v", i, keys, got)
+		}
+	}
+
+	var decoded []peakEntry
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	top := decoded[0]
+	if top.Trace != complexity.DimensionStrings[0] || top.Rank != 1 || top.StartHeight != 100 || top.EndHeight != 102 {
+		t.Fatalf("expected top %s peak from height 100 to 102 first, got %+v", complexity.DimensionStrings[0], top)
+	}
+	if top.Power != 180 || top.Explorer != blockURL(explorerURL, bandwidth[1].BlockIDs[0]) || !slices.Equal(top.BlockIDs, bandwidth[1].BlockIDs) {
+		t.Fatalf("unexpected top peak %+v", top)
+	}
+	if decoded[1].Rank != 2 || decoded[2].Trace != totalGasName || decoded[2].Rank != 1 {
+		t.Fatalf("expected peaks ranked within their trace, got %+v", decoded)
+	}
+}
+
+func TestPeaksByDimensionShape(t *testing.T) {
+	peaks := make([][]complexity.Peak, commonfee.NumDimensions)
+	for d := range peaks {
+		// sorted increasingly, as returned by findAllDimensionPeaks
+		peaks[d] = []complexity.Peak{
+			{StartHeight: 1, BlocksCount: 1, CumulatedComplexity: 10},
+			{StartHeight: 5, BlocksCount: 2, CumulatedComplexity: 20},
+		}
+	}
+
+	b, err := json.Marshal(peaksByDimension(peaks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := slices.Clone(complexity.DimensionStrings[:commonfee.NumDimensions])
+	slices.Sort(expected)
+	if got := jsonKeys(t, b); !slices.Equal(got, expected) {
+		t.Fatalf("expected dimensions %v, got %v", expected, got)
+	}
+
+	var byDimension map[string][]json.RawMessage
+	if err := json.Unmarshal(b, &byDimension); err != nil {
+		t.Fatal(err)
+	}
+	peakKeys := []string{"block_ids", "cumulated_complexity", "end_time", "peak_duration", "peak_width", "start_height", "start_time"}
+	for name, dimensionPeaks := range byDimension {
+		if got := jsonKeys(t, dimensionPeaks[0]); !slices.Equal(got, peakKeys) {
+			t.Fatalf("%s: expected peak keys %v, got %v", name, peakKeys, got)
+		}
+		var top complexity.Peak
+		if err := json.Unmarshal(dimensionPeaks[0], &top); err != nil {
+			t.Fatal(err)
+		}
+		if top.StartHeight != 5 {
+			t.Fatalf("%s: expected top peak first, got %+v", name, top)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/ava-labs/avalanchego/utils/units"
+	"gonum.org/v1/plot"
+)
+
+// format prints fee [f] in fixed notation with the precision of [d],
+// so that fees read the same whatever their magnitude and locale
+func (d denomination) format(f float64) string {
+	return strconv.FormatFloat(f, 'f', d.precision, 64)
+}
+
+// decimals returns the number of decimals fees are rounded to on plot axes:
+// the precision of [d] if set, the decimals of 1 nAvax in [d] otherwise
+func (d denomination) decimals() int {
+	if d.precision >= 0 {
+		return d.precision
+	}
+	return int(math.Round(math.Log10(float64(d.unit) / float64(units.NanoAvax))))
+}
+
+// formatTick prints fee [f] as a plot axis label. Trailing zeros are trimmed
+// unless precision is set, so that labels keep to the digits needed.
+func (d denomination) formatTick(f float64) string {
+	s := strconv.FormatFloat(f, 'f', d.decimals(), 64)
+	if d.precision < 0 && strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+// tickFormat returns the d3 format plotly labels fee axes with, matching formatTick
+func (d denomination) tickFormat() string {
+	if d.precision < 0 {
+		return "." + strconv.Itoa(d.decimals()) + "~f"
+	}
+	return "." + strconv.Itoa(d.precision) + "f"
+}
+
+// feeTicks places ticks as plot.DefaultTicks does, labeling them in fixed notation
+type feeTicks struct {
+	denom denomination
+}
+
+func (t feeTicks) Ticks(min, max float64) []plot.Tick {
+	ticks := plot.DefaultTicks{}.Ticks(min, max)
+	for i := range ticks {
+		if ticks[i].Label != "" {
+			ticks[i].Label = t.denom.formatTick(ticks[i].Value)
+		}
+	}
+	return ticks
+}
+
+// fixedNotation rewrites numbers of JSON document [b] printed in exponent form,
+// e.g. 1e-8, in fixed notation, leaving strings untouched
+func fixedNotation(b []byte) []byte {
+	var (
+		res      = make([]byte, 0, len(b))
+		inString bool
+	)
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case inString:
+			res = append(res, c)
+			if c == '\\' && i+1 < len(b) {
+				i++
+				res = append(res, b[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			res = append(res, c)
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+			for end < len(b) && bytes.IndexByte([]byte("0123456789.eE+-"), b[end]) >= 0 {
+				end++
+			}
+			number := b[i:end]
+			if bytes.ContainsAny(number, "eE") {
+				if f, err := strconv.ParseFloat(string(number), 64); err == nil {
+					number = strconv.AppendFloat(nil, f, 'f', -1, 64)
+				}
+			}
+			res = append(res, number...)
+			i = end - 1
+		default:
+			res = append(res, c)
+		}
+	}
+	return res
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// distributionPlot describes one distribution to be plotted, cut at the target quantile
+type distributionPlot struct {
+	name   string // file name, without log suffix
+	title  string
+	xLabel string
+	values []float64
+	cutoff float64
+}
+
+// runHistogram plots, for each dimension, the distributions of per block complexity
+// and of complexity rate on linear and log scale, marking values at the target quantile
+func runHistogram(ctx context.Context, o *options) {
+	out, err := newPlotOutput(o.outDir, o.plotFormat)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := loadRecords(ctx, o)
+	dists, err := complexity.ComplexityDistributions(a.derived, o.chain.minHeight, o.quantile)
+	if err != nil {
+		fatal(err)
+	}
+
+	plots := make([]distributionPlot, 0, 2*commonfee.NumDimensions)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		var (
+			name = complexity.DimensionStrings[d]
+			dist = dists[d]
+		)
+		complexities := make([]float64, len(dist.Complexities))
+		for i, c := range dist.Complexities {
+			complexities[i] = float64(c)
+		}
+		plots = append(plots,
+			distributionPlot{
+				name:   "dist_complexity_" + snakeCase(name),
+				title:  "Block complexity distribution, " + name,
+				xLabel: "complexity",
+				values: complexities,
+				cutoff: float64(dist.ComplexityCutoff),
+			},
+			distributionPlot{
+				name:   "dist_rate_" + snakeCase(name),
+				title:  "Complexity rate distribution, " + name,
+				xLabel: "complexity per second",
+				values: dist.Rates,
+				cutoff: float64(dist.RateCutoff),
+			},
+		)
+		fmt.Fprintf(a.stdout, "%s p%v: block complexity %d, complexity rate %d\n", name, 100*o.quantile, dist.ComplexityCutoff, dist.RateCutoff)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+
+	if out.format == htmlFormat {
+		a.tolerate(printHTMLDistributions(out, plots, o.bins, o.quantile))
+		return
+	}
+	for _, p := range plots {
+		for _, logY := range []bool{false, true} {
+			a.tolerate(printDistributionImage(out, p, o.bins, o.quantile, logY))
+		}
+	}
+}
+
+// printDistributionImage plots the histogram of [dist] split into [bins] buckets,
+// with a vertical line at its [quantile] cutoff. Counts are log-scaled if [logY],
+// in which case the file name is suffixed with _log.
+func printDistributionImage(out plotOutput, dist distributionPlot, bins int, quantile float64, logY bool) error {
+	if bins <= 0 {
+		return fmt.Errorf("bins must be positive, got %d", bins)
+	}
+
+	h, err := plotter.NewHist(plotter.Values(dist.values), bins)
+	if err != nil {
+		return fmt.Errorf("failed building %s histogram: %w", dist.name, err)
+	}
+	h.LogY = logY
+
+	maxCount := float64(1)
+	for _, b := range h.Bins {
+		maxCount = max(maxCount, b.Weight)
+	}
+	cutoff, err := plotter.NewLine(plotter.XYs{{X: dist.cutoff, Y: 1}, {X: dist.cutoff, Y: maxCount}})
+	if err != nil {
+		return fmt.Errorf("failed building %s cutoff: %w", dist.name, err)
+	}
+	cutoff.Dashes = []vg.Length{vg.Points(4), vg.Points(4)}
+
+	p := plot.New()
+	p.Title.Text = dist.title
+	p.X.Label.Text = dist.xLabel
+	p.Y.Label.Text = "blocks count"
+	p.Add(h, cutoff)
+	p.Legend.Add(fmt.Sprintf("p%v", 100*quantile), cutoff)
+	p.Legend.Top = true
+
+	name := dist.name
+	if logY {
+		p.Y.Scale = plot.LogScale{}
+		p.Y.Tick.Marker = plot.LogTicks{}
+		name += "_log"
+	}
+
+	path := out.path(name)
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printHTMLDistributions renders each of [dists] on linear and log scale
+// into distributions file
+func printHTMLDistributions(out plotOutput, dists []distributionPlot, bins int, quantile float64) error {
+	charts := make([]htmlChart, 0, 2*len(dists))
+	for _, dist := range dists {
+		for _, logY := range []bool{false, true} {
+			chart := htmlChart{
+				ID:     dist.name,
+				Title:  fmt.Sprintf("%s, p%v at %v", dist.title, 100*quantile, dist.cutoff),
+				XLabel: dist.xLabel,
+				YLabel: "blocks",
+				Traces: []htmlTrace{{
+					Name:   dist.xLabel,
+					Type:   "histogram",
+					X:      dist.values,
+					NBinsX: bins,
+				}},
+				LogY:    logY,
+				Markers: []float64{dist.cutoff},
+			}
+			if logY {
+				chart.ID += "_log"
+			}
+			charts = append(charts, chart)
+		}
+	}
+	return writeHTMLCharts(out, "distributions", "complexity distributions", charts)
+}
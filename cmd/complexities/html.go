@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// htmlFormat renders plots as interactive plotly charts, all gathered
+// in a single page, rather than as one static image per plot
+const htmlFormat = "html"
+
+// htmlChart is a plotly chart. Its traces are serialized as plotly expects them.
+type htmlChart struct {
+	ID     string      `json:"id"`
+	Title  string      `json:"title"`
+	XLabel string      `json:"xLabel"`
+	YLabel string      `json:"yLabel"`
+	Traces []htmlTrace `json:"traces"`
+
+	// LogY log-scales the y axis
+	LogY bool `json:"logY,omitempty"`
+
+	// YTickFormat is the d3 format y axis ticks are labeled with, plotly picks one if unset
+	YTickFormat string `json:"yTickFormat,omitempty"`
+
+	// Markers are x values marked by vertical lines
+	Markers []float64 `json:"markers,omitempty"`
+}
+
+type htmlTrace struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Mode   string   `json:"mode,omitempty"`
+	X      any      `json:"x,omitempty"`
+	Y      any      `json:"y,omitempty"`
+	Text   []string `json:"text,omitempty"`
+	NBinsX int      `json:"nbinsx,omitempty"`
+}
+
+// htmlLine returns a line trace of [y] along [x]. Hovering a point shows [hover],
+// which is indexed as [y].
+func htmlLine(name string, x xAxis, y any, hover []string) htmlTrace {
+	return htmlTrace{
+		Name: name,
+		Type: "scattergl",
+		Mode: "lines",
+		X:    x.values,
+		Y:    y,
+		Text: hover,
+	}
+}
+
+// blockHover returns, for each of [records], the text shown when hovering it
+func blockHover(records []complexity.Record) []string {
+	res := make([]string, len(records))
+	for i, r := range records {
+		res[i] = fmt.Sprintf("height %d<br>time %d<br>block %s", r.Height, r.Time, r.ID)
+	}
+	return res
+}
+
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<script src="https://cdn.plot.ly/plotly-2.35.2.min.js"></script>
+<style>div.chart { height: 480px; }</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Charts}}<div class="chart" id="{{.ID}}"></div>
+{{end}}<script>
+const charts = {{.Charts}};
+for (const c of charts) {
+	const traces = c.traces.map(t => Object.assign({hovertemplate: t.text ? "%{text}<br>%{y}<extra>" + t.name + "</extra>" : undefined}, t));
+	Plotly.newPlot(c.id, traces, {
+		title: c.title,
+		xaxis: {title: c.xLabel},
+		yaxis: {title: c.yLabel, type: c.logY ? "log" : "linear", tickformat: c.yTickFormat},
+		shapes: (c.markers || []).map(x => ({type: "line", x0: x, x1: x, yref: "paper", y0: 0, y1: 1, line: {dash: "dash"}})),
+		dragmode: "pan",
+	}, {scrollZoom: true, responsive: true});
+}
+</script>
+</body>
+</html>
+`))
+
+// writeHTMLCharts renders [charts] into a single page named [name] in [out] dir
+func writeHTMLCharts(out plotOutput, name, title string, charts []htmlChart) error {
+	path := out.path(name)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	data := struct {
+		Title  string
+		Charts []htmlChart
+	}{
+		Title:  title,
+		Charts: charts,
+	}
+	if err := htmlPage.Execute(f, data); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// printHTMLHistograms renders the distribution of complexities of each dimension
+// into histograms file
+func printHTMLHistograms(out plotOutput, records []complexity.Record, bins int) error {
+	charts := make([]htmlChart, 0, commonfee.NumDimensions)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		name := complexity.DimensionStrings[d]
+		charts = append(charts, htmlChart{
+			ID:     "hist_" + snakeCase(name),
+			Title:  name + " complexity distribution",
+			XLabel: "complexity",
+			YLabel: "blocks",
+			Traces: []htmlTrace{{
+				Name:   name,
+				Type:   "histogram",
+				X:      complexity.PullComplexityFromRecords(records, d),
+				NBinsX: bins,
+			}},
+		})
+	}
+	return writeHTMLCharts(out, "histograms", "complexity histograms", charts)
+}
+
+// printHTMLCharts renders consumed vs target complexity of each dimension and
+// of total gas, along with fees and gas price, into charts file.
+// Assumes [targets] is indexed by dimension.
+func printHTMLCharts(
+	out plotOutput,
+	x xAxis,
+	r []complexity.Record,
+	targets [][]uint64,
+	totalGas, totalTarget []uint64,
+	traces []feeTrace,
+	denom denomination,
+) error {
+	var (
+		hover  = blockHover(r)
+		charts = make([]htmlChart, 0, commonfee.NumDimensions+3)
+	)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		name := complexity.DimensionStrings[d]
+		charts = append(charts, htmlChart{
+			ID:     "gas_" + snakeCase(name),
+			Title:  name,
+			XLabel: x.label,
+			YLabel: "complexity",
+			Traces: []htmlTrace{
+				htmlLine("consumed "+name, x, complexity.PullComplexityFromRecords(r, d), hover),
+				htmlLine("target "+name, x, targets[d], hover),
+			},
+		})
+	}
+	charts = append(charts, htmlChart{
+		ID:     "gas_" + snakeCase(totalGasName),
+		Title:  totalGasName,
+		XLabel: x.label,
+		YLabel: "gas",
+		Traces: []htmlTrace{
+			htmlLine("consumed "+totalGasName, x, totalGas, hover),
+			htmlLine("target "+totalGasName, x, totalTarget, hover),
+		},
+	})
+
+	charts = append(charts, feeCharts(x, hover, traces, denom)...)
+	return writeHTMLCharts(out, "charts", "complexities", charts)
+}
+
+// feeCharts returns the fee and gas price charts of all [traces],
+// one line per fee config, so that configs can be compared
+func feeCharts(x xAxis, hover []string, traces []feeTrace, denom denomination) []htmlChart {
+	fees := htmlChart{
+		ID:          "fee",
+		Title:       "fee",
+		XLabel:      x.label,
+		YLabel:      "fee (" + denom.label + ")",
+		YTickFormat: denom.tickFormat(),
+		Traces:      make([]htmlTrace, 0, len(traces)),
+	}
+	prices := htmlChart{
+		ID:     "price",
+		Title:  "gas price",
+		XLabel: x.label,
+		YLabel: "gas price (nAvax)",
+		Traces: make([]htmlTrace, 0, len(traces)),
+	}
+	for _, t := range traces {
+		fees.Traces = append(fees.Traces, htmlLine(t.name, x, t.fees, hover))
+		prices.Traces = append(prices.Traces, htmlLine(t.name, x, t.gasPrices, hover))
+	}
+	return []htmlChart{fees, prices}
+}
+
+// excessGasChart returns the excess gas chart of all [traces], one line per fee config
+func excessGasChart(x xAxis, traces []feeTrace) htmlChart {
+	res := htmlChart{
+		ID:     "excess_gas",
+		Title:  "excess gas",
+		XLabel: x.label,
+		YLabel: "excess gas",
+		Traces: make([]htmlTrace, 0, len(traces)),
+	}
+	for _, t := range traces {
+		res.Traces = append(res.Traces, htmlLine(t.name, x, t.excessGas, nil))
+	}
+	return res
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"process_data/pkg/complexity"
+)
+
+// runIngest stores records read from -csv or -rpc in the block store at -db-out,
+// so that later runs read them with -db instead of parsing input again.
+// Blocks already stored are replaced, so that ingesting again as blocks
+// are produced updates the store incrementally.
+func runIngest(ctx context.Context, o *options) {
+	if o.dbOutPath == "" {
+		fatal(fmt.Errorf("ingest needs a block store, set -db-out"))
+	}
+	store, err := openBlockStore(o.dbOutPath, true)
+	if err != nil {
+		fatal(err)
+	}
+	defer store.close()
+
+	records, err := ingestedRecords(ctx, o, store)
+	if errors.Is(err, errAboveTip) {
+		slog.Info("block store is up to date", "path", o.dbOutPath)
+		return
+	}
+	if err != nil {
+		fatal(err)
+	}
+	records = cleanRecords(records, o)
+	if err := store.upsert(ctx, records); err != nil {
+		fatal(fmt.Errorf("failed ingesting into %s: %w", o.dbOutPath, err))
+	}
+	latest, _, err := store.lastHeight()
+	if err != nil {
+		fatal(fmt.Errorf("failed reading %s: %w", o.dbOutPath, err))
+	}
+	slog.Info("ingested records", "path", o.dbOutPath, "records", len(records), "latest_height", latest)
+}
+
+// ingestedRecords reads the records to ingest into [store]. Fetching from a node
+// starts above the latest stored height, unless -min-height is set above it.
+func ingestedRecords(ctx context.Context, o *options, store *blockStore) ([]complexity.Record, error) {
+	if o.rpcURI == "" {
+		records, _ := readInput(ctx, o)
+		return records, nil
+	}
+	last, ok, err := store.lastHeight()
+	if err != nil {
+		return nil, fmt.Errorf("failed reading %s: %w", o.dbOutPath, err)
+	}
+	if ok && last+1 > o.minHeight {
+		o.minHeight = last + 1
+	}
+	return fetchRecords(ctx, o)
+}
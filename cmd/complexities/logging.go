@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+var logLevels = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+}
+
+// setupLogging routes diagnostics to stderr at [level], so that
+// analysis results printed on stdout stay machine readable.
+// Diagnostics are copied to [logFile] too, if not nil.
+func setupLogging(level string, logFile io.Writer) error {
+	l, ok := logLevels[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unsupported log level %q, supported values are error, warn, info, debug", level)
+	}
+	var w io.Writer = os.Stderr
+	if logFile != nil {
+		w = io.MultiWriter(os.Stderr, logFile)
+	}
+	handler := slog.NewTextHandler(w, &slog.HandlerOptions{Level: l})
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// fatal logs [err] and terminates the process
+func fatal(err error) {
+	slog.Error(err.Error())
+	os.Exit(1)
+}
+
+// Modes of handling recoverable errors, i.e. malformed input rows,
+// failing fee configs and failing plots
+const (
+	onErrorAbort = "abort"
+	onErrorWarn  = "warn"
+	onErrorSkip  = "skip"
+)
+
+var onErrorModes = []string{onErrorAbort, onErrorWarn, onErrorSkip}
+
+// handleError deals with the recoverable [err] according to [mode]: it either
+// terminates the process, or logs [err] and lets the caller go on.
+// Assumes [mode] is one of [onErrorModes]
+func handleError(mode string, err error) {
+	switch mode {
+	case onErrorWarn:
+		slog.Warn(err.Error())
+	case onErrorSkip:
+		slog.Debug(err.Error())
+	default:
+		fatal(err)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// totalGasName labels the weighted sum of all dimensions
+	// wherever it is handled as a pseudo-dimension
+	totalGasName = "Total"
+)
+
+// command is a subcommand, running the pipeline stages it needs
+// with the flag groups they depend on
+type command struct {
+	name        string
+	description string
+	flags       []func(*flag.FlagSet, *options)
+	run         func(context.Context, *options)
+}
+
+// defaultCommand runs when no subcommand is given, i.e. when the first argument
+// is a flag or there are none, so that flags alone run the whole analysis
+const defaultCommand = "analyze"
+
+var commands = []command{
+	{
+		name:        "analyze",
+		description: "run the whole analysis: stats, targets, peaks, fees and plots",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeFlags, addPlotFlags, addAnalyzeFlags, addWatchFlags, addAlertFlags},
+		run:         runAnalyze,
+	},
+	{
+		name:        "validate",
+		description: "check input files against the expected layout, report issues with line numbers and exit with a non-zero code if any",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addValidateFlags},
+		run:         runValidate,
+	},
+	{
+		name:        "peaks",
+		description: "find and print top complexity peaks per dimension",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeFlags},
+		run:         runPeaks,
+	},
+	{
+		name:        "fees",
+		description: "replay fee configs over the window of the selected peak and overlay their fees and gas prices",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeFlags, addFeesPlotFlags},
+		run:         runFees,
+	},
+	{
+		name:        "plot",
+		description: "plot complexities, utilization and fees over the window of the selected peak",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeFlags, addPlotFlags},
+		run:         runPlot,
+	},
+	{
+		name:        "tx-types",
+		description: "break complexities of the dataset and of top peaks down by tx type, read from a mapped tx_type column",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeConfigFlags},
+		run:         runTxTypes,
+	},
+	{
+		name:        "sweep",
+		description: "replay the whole dataset over ranges of fee parameters, summarize fees and keep configs meeting constraints",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addSweepFlags},
+		run:         runSweep,
+	},
+	{
+		name:        "ingest",
+		description: "ingest CSV files or blocks fetched from a node into a SQLite block store, which later runs read with -db",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addIngestFlags},
+		run:         runIngest,
+	},
+	{
+		name:        "convert",
+		description: "write input records, filtered, to a CSV or Parquet file",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addConvertFlags},
+		run:         runConvert,
+	},
+	{
+		name:        "sensitivity",
+		description: "scale each dimension weight in turn and report how fees and the top total gas peak change",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addSensitivityFlags},
+		run:         runSensitivity,
+	},
+	{
+		name:        "recommend",
+		description: "derive a fee config from target complexity rates, max block gas and the top total gas peak, explaining each value",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addPeakFlags, addFeeConfigFlags, addRecommendFlags},
+		run:         runRecommend,
+	},
+	{
+		name:        "capacity",
+		description: "report per block utilization of the gas budget granted by MaxGasPerSecond and how often blocks exceed it",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addCapacityFlags},
+		run:         runCapacity,
+	},
+	{
+		name:        "revenue",
+		description: "sum fees each fee config collects over the dataset and by day or week, split between min gas price and congestion",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addRevenueFlags},
+		run:         runRevenue,
+	},
+	{
+		name:        "simulate",
+		description: "replay fee configs over synthetic blocks sampled from the dataset, with bursts worse than history",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addSimulateFlags},
+		run:         runSimulate,
+	},
+	{
+		name:        "step-response",
+		description: "feed fee configs an idealized step, impulse or square wave of complexities and plot how gas price and excess gas respond",
+		flags:       []func(*flag.FlagSet, *options){addFeeConfigFlags, addStepResponseFlags},
+		run:         runStepResponse,
+	},
+	{
+		name:        "serve",
+		description: "tail blocks, e.g. from stdin, and expose the simulated fee market as Prometheus metrics",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addServeFlags, addAlertFlags},
+		run:         runServe,
+	},
+	{
+		name:        "dashboard",
+		description: "serve an interactive dashboard to pick or upload datasets and tweak fee configs, re-rendering gas, fee and excess gas charts",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addDashboardFlags},
+		run:         runDashboard,
+	},
+	{
+		name:        "rolling",
+		description: "compute sustained and spiky complexity rates over rolling windows",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addFeeConfigFlags, addPlotFlags, addRollingFlags},
+		run:         runRolling,
+	},
+	{
+		name:        "histogram",
+		description: "plot distributions of block complexity and complexity rate per dimension, cut at the target quantile",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addHistogramFlags},
+		run:         runHistogram,
+	},
+	{
+		name:        "correlation",
+		description: "report Pearson and Spearman correlations among dimensions of block complexities and rates, and plot each pair of dimensions",
+		flags:       []func(*flag.FlagSet, *options){addInputFlags, addTargetFlags, addCorrelationFlags},
+		run:         runCorrelation,
+	},
+	{
+		name:        "run-scenario",
+		description: "run the whole analysis as described by a scenario file and write a manifest of results",
+		flags:       []func(*flag.FlagSet, *options){addScenarioFlags},
+		run:         runScenario,
+	},
+	{
+		name:        "report",
+		description: "run a scenario and render its results and plots as a single Markdown or HTML report",
+		flags:       []func(*flag.FlagSet, *options){addScenarioFlags, addReportFlags},
+		run:         runReport,
+	},
+}
+
+func main() {
+	name, args := defaultCommand, os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	idx := slices.IndexFunc(commands, func(c command) bool { return c.name == name })
+	if idx < 0 {
+		printUsage()
+		os.Exit(2)
+	}
+	cmd := commands[idx]
+
+	var (
+		fs = flag.NewFlagSet(cmd.name, flag.ExitOnError)
+		o  = defaultOptions()
+	)
+	for _, addFlags := range cmd.flags {
+		addFlags(fs, o)
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s: %s\n", cmd.name, cmd.description)
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args) // ExitOnError
+
+	if err := setupLogging(o.logLevel, nil); err != nil {
+		fatal(err)
+	}
+	if err := o.resolve(); err != nil {
+		fatal(err)
+	}
+	if err := o.startRun(); err != nil {
+		fatal(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	cmd.run(ctx, o)
+	if o.run != nil {
+		if err := o.run.finish(cmd.name, args); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+func printUsage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.description)
+	}
+	fmt.Fprintf(os.Stderr, "\n%s runs if no command is given. Use <command> -h to list its flags\n", defaultCommand)
+}
+
+func runAnalyze(ctx context.Context, o *options) {
+	a, out := analyze(ctx, o)
+	if o.watch {
+		a.watch(ctx, out)
+	}
+}
+
+// analyze runs the whole analysis and returns it, along with the output plots
+// are saved to unless disabled, so that its results can be rendered further
+func analyze(ctx context.Context, o *options) (*analysis, plotOutput) {
+	var out plotOutput
+	if !o.noPlot {
+		var err error
+		if out, err = newPlotOutput(o.outDir, o.plotFormat); err != nil {
+			fatal(err)
+		}
+	}
+
+	a := loadRecords(ctx, o)
+	a.printStats()
+	if !o.noPlot {
+		a.printHistograms(out)
+	}
+	a.computeTargets()
+	a.findPeaks(ctx)
+	a.selectWindow(ctx)
+	a.simulateThrottling()
+	a.computeFees(ctx)
+	a.writeReport()
+
+	targets, utilizations := a.utilizations()
+	if !o.noPlot {
+		a.plot(out, targets, utilizations)
+	}
+	a.printOutput()
+	return a, out
+}
+
+func runPeaks(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+	a.computeTargets()
+	a.findPeaks(ctx)
+	a.printPeaks()
+	a.printOutput()
+}
+
+func runFees(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+	a.computeTargets()
+	a.findPeaks(ctx)
+	a.selectWindow(ctx)
+	a.simulateThrottling()
+	a.computeFees(ctx)
+	if !o.noPlot {
+		out, err := newPlotOutput(o.outDir, o.plotFormat)
+		if err != nil {
+			fatal(err)
+		}
+		a.plotFees(out)
+	}
+	a.printOutput()
+}
+
+func runPlot(ctx context.Context, o *options) {
+	out, err := newPlotOutput(o.outDir, o.plotFormat)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := loadRecords(ctx, o)
+	a.printHistograms(out)
+	a.computeTargets()
+	a.findPeaks(ctx)
+	a.selectWindow(ctx)
+	a.computeFees(ctx)
+
+	targets, utilizations := a.utilizations()
+	a.plot(out, targets, utilizations)
+	a.printOutput()
+}
+
+// parseTimeFlag converts an RFC3339 timestamp into Unix seconds,
+// returning [fallback] if [value] is empty
+func parseTimeFlag(value string, fallback uint64) (uint64, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("failed parsing timestamp %q: %w", value, err)
+	}
+	if t.Unix() < 0 {
+		return 0, fmt.Errorf("timestamp %q predates Unix epoch", value)
+	}
+	return uint64(t.Unix()), nil
+}
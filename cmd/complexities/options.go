@@ -0,0 +1,928 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var outputModes = []string{outputText, outputJSON}
+
+// initialExcessPeak starts fee replays from the excess gas accrued
+// at the midpoint of the top total gas peak
+const initialExcessPeak = "peak"
+
+// options holds the flags of all subcommands. Each subcommand registers only
+// the flag groups it needs, the others keep the defaults set by defaultOptions.
+type options struct {
+	// input flags
+	chainName      string
+	csvPaths       string
+	rpcURI         string
+	rpcParallelism int
+	dbPath         string
+	columnsSpec    string
+	timestampsPath string
+	explorerURL    string
+	cacheDir       string
+	noCache        bool
+	fromTime       string
+	toTime         string
+	minHeight      uint64
+	maxHeight      uint64
+	cleanMode      string
+	outlierFactor  float64
+	outliersPath   string
+	logLevel       string
+	onError        string
+	output         string
+
+	// target flags
+	quantile           float64
+	blockDelayQuantile float64
+	minBlockDelay      uint64
+	sameTime           string
+	quantilesSpec      string
+	topBlocks          int
+
+	// peak flags
+	smoothWindow    int
+	thresholdWindow int
+	sortMode        string
+	sortOrder       string
+	peaksOutPath    string
+	allPeaks        bool
+	dimensionName   string
+	peakIndex       int
+	detectorMode    string
+	hysteresisStart float64
+	hysteresisStop  float64
+	zScore          float64
+	zScoreWindow    int
+	emaAlpha        float64
+	minPeakDuration uint64
+	peakAtHeight    uint64
+	peakAtTime      string
+	marginBefore    uint64
+	marginAfter     uint64
+
+	// fee flags
+	feeConfigPaths  string
+	feeOutPath      string
+	denomName       string
+	resampleSpec    string
+	units           string
+	gasWeightsSpec  string
+	feePrecision    int
+	verifyOutPath   string
+	excessOutPath   string
+	maxGasPerSecond uint64
+	throttleOutPath string
+	initialExcess   string
+
+	// plot flags
+	plotFormat         string
+	outDir             string
+	layout             string
+	xAxisMode          string
+	maxXGap            uint64
+	annotatePeaks      bool
+	panel              bool
+	bins               int
+	utilizationOutPath string
+
+	// analyze only flags
+	reportPath string
+	noPlot     bool
+
+	// sweep flags, ranges default to the first fee config values
+	gasTargetRates     string
+	updateDenominators string
+	maxGasPerSeconds   string
+	minGasPrices       string
+	priceThreshold     uint64
+	sweepOutPath       string
+	maxMedianFee       float64
+	minPeakFee         float64
+	maxRecovery        time.Duration
+	pareto             bool
+	parallelism        int
+
+	// ingest flags
+	dbOutPath string
+
+	// convert flags
+	recordsOutPath string
+
+	// sensitivity flags
+	weightFactors      string
+	sensitivityOutPath string
+
+	// recommend flags
+	priceFactor      float64
+	recommendOutPath string
+
+	// simulate flags
+	syntheticBlocks     int
+	syntheticBlockDelay float64
+	burstProbability    float64
+	burstBlocks         int
+	burstFactor         float64
+	scaleSpec           string
+	seed                uint64
+	syntheticOutPath    string
+
+	// step response flags
+	waveform           string
+	responseBlocks     int
+	responseBlockDelay float64
+	onsetBlocks        int
+	periodBlocks       int
+	baselineSpec       string
+	amplitudeSpec      string
+
+	// watch flags
+	watch        bool
+	pollInterval time.Duration
+	watchWindow  time.Duration
+
+	// alerts flags
+	alertsPath string
+
+	// validate flags
+	maxIssues int
+
+	// serve flags
+	listenAddr string
+
+	// dashboard flags
+	dashboardAddr string
+	datasetsDir   string
+
+	// scenario flags
+	scenarioPath string
+
+	// report flags
+	reportFormat  string
+	reportOutPath string
+
+	// capacity flags
+	capacityQuantilesSpec string
+	capacityOutPath       string
+
+	// revenue flags
+	revenuePeriod  string
+	revenueOutPath string
+
+	// rolling flags
+	rollingWindowsSpec string
+	rollingQuantile    float64
+	rollingOutPath     string
+
+	// values resolved from flags by resolve
+	initialExcessGas    uint64
+	initialExcessAtPeak bool
+	minTime             uint64
+	maxTime             uint64
+	peakTime            uint64
+	cols                columns
+	dimension           commonfee.Dimension
+	detector            complexity.PeakDetector
+	peakOrder           complexity.PeakOrder
+	// totalGasWindow selects the window from peaks of the weighted
+	// total gas rather than of [dimension]
+	totalGasWindow    bool
+	quantiles         []float64
+	capacityQuantiles []float64
+	scale             [commonfee.NumDimensions]float64
+	rollingWindows    []time.Duration
+	chain             chain
+	denom             denomination
+	feeCfgs           []namedFeeConfig
+	alerts            *alertRules   // nil if no alert rules are set
+	cache             *resultsCache // nil if caching is disabled
+	run               *resultsRun   // nil unless the run layout is set
+
+	// resampleWidth is the width, in seconds, of the buckets records are aggregated
+	// into, 0 if they are not. It is resolved from data when resampling by block delay.
+	resampleWidth   uint64
+	resampleByDelay bool
+	// gasWeights converts complexities into gas when analyzing in gas units
+	gasWeights commonfee.Dimensions
+	// baseline and amplitude are the per block complexities of step response inputs
+	baseline  commonfee.Dimensions
+	amplitude commonfee.Dimensions
+}
+
+func defaultOptions() *options {
+	return &options{
+		chainName:             "P",
+		rpcParallelism:        8,
+		maxHeight:             math.MaxUint64,
+		logLevel:              "info",
+		onError:               onErrorAbort,
+		cleanMode:             complexity.CleanOff,
+		outlierFactor:         100,
+		output:                outputText,
+		quantile:              0.99,
+		blockDelayQuantile:    0.5,
+		minBlockDelay:         1,
+		sameTime:              complexity.SameTimeFloor,
+		topBlocks:             5,
+		smoothWindow:          1,
+		thresholdWindow:       1,
+		sortMode:              complexity.SortByComplexity,
+		sortOrder:             complexity.SortDescending,
+		detectorMode:          complexity.DetectThreshold,
+		hysteresisStart:       1.2,
+		hysteresisStop:        0.8,
+		zScore:                3,
+		zScoreWindow:          100,
+		dimensionName:         "bandwidth",
+		peakIndex:             2,
+		marginBefore:          5,
+		denomName:             "avax",
+		feePrecision:          -1,
+		units:                 unitsComplexity,
+		maxIssues:             100,
+		plotFormat:            "png",
+		outDir:                ".",
+		layout:                layoutFlat,
+		xAxisMode:             xAxisHeight,
+		bins:                  50,
+		syntheticBlocks:       100_000,
+		burstProbability:      0.001,
+		burstBlocks:           100,
+		burstFactor:           5,
+		seed:                  1,
+		waveform:              complexity.WaveformStep,
+		responseBlocks:        3_600,
+		responseBlockDelay:    1,
+		onsetBlocks:           60,
+		periodBlocks:          600,
+		baselineSpec:          formatDimensions(commonfee.Dimensions{}),
+		amplitudeSpec:         formatDimensions(defaultAmplitude()),
+		listenAddr:            ":9100",
+		dashboardAddr:         ":8080",
+		pollInterval:          5 * time.Second,
+		watchWindow:           24 * time.Hour,
+		weightFactors:         "0.5,2",
+		priceFactor:           10,
+		reportFormat:          reportMarkdown,
+		parallelism:           runtime.NumCPU(),
+		rollingWindowsSpec:    "1h,6h,24h",
+		capacityQuantilesSpec: "0.5,0.9,0.99,0.999",
+		rollingQuantile:       0.99,
+		revenuePeriod:         complexity.RevenueDay,
+	}
+}
+
+func addInputFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.chainName, "chain", o.chainName, "chain whose complexities are analyzed, one of P, X. It picks default input file and layout, and the first height accounted for in targets")
+	fs.StringVar(&o.csvPaths, "csv", o.csvPaths, "comma separated list of CSV files, directories of CSV chunks or glob patterns with block complexities, possibly gzip or zstd compressed. Files with a .parquet extension are read as Parquet, their columns being matched by name, e.g. height or db_read. Use - to read from stdin. The chain export is read if unset")
+	fs.StringVar(&o.rpcURI, "rpc", o.rpcURI, "URI of an avalanchego node, e.g. http://127.0.0.1:9650, whose P-chain blocks between -min-height and -max-height, capped to the tip, are fetched and metered instead of reading -csv. -min-height defaults to the chain first accounted height. Skipped if unset")
+	fs.IntVar(&o.rpcParallelism, "rpc-parallelism", o.rpcParallelism, "number of blocks fetched concurrently with -rpc")
+	fs.StringVar(&o.dbPath, "db", o.dbPath, "path to a SQLite block store written by ingest, read instead of -csv. Only records within -min-height, -max-height, -from and -to are read, looked up by index. Skipped if unset")
+	fs.StringVar(&o.columnsSpec, "columns", o.columnsSpec, fmt.Sprintf("mapping of CSV fields to row indexes, e.g. id=0,height=1,time=2,bandwidth=4,db_read=5,db_write=6,compute=7 plus optional observed_fee and tx_type. Complexity fields are among %s, unmapped ones are read as no complexity. The chain layout is used if unset", strings.Join(complexityColumns[:], ", ")))
+	fs.StringVar(&o.timestampsPath, "timestamps", o.timestampsPath, "path to a CSV file of height,timestamp rows, e.g. from an indexer, used to backfill times of blocks predating the chain first accounted height, which are then accounted for in targets. Skipped if unset")
+	fs.StringVar(&o.fromTime, "from", o.fromTime, "RFC3339 timestamp, only blocks at or after it are analyzed. No lower bound if unset")
+	fs.StringVar(&o.toTime, "to", o.toTime, "RFC3339 timestamp, only blocks at or before it are analyzed. No upper bound if unset")
+	fs.StringVar(&o.fromTime, "from-time", o.fromTime, "alias of -from")
+	fs.StringVar(&o.toTime, "to-time", o.toTime, "alias of -to")
+	fs.Uint64Var(&o.minHeight, "min-height", o.minHeight, "only blocks at or above this height are analyzed")
+	fs.Uint64Var(&o.maxHeight, "max-height", o.maxHeight, "only blocks at or below this height are analyzed")
+	fs.StringVar(&o.cleanMode, "clean", o.cleanMode, fmt.Sprintf("handling of implausible records, i.e. heights not increasing, times going backwards and absurd complexities, one of %v. clamp lifts times to the previous one, caps complexities and drops records it cannot fix", complexity.CleanModes))
+	fs.Float64Var(&o.outlierFactor, "outlier-factor", o.outlierFactor, "complexities above this factor times their dimension 99.9th percentile are deemed absurd while cleaning. 0 checks ordering only")
+	fs.StringVar(&o.resampleSpec, "resample", o.resampleSpec, fmt.Sprintf("aggregate per block complexities into fixed wall-clock buckets, either a duration of whole seconds, e.g. 1m, or %s for the median block delay. Targets, peaks, stats, fees and plots then work on buckets. Blocks are analyzed one by one if unset", resampleBlockDelay))
+	fs.StringVar(&o.outliersPath, "outliers-out", o.outliersPath, "path to a CSV file where implausible records found while cleaning are written. Skipped if unset")
+	fs.StringVar(&o.explorerURL, "explorer-url", o.explorerURL, fmt.Sprintf("block explorer URL, followed by block IDs, linked wherever blocks are printed or exported. The chain explorer is used if unset, %s leaves links out", noExplorer))
+	fs.StringVar(&o.cacheDir, "cache-dir", o.cacheDir, "directory where parsed input files and fee replays are cached, keyed by file and config digests. complexities under the user cache dir, e.g. ~/.cache/complexities, is used if unset")
+	fs.BoolVar(&o.noCache, "no-cache", o.noCache, "neither read nor write cached results")
+	fs.StringVar(&o.logLevel, "log-level", o.logLevel, "diagnostics verbosity, one of error, warn, info, debug")
+	fs.StringVar(&o.onError, "on-error", o.onError, fmt.Sprintf("handling of malformed rows, failing fee configs and failing plots, one of %v. warn and skip go on, logging errors at warn and debug level respectively", onErrorModes))
+	fs.StringVar(&o.output, "output", o.output, fmt.Sprintf("format of results printed on stdout, one of %v", outputModes))
+}
+
+func addTargetFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.units, "units", o.units, fmt.Sprintf("units records are analyzed in, one of %v. gas scales each dimension by its weight at ingestion, so that targets, peaks, quantiles and plots are in gas, the total being the plain sum of dimensions", unitModes))
+	fs.StringVar(&o.gasWeightsSpec, "gas-weights", o.gasWeightsSpec, fmt.Sprintf("comma separated weights of %s converting complexities into gas with -units gas. The first fee config weights are used if unset", strings.Join(complexityColumns[:], ", ")))
+	fs.Float64Var(&o.quantile, "quantile", o.quantile, "quantile, from 0 to 1, of historical complexity rates used as target complexity rate")
+	fs.Float64Var(&o.blockDelayQuantile, "block-delay-quantile", o.blockDelayQuantile, "quantile, from 0 to 1, of inter-block delays used as target block delay")
+	fs.StringVar(&o.quantilesSpec, "quantiles", o.quantilesSpec, "comma separated list of quantiles, from 0 to 1, e.g. 0.5,0.9,0.95,0.99, for which block delay and complexity rates are tabulated. Skipped if unset")
+	fs.IntVar(&o.topBlocks, "top-blocks", o.topBlocks, "number of blocks with the highest complexity reported per dimension. 0 disables them")
+	fs.Uint64Var(&o.minBlockDelay, "min-block-delay", o.minBlockDelay, "floor, in seconds, for the target block delay. Dense same-timestamp data may otherwise yield a degenerate delay")
+	fs.StringVar(&o.sameTime, "same-time", o.sameTime, fmt.Sprintf("how targets are granted to blocks sharing a timestamp, one of %v", complexity.SameTimeModes))
+}
+
+func addPeakFlags(fs *flag.FlagSet, o *options) {
+	fs.IntVar(&o.smoothWindow, "smooth", o.smoothWindow, "number of blocks of the moving average applied to traces before peak detection. 1 disables smoothing")
+	fs.IntVar(&o.thresholdWindow, "threshold-window", o.thresholdWindow, "number of blocks whose elapsed time is averaged to compute peak thresholds. 1 uses the delay from the parent block only")
+	fs.StringVar(&o.sortMode, "sort-peaks-by", o.sortMode, fmt.Sprintf("comma separated peaks ranking keys, each breaking ties of the previous ones, among %v. A single key gets a default tie break: complexity by power, others by complexity", complexity.SortModes))
+	fs.StringVar(&o.sortMode, "sort", o.sortMode, "alias of -sort-peaks-by")
+	fs.StringVar(&o.sortOrder, "sort-order", o.sortOrder, fmt.Sprintf("peaks ranking direction, one of %v. desc ranks first peaks with the highest values, e.g. the most intense bursts, asc those with the lowest ones", complexity.SortOrders))
+	fs.StringVar(&o.detectorMode, "detector", o.detectorMode, fmt.Sprintf("peak detection strategy, one of %v. threshold compares blocks with their target value, hysteresis uses separate start and stop levels, zscore compares blocks with the recent mean", complexity.DetectorModes))
+	fs.Float64Var(&o.hysteresisStart, "hysteresis-start", o.hysteresisStart, "multiple of the target value a block must reach to start a peak, with -detector hysteresis")
+	fs.Float64Var(&o.hysteresisStop, "hysteresis-stop", o.hysteresisStop, "multiple of the target value a block must fall below to end a peak, with -detector hysteresis")
+	fs.Float64Var(&o.zScore, "zscore", o.zScore, "standard deviations above the recent mean a block must reach to be part of a peak, with -detector zscore")
+	fs.IntVar(&o.zScoreWindow, "zscore-window", o.zScoreWindow, "number of preceding blocks the mean and standard deviation are computed over, with -detector zscore")
+	fs.Float64Var(&o.emaAlpha, "ema", o.emaAlpha, "weight, from 0 to 1, of the latest block in an exponential moving average applied to traces before detection. 0 disables it")
+	fs.Uint64Var(&o.minPeakDuration, "min-peak-duration", o.minPeakDuration, "peaks lasting less than this many seconds are dropped. 0 keeps all of them")
+	fs.StringVar(&o.peaksOutPath, "peaks-out", o.peaksOutPath, "path to a file where top peaks of each dimension and of the total gas are written, ranked, as JSON if it has a .json extension, as CSV otherwise. Skipped if unset")
+	fs.BoolVar(&o.allPeaks, "all-peaks", o.allPeaks, "write all detected peaks to -peaks-out rather than the top ones")
+	fs.StringVar(&o.dimensionName, "dimension", o.dimensionName, fmt.Sprintf("dimension whose peak selects the analyzed window, one of %s, or total for the gas weighted by the first fee config", strings.Join(complexityColumns[:], ", ")))
+	fs.IntVar(&o.peakIndex, "peak", o.peakIndex, "rank of the peak selecting the analyzed window, 1 being the top peak")
+	fs.IntVar(&o.peakIndex, "peak-rank", o.peakIndex, "alias of -peak")
+	fs.Uint64Var(&o.peakAtHeight, "peak-at-height", o.peakAtHeight, "select the peak containing the block at this height instead of ranking peaks. Unused if unset")
+	fs.StringVar(&o.peakAtTime, "peak-containing-time", o.peakAtTime, "RFC3339 timestamp, select the peak spanning it instead of ranking peaks. Unused if unset")
+	fs.Uint64Var(&o.marginBefore, "margin-before", o.marginBefore, "number of blocks before the selected peak included in the analyzed window")
+	fs.Uint64Var(&o.marginAfter, "margin-after", o.marginAfter, "number of blocks after the selected peak included in the analyzed window")
+}
+
+func addFeeConfigFlags(fs *flag.FlagSet, o *options) {
+	fs.Var(&listFlag{value: &o.feeConfigPaths}, "fee-config", "comma separated list of JSON or YAML fee configs to compare, the flag may be repeated. Hardcoded defaults are used if unset")
+	fs.StringVar(&o.denomName, "denom", o.denomName, "fees denomination, one of avax, milliavax, microavax, nanoavax, or their labels Avax, mAvax, µAvax, nAvax")
+	fs.IntVar(&o.feePrecision, "fee-precision", o.feePrecision, "number of decimals fees are printed with in tables, CSV files and plot axes. Fees are printed exactly if -1")
+}
+
+func addFeeFlags(fs *flag.FlagSet, o *options) {
+	addFeeConfigFlags(fs, o)
+	fs.StringVar(&o.feeOutPath, "fee-out", o.feeOutPath, "path to a file where per block gas price, excess gas and fee computed with the first fee config are written, as JSON if it has a .json extension, as Parquet if it has a .parquet one, as CSV otherwise. Skipped if unset")
+	fs.StringVar(&o.verifyOutPath, "verify", o.verifyOutPath, "path to a CSV file where fees computed with the first fee config over the whole dataset are compared with observed ones. Skipped if unset")
+	fs.StringVar(&o.excessOutPath, "excess-out", o.excessOutPath, "path to a file where per block excess gas, gas price and fee computed with the first fee config over the whole dataset are written, as JSON if it has a .json extension, as Parquet if it has a .parquet one, as CSV otherwise. Periods spent above the min gas price are summarized too. Skipped if unset")
+	fs.Uint64Var(&o.maxGasPerSecond, "max-gas-per-second", o.maxGasPerSecond, "gas cap per second used to simulate throttled blocks. The first fee config value is used if unset")
+	fs.StringVar(&o.initialExcess, "initial-excess", o.initialExcess, fmt.Sprintf("excess gas fee replays over the analyzed window start from, either a value or %s for the excess gas accrued at the midpoint of the top total gas peak, as if rules activated amid a surge. Replays start from no excess gas if unset", initialExcessPeak))
+	fs.StringVar(&o.throttleOutPath, "throttle-out", o.throttleOutPath, "path to a CSV file where height ranges of throttled blocks are written. Skipped if unset")
+}
+
+// addOutputFlags adds the flags telling where and how plots are saved,
+// shared by all commands producing plots
+func addOutputFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.plotFormat, "format", o.plotFormat, fmt.Sprintf("plots format, one of %v", plotFormats))
+	fs.StringVar(&o.outDir, "out-dir", o.outDir, "directory where plots are saved")
+	fs.StringVar(&o.layout, "layout", o.layout, fmt.Sprintf("layout of the output dir, one of %v. run gathers plots, exported files, logs and a manifest of the files produced into a fresh timestamped dir, relative export paths being placed under its exports subdir", layouts))
+}
+
+func addPlotFlags(fs *flag.FlagSet, o *options) {
+	addOutputFlags(fs, o)
+	fs.StringVar(&o.xAxisMode, "x-axis", o.xAxisMode, fmt.Sprintf("plots x axis, one of %v. time spreads blocks sharing a timestamp within their second, synthetic advances by at least one per block", xAxisModes))
+	fs.Uint64Var(&o.maxXGap, "max-x-gap", o.maxXGap, "longest gap, in seconds, among consecutive blocks shown along time and synthetic x axes, longer gaps are shrunk to it. 0 keeps gaps as they are")
+	fs.BoolVar(&o.annotatePeaks, "annotate-peaks", o.annotatePeaks, "mark detected peaks on gas plots")
+	fs.BoolVar(&o.panel, "panel", o.panel, "also plot all dimensions as stacked panels of a single figure")
+	fs.IntVar(&o.bins, "bins", o.bins, "number of buckets of complexity histograms")
+	fs.StringVar(&o.utilizationOutPath, "utilization-out", o.utilizationOutPath, "path to a CSV file where per block utilization is written. Skipped if unset")
+}
+
+func addFeesPlotFlags(fs *flag.FlagSet, o *options) {
+	addOutputFlags(fs, o)
+	fs.StringVar(&o.xAxisMode, "x-axis", o.xAxisMode, fmt.Sprintf("plots x axis, one of %v. time spreads blocks sharing a timestamp within their second, synthetic advances by at least one per block", xAxisModes))
+	fs.BoolVar(&o.noPlot, "no-plot", o.noPlot, "skip fee and gas price plots, only printed results and requested CSV/JSON files are produced")
+}
+
+func addAnalyzeFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.reportPath, "report", o.reportPath, "path to a JSON file where the whole analysis report is written. Skipped if unset")
+	fs.BoolVar(&o.noPlot, "no-plot", o.noPlot, "skip plots and histograms, only printed results and requested CSV/JSON files are produced")
+}
+
+func addWatchFlags(fs *flag.FlagSet, o *options) {
+	fs.BoolVar(&o.watch, "watch", o.watch, "once the analysis is done, keep following the input file, a single uncompressed one, and extend the analysis as blocks are appended, alerting when a new peak starts")
+	fs.DurationVar(&o.pollInterval, "poll", o.pollInterval, "how often the input file is checked for new blocks, with -watch")
+	fs.DurationVar(&o.watchWindow, "watch-window", o.watchWindow, "trailing time window targets, peaks and plots are recomputed over, with -watch")
+}
+
+func addAlertFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.alertsPath, "alerts", o.alertsPath, "path to a JSON or YAML file of alert rules on simulated fees and gas prices, and of webhooks alerts are posted to, see alerts.yaml. Rules are checked on blocks tailed by serve or appended with -watch")
+}
+
+func addValidateFlags(fs *flag.FlagSet, o *options) {
+	fs.IntVar(&o.maxIssues, "max-issues", o.maxIssues, "maximum number of issues listed, all of them being counted")
+}
+
+func addSweepFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.gasTargetRates, "gas-target-rate", o.gasTargetRates, "gas target rates to sweep, either a comma separated list or a start:stop:step range")
+	fs.StringVar(&o.updateDenominators, "update-denominator", o.updateDenominators, "update denominators to sweep, either a comma separated list or a start:stop:step range")
+	fs.StringVar(&o.maxGasPerSeconds, "max-gas-per-second", o.maxGasPerSeconds, "max gas per second values to sweep, either a comma separated list or a start:stop:step range")
+	fs.StringVar(&o.minGasPrices, "min-gas-price", o.minGasPrices, "min gas prices, in nAvax, to sweep, either a comma separated list or a start:stop:step range")
+	fs.Uint64Var(&o.priceThreshold, "price-threshold", o.priceThreshold, "gas price, in nAvax, above which time is accounted as congested. Each combination min gas price is used if unset")
+	fs.StringVar(&o.sweepOutPath, "sweep-out", o.sweepOutPath, "path to a CSV file where the sweep summary is written. Skipped if unset")
+	fs.Float64Var(&o.maxMedianFee, "max-median-fee", o.maxMedianFee, "drop configs whose median fee, in the chosen denomination, is above this value. No constraint if unset")
+	fs.Float64Var(&o.minPeakFee, "min-peak-fee", o.minPeakFee, "drop configs whose max fee during the top total gas peak, in the chosen denomination, is below this value. No constraint if unset")
+	fs.DurationVar(&o.maxRecovery, "max-recovery", o.maxRecovery, "drop configs whose gas price takes longer than this to return to the min gas price after the top total gas peak, e.g. 10m. No constraint if unset")
+	fs.BoolVar(&o.pareto, "pareto", o.pareto, "only report Pareto-optimal configs, minimizing median fee and recovery time while maximizing peak fee")
+	fs.IntVar(&o.parallelism, "parallelism", o.parallelism, "number of fee configs replayed concurrently. Defaults to the number of CPUs")
+}
+
+func addConvertFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.recordsOutPath, "records-out", o.recordsOutPath, "path to the file records are written to, as Parquet if it has a .parquet extension, as CSV in the default layout otherwise, possibly gzip or zstd compressed")
+}
+
+func addIngestFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.dbOutPath, "db-out", o.dbOutPath, "path to the SQLite block store records are ingested into, created if missing. Blocks already stored are replaced, and fetching with -rpc resumes above the latest stored height")
+}
+
+func addSensitivityFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.weightFactors, "weight-factors", o.weightFactors, "comma separated multipliers applied, one dimension at a time, to the first fee config weights")
+	fs.StringVar(&o.sensitivityOutPath, "sensitivity-out", o.sensitivityOutPath, "path to a CSV file where the sensitivity table is written. Skipped if unset")
+}
+
+func addRecommendFlags(fs *flag.FlagSet, o *options) {
+	fs.Float64Var(&o.priceFactor, "price-factor", o.priceFactor, "multiplier of the gas price reached over the top total gas peak, from which the update denominator is derived. Must be above 1")
+	fs.StringVar(&o.recommendOutPath, "recommend-out", o.recommendOutPath, "path to a JSON file where the recommended fee config is written, ready to be passed to -fee-config. Skipped if unset")
+}
+
+func addSimulateFlags(fs *flag.FlagSet, o *options) {
+	fs.IntVar(&o.syntheticBlocks, "blocks", o.syntheticBlocks, "number of synthetic blocks to generate")
+	fs.Float64Var(&o.syntheticBlockDelay, "block-delay", o.syntheticBlockDelay, "mean delay, in seconds, among Poisson block arrivals. Historical mean delay is used if unset")
+	fs.Float64Var(&o.burstProbability, "burst-prob", o.burstProbability, "probability, from 0 to 1, that a burst starts at any block outside bursts")
+	fs.IntVar(&o.burstBlocks, "burst-blocks", o.burstBlocks, "number of blocks a burst lasts")
+	fs.Float64Var(&o.burstFactor, "burst-factor", o.burstFactor, "multiplier of complexities of blocks within bursts")
+	fs.StringVar(&o.scaleSpec, "scale", o.scaleSpec, fmt.Sprintf("comma separated multipliers of %s complexities. Historical complexities are kept if unset", strings.Join(complexityColumns[:], ", ")))
+	fs.Uint64Var(&o.seed, "seed", o.seed, "seed of the generator, same seed and flags yield the same trace")
+	fs.Uint64Var(&o.priceThreshold, "price-threshold", o.priceThreshold, "gas price, in nAvax, above which time is accounted as congested. Each config min gas price is used if unset")
+	fs.StringVar(&o.syntheticOutPath, "synthetic-out", o.syntheticOutPath, "path to a file where generated blocks are written, as Parquet if it has a .parquet extension, as CSV in the default layout otherwise. Skipped if unset")
+}
+
+func addStepResponseFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.waveform, "waveform", o.waveform, fmt.Sprintf("shape of the complexity input, one of %v. step excites all blocks from the onset on, impulse the onset block only, square the first half of each period", complexity.Waveforms))
+	fs.IntVar(&o.responseBlocks, "blocks", o.responseBlocks, "number of idealized blocks to generate")
+	fs.Float64Var(&o.responseBlockDelay, "block-delay", o.responseBlockDelay, "delay, in seconds, among consecutive blocks")
+	fs.IntVar(&o.onsetBlocks, "onset", o.onsetBlocks, "number of baseline blocks preceding the first excited one")
+	fs.IntVar(&o.periodBlocks, "period", o.periodBlocks, "period, in blocks, of square waves")
+	fs.StringVar(&o.baselineSpec, "baseline", o.baselineSpec, fmt.Sprintf("comma separated %s complexities of every block", strings.Join(complexityColumns[:], ", ")))
+	fs.StringVar(&o.amplitudeSpec, "amplitude", o.amplitudeSpec, fmt.Sprintf("comma separated %s complexities added to the baseline of excited blocks", strings.Join(complexityColumns[:], ", ")))
+	fs.StringVar(&o.syntheticOutPath, "synthetic-out", o.syntheticOutPath, "path to a file where generated blocks are written, as Parquet if it has a .parquet extension, as CSV in the default layout otherwise. Skipped if unset")
+	addOutputFlags(fs, o)
+	fs.BoolVar(&o.noPlot, "no-plot", o.noPlot, "skip input, gas price and excess gas plots, only printed results are produced")
+	fs.StringVar(&o.logLevel, "log-level", o.logLevel, "diagnostics verbosity, one of error, warn, info, debug")
+	fs.StringVar(&o.onError, "on-error", o.onError, fmt.Sprintf("handling of failing fee configs and failing plots, one of %v", onErrorModes))
+	fs.StringVar(&o.output, "output", o.output, fmt.Sprintf("format of results printed on stdout, one of %v", outputModes))
+}
+
+func addServeFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.listenAddr, "listen", o.listenAddr, "address metrics are served at, under /metrics")
+}
+
+func addDashboardFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.dashboardAddr, "dashboard-listen", o.dashboardAddr, "address the dashboard is served at")
+	fs.StringVar(&o.datasetsDir, "datasets-dir", o.datasetsDir, "directory whose CSV files are offered as datasets, parsed once selected. The -csv input is loaded if unset")
+}
+
+func addHistogramFlags(fs *flag.FlagSet, o *options) {
+	addOutputFlags(fs, o)
+	fs.IntVar(&o.bins, "bins", o.bins, "number of buckets of each histogram")
+}
+
+func addCorrelationFlags(fs *flag.FlagSet, o *options) {
+	addOutputFlags(fs, o)
+	fs.BoolVar(&o.noPlot, "no-plot", o.noPlot, "skip scatter plots, only correlations are printed")
+}
+
+func addScenarioFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.scenarioPath, "scenario", o.scenarioPath, "path to a JSON or YAML scenario file, see scenario.yaml")
+	fs.StringVar(&o.layout, "layout", o.layout, fmt.Sprintf("layout of the output dir, one of %v. run gathers plots, exported files, logs and a manifest of the files produced into a fresh timestamped dir, under the scenario output dir", layouts))
+	fs.StringVar(&o.logLevel, "log-level", o.logLevel, "diagnostics verbosity, one of error, warn, info, debug")
+	fs.StringVar(&o.onError, "on-error", o.onError, fmt.Sprintf("handling of malformed rows, failing fee configs and failing plots, one of %v", onErrorModes))
+	fs.StringVar(&o.output, "output", o.output, fmt.Sprintf("format of results printed on stdout, one of %v", outputModes))
+}
+
+func addReportFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.reportFormat, "report-format", o.reportFormat, fmt.Sprintf("report format, one of %v. HTML reports embed plots, Markdown ones link them", reportFormats))
+	fs.StringVar(&o.reportOutPath, "report-out", o.reportOutPath, "path the report is written to. Defaults to report.md or report.html in the scenario output dir")
+}
+
+func addRollingFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.rollingWindowsSpec, "windows", o.rollingWindowsSpec, "comma separated list of rolling windows, e.g. 1h,6h,24h")
+	fs.Float64Var(&o.rollingQuantile, "rolling-quantile", o.rollingQuantile, "quantile, from 0 to 1, of per block complexity rates reported within each window")
+	fs.StringVar(&o.rollingOutPath, "rolling-out", o.rollingOutPath, "path to a CSV file where rolling rates are written. Skipped if unset")
+	fs.BoolVar(&o.noPlot, "no-plot", o.noPlot, "skip plots, only printed results and requested CSV files are produced")
+}
+
+func addCapacityFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.capacityQuantilesSpec, "capacity-quantiles", o.capacityQuantilesSpec, "comma separated list of quantiles, from 0 to 1, at which per block capacity utilization is reported")
+	fs.StringVar(&o.capacityOutPath, "capacity-out", o.capacityOutPath, "path to a CSV file where per block gas, gas budget and utilization under the first fee config are written. Skipped if unset")
+}
+
+func addRevenueFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.revenuePeriod, "revenue-period", o.revenuePeriod, fmt.Sprintf("period fees are summed over, one of %v. Periods are UTC days or weeks starting on Monday", complexity.RevenuePeriods))
+	fs.StringVar(&o.revenueOutPath, "revenue-out", o.revenueOutPath, "path to a CSV file where fees collected by each fee config per period are written. Skipped if unset")
+}
+
+// listFlag is a comma separated list flag which may also be repeated,
+// each occurrence appending its values to those of the previous ones
+type listFlag struct {
+	value *string
+	set   bool
+}
+
+func (f *listFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f *listFlag) Set(s string) error {
+	if f.set && *f.value != "" {
+		*f.value += "," + s
+	} else {
+		*f.value = s
+	}
+	f.set = true
+	return nil
+}
+
+// resolve validates flag values and parses those which are not used verbatim.
+// Defaults of flags not registered by a subcommand are valid, so all values are checked.
+func (o *options) resolve() error {
+	switch o.initialExcess {
+	case "":
+	case initialExcessPeak:
+		o.initialExcessAtPeak = true
+	default:
+		v, err := strconv.ParseUint(o.initialExcess, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid initial excess %q, expected a gas value or %s: %w", o.initialExcess, initialExcessPeak, err)
+		}
+		o.initialExcessGas = v
+	}
+	if o.priceFactor <= 1 {
+		return fmt.Errorf("price factor must be above 1, got %v", o.priceFactor)
+	}
+	if o.parallelism < 1 {
+		return fmt.Errorf("parallelism must be positive, got %d", o.parallelism)
+	}
+	if o.rpcParallelism < 1 {
+		return fmt.Errorf("rpc parallelism must be positive, got %d", o.rpcParallelism)
+	}
+	if o.quantile < 0 || o.quantile > 1 {
+		return fmt.Errorf("quantile must be within [0, 1], got %v", o.quantile)
+	}
+	if o.blockDelayQuantile < 0 || o.blockDelayQuantile > 1 {
+		return fmt.Errorf("block delay quantile must be within [0, 1], got %v", o.blockDelayQuantile)
+	}
+	if o.smoothWindow < 1 {
+		return fmt.Errorf("smoothing window must be at least 1, got %d", o.smoothWindow)
+	}
+	if o.thresholdWindow < 1 {
+		return fmt.Errorf("threshold window must be at least 1, got %d", o.thresholdWindow)
+	}
+	if o.peakIndex < 1 {
+		return fmt.Errorf("peak rank must be at least 1, got %d", o.peakIndex)
+	}
+	if o.maxMedianFee < 0 || o.minPeakFee < 0 || o.maxRecovery < 0 {
+		return fmt.Errorf("sweep constraints must not be negative")
+	}
+	if o.rollingQuantile < 0 || o.rollingQuantile > 1 {
+		return fmt.Errorf("rolling quantile must be within [0, 1], got %v", o.rollingQuantile)
+	}
+	if o.feePrecision < -1 {
+		return fmt.Errorf("fee precision must be -1 or more, got %d", o.feePrecision)
+	}
+	if o.maxIssues < 0 {
+		return fmt.Errorf("max issues must not be negative, got %d", o.maxIssues)
+	}
+	if o.topBlocks < 0 {
+		return fmt.Errorf("top blocks must not be negative, got %d", o.topBlocks)
+	}
+	if o.syntheticBlocks < 2 {
+		return fmt.Errorf("at least 2 synthetic blocks are needed, got %d", o.syntheticBlocks)
+	}
+	if o.syntheticBlockDelay < 0 {
+		return fmt.Errorf("block delay must not be negative, got %v", o.syntheticBlockDelay)
+	}
+	if o.burstProbability < 0 || o.burstProbability > 1 {
+		return fmt.Errorf("burst probability must be within [0, 1], got %v", o.burstProbability)
+	}
+	if o.burstBlocks < 0 || o.burstFactor < 0 {
+		return fmt.Errorf("burst blocks and factor must not be negative, got %d and %v", o.burstBlocks, o.burstFactor)
+	}
+	if !slices.Contains(complexity.Waveforms, o.waveform) {
+		return fmt.Errorf("unsupported waveform %q, supported values are %v", o.waveform, complexity.Waveforms)
+	}
+	if o.responseBlocks < 2 || o.responseBlockDelay <= 0 {
+		return fmt.Errorf("at least 2 idealized blocks, spaced by a positive delay, are needed, got %d and %v", o.responseBlocks, o.responseBlockDelay)
+	}
+	if o.onsetBlocks < 0 || o.onsetBlocks >= o.responseBlocks {
+		return fmt.Errorf("onset must be within [0, %d), got %d", o.responseBlocks, o.onsetBlocks)
+	}
+	if o.periodBlocks < 2 {
+		return fmt.Errorf("period must be at least 2 blocks, got %d", o.periodBlocks)
+	}
+	if o.minHeight > o.maxHeight {
+		return fmt.Errorf("min height %d above max height %d", o.minHeight, o.maxHeight)
+	}
+	if o.pollInterval <= 0 || o.watchWindow <= 0 {
+		return fmt.Errorf("poll interval and watch window must be positive, got %v and %v", o.pollInterval, o.watchWindow)
+	}
+	if !slices.Contains(complexity.RevenuePeriods, o.revenuePeriod) {
+		return fmt.Errorf("unsupported revenue period %q, supported values are %v", o.revenuePeriod, complexity.RevenuePeriods)
+	}
+	if !slices.Contains(complexity.CleanModes, o.cleanMode) {
+		return fmt.Errorf("unsupported clean mode %q, supported values are %v", o.cleanMode, complexity.CleanModes)
+	}
+	if o.outlierFactor < 0 {
+		return fmt.Errorf("outlier factor must not be negative, got %v", o.outlierFactor)
+	}
+	if !slices.Contains(onErrorModes, o.onError) {
+		return fmt.Errorf("unsupported error handling %q, supported values are %v", o.onError, onErrorModes)
+	}
+	if !slices.Contains(reportFormats, o.reportFormat) {
+		return fmt.Errorf("unsupported report format %q, supported values are %v", o.reportFormat, reportFormats)
+	}
+	if !slices.Contains(outputModes, o.output) {
+		return fmt.Errorf("unsupported output %q, supported values are %v", o.output, outputModes)
+	}
+	if !slices.Contains(plotFormats, o.plotFormat) {
+		return fmt.Errorf("unsupported plot format %q, supported formats are %v", o.plotFormat, plotFormats)
+	}
+	if !slices.Contains(layouts, o.layout) {
+		return fmt.Errorf("unsupported layout %q, supported values are %v", o.layout, layouts)
+	}
+	if !slices.Contains(xAxisModes, o.xAxisMode) {
+		return fmt.Errorf("unsupported x axis %q, supported values are %v", o.xAxisMode, xAxisModes)
+	}
+	if !slices.Contains(complexity.DetectorModes, o.detectorMode) {
+		return fmt.Errorf("unsupported detector %q, supported values are %v", o.detectorMode, complexity.DetectorModes)
+	}
+	if o.hysteresisStop <= 0 || o.hysteresisStop > o.hysteresisStart {
+		return fmt.Errorf("hysteresis stop level must be positive and not above start level, got %v and %v", o.hysteresisStop, o.hysteresisStart)
+	}
+	if o.zScoreWindow < 1 {
+		return fmt.Errorf("z-score window must be at least 1, got %d", o.zScoreWindow)
+	}
+	if o.emaAlpha < 0 || o.emaAlpha > 1 {
+		return fmt.Errorf("ema weight must be within [0, 1], got %v", o.emaAlpha)
+	}
+	if !slices.Contains(complexity.SameTimeModes, o.sameTime) {
+		return fmt.Errorf("unsupported same time mode %q, supported values are %v", o.sameTime, complexity.SameTimeModes)
+	}
+
+	var err error
+	if o.peakOrder, err = complexity.NewPeakOrder(o.sortMode, o.sortOrder); err != nil {
+		return err
+	}
+	if o.minTime, err = parseTimeFlag(o.fromTime, 0); err != nil {
+		return err
+	}
+	if o.maxTime, err = parseTimeFlag(o.toTime, math.MaxUint64); err != nil {
+		return err
+	}
+	if o.peakTime, err = parseTimeFlag(o.peakAtTime, 0); err != nil {
+		return err
+	}
+	if o.peakAtHeight != 0 && o.peakTime != 0 {
+		return fmt.Errorf("peak can be selected by either height or time, not both")
+	}
+	if strings.EqualFold(o.dimensionName, totalGasName) {
+		o.totalGasWindow = true
+	} else if o.dimension, err = parseDimension(o.dimensionName); err != nil {
+		return err
+	}
+	o.detector = newPeakDetector(o)
+	if o.denom, err = getDenomination(o.denomName); err != nil {
+		return err
+	}
+	o.denom.precision = o.feePrecision
+	if o.rollingWindows, err = parseWindows(o.rollingWindowsSpec); err != nil {
+		return err
+	}
+	switch o.resampleSpec {
+	case "":
+	case resampleBlockDelay:
+		o.resampleByDelay = true
+	default:
+		width, err := time.ParseDuration(o.resampleSpec)
+		if err != nil || width < time.Second || width%time.Second != 0 {
+			return fmt.Errorf("invalid resampling %q, expected a duration of whole seconds or %s", o.resampleSpec, resampleBlockDelay)
+		}
+		o.resampleWidth = uint64(width / time.Second)
+	}
+	if o.resampleSpec != "" && o.watch {
+		return fmt.Errorf("resampling is not supported with -watch")
+	}
+	if !slices.Contains(unitModes, o.units) {
+		return fmt.Errorf("unsupported units %q, supported values are %v", o.units, unitModes)
+	}
+	if o.units == unitsGas && o.watch {
+		return fmt.Errorf("gas units are not supported with -watch")
+	}
+	if o.scale, err = parseScale(o.scaleSpec); err != nil {
+		return err
+	}
+	if o.quantiles, err = parseQuantiles(o.quantilesSpec); err != nil {
+		return err
+	}
+	if o.capacityQuantiles, err = parseQuantiles(o.capacityQuantilesSpec); err != nil {
+		return err
+	}
+	if o.chain, err = getChain(o.chainName); err != nil {
+		return err
+	}
+	if o.rpcURI != "" && o.dbPath != "" {
+		return fmt.Errorf("-rpc and -db are mutually exclusive")
+	}
+	if o.rpcURI != "" || o.dbPath != "" {
+		if o.csvPaths != "" {
+			return fmt.Errorf("-csv is mutually exclusive with -rpc and -db")
+		}
+		if o.watch {
+			return fmt.Errorf("-watch follows CSV input, it does not support -rpc and -db")
+		}
+		if o.rpcURI != "" && o.minHeight == 0 {
+			// blocks predating the chain first accounted height are left out of targets
+			o.minHeight = o.chain.minHeight
+			if o.minHeight > o.maxHeight {
+				return fmt.Errorf("max height %d below the default min height %d of -rpc", o.maxHeight, o.minHeight)
+			}
+		}
+	} else if o.csvPaths == "" {
+		o.csvPaths = o.chain.csvPath
+	}
+	if !o.noCache {
+		o.cache, err = o.openCache()
+		if err != nil {
+			slog.Warn("caching disabled", "err", err)
+		}
+	}
+	switch o.explorerURL {
+	case "":
+		o.explorerURL = o.chain.explorerURL
+	case noExplorer:
+		o.explorerURL = ""
+	}
+	o.cols = o.chain.columns
+	if o.columnsSpec != "" {
+		if o.cols, err = parseColumns(o.columnsSpec); err != nil {
+			return err
+		}
+	}
+	if missing := o.cols.missingDimensions(); len(missing) > 0 {
+		slog.Warn("dimensions missing from the dataset are read as no complexity", "dimensions", strings.Join(missing, ","))
+	}
+	if o.feeCfgs, err = loadFeeConfigs(o.feeConfigPaths); err != nil {
+		return err
+	}
+	o.gasWeights = o.feeCfg().Weights
+	if o.gasWeightsSpec != "" {
+		if o.gasWeights, err = parseDimensions(o.gasWeightsSpec, "weights"); err != nil {
+			return err
+		}
+	}
+	if o.baseline, err = parseDimensions(o.baselineSpec, "complexities"); err != nil {
+		return err
+	}
+	if o.amplitude, err = parseDimensions(o.amplitudeSpec, "complexities"); err != nil {
+		return err
+	}
+	if o.alertsPath != "" {
+		if o.alerts, err = loadAlertRules(o.alertsPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openCache opens the cache in -cache-dir or, if unset, in the user cache dir
+func (o *options) openCache() (*resultsCache, error) {
+	dir := o.cacheDir
+	if dir == "" {
+		userDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(userDir, "complexities")
+	}
+	return newResultsCache(dir)
+}
+
+// newPeakDetector builds the detector selected by [o], wrapped by
+// smoothing and min duration filtering when enabled
+func newPeakDetector(o *options) complexity.PeakDetector {
+	var d complexity.PeakDetector = complexity.ThresholdDetector{}
+	switch o.detectorMode {
+	case complexity.DetectHysteresis:
+		d = complexity.HysteresisDetector{Start: o.hysteresisStart, Stop: o.hysteresisStop}
+	case complexity.DetectZScore:
+		d = complexity.ZScoreDetector{Z: o.zScore, Window: o.zScoreWindow}
+	}
+	if o.emaAlpha > 0 {
+		d = complexity.SmoothedDetector{Alpha: o.emaAlpha, Detector: d}
+	}
+	if o.minPeakDuration > 0 {
+		d = complexity.MinDurationDetector{MinDuration: o.minPeakDuration, Detector: d}
+	}
+	return d
+}
+
+// feeCfg returns the first fee config, which drives the outputs
+// which are not compared across configs
+func (o *options) feeCfg() commonfee.Config {
+	return o.feeCfgs[0].cfg
+}
+
+// parseQuantiles parses a comma separated list of quantiles.
+// An empty [spec] returns no quantiles.
+func parseQuantiles(spec string) ([]float64, error) {
+	if spec == "" {
+		return nil, nil
+	}
+	res := make([]float64, 0)
+	for _, s := range strings.Split(spec, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q: %w", s, err)
+		}
+		if q < 0 || q > 1 {
+			return nil, fmt.Errorf("quantile must be within [0, 1], got %v", q)
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+// parseScale parses one multiplier per dimension.
+// An empty [spec] keeps all dimensions unscaled.
+func parseScale(spec string) ([commonfee.NumDimensions]float64, error) {
+	var res [commonfee.NumDimensions]float64
+	for d := range res {
+		res[d] = 1
+	}
+	if spec == "" {
+		return res, nil
+	}
+	values := strings.Split(spec, ",")
+	if len(values) != commonfee.NumDimensions {
+		return res, fmt.Errorf("invalid scale %q, expected %d multipliers", spec, commonfee.NumDimensions)
+	}
+	for d, v := range values {
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return res, fmt.Errorf("invalid scale %q: %w", spec, err)
+		}
+		if f < 0 {
+			return res, fmt.Errorf("invalid scale %q, multipliers must not be negative", spec)
+		}
+		res[d] = f
+	}
+	return res, nil
+}
+
+// parseDimensions parses comma separated values of all dimensions,
+// e.g. weights or complexities, as [what] names them in errors
+func parseDimensions(spec, what string) (commonfee.Dimensions, error) {
+	var res commonfee.Dimensions
+	values := strings.Split(spec, ",")
+	if len(values) != commonfee.NumDimensions {
+		return res, fmt.Errorf("invalid %s %q, expected %d values", what, spec, commonfee.NumDimensions)
+	}
+	for d, v := range values {
+		w, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return res, fmt.Errorf("invalid %s %q: %w", what, spec, err)
+		}
+		res[d] = w
+	}
+	return res, nil
+}
+
+// formatDimensions formats [v] as parseDimensions parses it
+func formatDimensions(v commonfee.Dimensions) string {
+	values := make([]string, len(v))
+	for d, value := range v {
+		values[d] = strconv.FormatUint(value, 10)
+	}
+	return strings.Join(values, ",")
+}
+
+// defaultAmplitude excites the first dimension of the fee package only
+func defaultAmplitude() commonfee.Dimensions {
+	var res commonfee.Dimensions
+	res[0] = 1000
+	return res
+}
+
+// parseDimension returns the dimension named [name], in snake case
+func parseDimension(name string) (commonfee.Dimension, error) {
+	for d, column := range complexityColumns {
+		if column == strings.ToLower(name) {
+			return commonfee.Dimension(d), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported dimension %q, supported values are %s", name, strings.Join(complexityColumns[:], ", "))
+}
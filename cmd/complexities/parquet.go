@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	parquetExt = ".parquet"
+
+	// denominationKey is the key of the file metadata telling in which
+	// denomination fees of a fee data file are expressed
+	denominationKey = "denomination"
+)
+
+var errParquetSchema = errors.New("unexpected Parquet schema")
+
+// parquetRecord is the Parquet schema of records. Column names are those
+// -columns maps, and the observed fee and tx type columns may be missing or null.
+type parquetRecord struct {
+	ID          [32]byte `parquet:"id"`
+	Height      uint64   `parquet:"height"`
+	Time        uint64   `parquet:"time"`
+	Bandwidth   uint64   `parquet:"bandwidth"`
+	DBRead      uint64   `parquet:"db_read"`
+	DBWrite     uint64   `parquet:"db_write"`
+	Compute     uint64   `parquet:"compute"`
+	ObservedFee *uint64  `parquet:"observed_fee,optional"`
+	TxType      string   `parquet:"tx_type,optional"`
+}
+
+func newParquetRecord(r complexity.Record) parquetRecord {
+	res := parquetRecord{
+		ID:        r.ID,
+		Height:    r.Height,
+		Time:      r.Time,
+		Bandwidth: r.Complexity[commonfee.Bandwidth],
+		DBRead:    r.Complexity[commonfee.DBRead],
+		DBWrite:   r.Complexity[commonfee.DBWrite],
+		Compute:   r.Complexity[commonfee.Compute],
+		TxType:    r.TxType,
+	}
+	if r.HasObservedFee {
+		fee := r.ObservedFee
+		res.ObservedFee = &fee
+	}
+	return res
+}
+
+func (p parquetRecord) record() complexity.Record {
+	r := complexity.Record{
+		ID:            p.ID,
+		BlkHeightTime: complexity.BlkHeightTime{Height: p.Height, Time: p.Time},
+		TxType:        p.TxType,
+	}
+	r.Complexity[commonfee.Bandwidth] = p.Bandwidth
+	r.Complexity[commonfee.DBRead] = p.DBRead
+	r.Complexity[commonfee.DBWrite] = p.DBWrite
+	r.Complexity[commonfee.Compute] = p.Compute
+	if p.ObservedFee != nil {
+		r.ObservedFee, r.HasObservedFee = *p.ObservedFee, true
+	}
+	return r
+}
+
+// parquetFeeData is the Parquet schema of per block fee data.
+// The fee denomination is stored in the file metadata, under denominationKey,
+// and explorer links are null unless set.
+type parquetFeeData struct {
+	Height    uint64  `parquet:"height"`
+	Time      uint64  `parquet:"time"`
+	GasPrice  uint64  `parquet:"gas_price"`
+	ExcessGas uint64  `parquet:"excess_gas"`
+	Fee       float64 `parquet:"fee"`
+	Explorer  string  `parquet:"explorer,optional"`
+}
+
+func newParquetFeeData(e feeDataEntry) parquetFeeData {
+	return parquetFeeData{
+		Height:    e.Height,
+		Time:      e.Time,
+		GasPrice:  uint64(e.GasPrice),
+		ExcessGas: uint64(e.ExcessGas),
+		Fee:       e.Fee,
+		Explorer:  e.Explorer,
+	}
+}
+
+// isParquet returns true if [path] has a .parquet extension
+func isParquet(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), parquetExt)
+}
+
+// writeRecordsParquet writes [records] to [path], zstd compressed
+func writeRecordsParquet(path string, records []complexity.Record) error {
+	return writeParquet(path, records, newParquetRecord)
+}
+
+// writeFeeParquet writes per block fee data to [path], zstd compressed,
+// with fees expressed in [denom]
+func writeFeeParquet(path string, data []feeDataEntry, denom denomination) error {
+	return writeParquet(path, data, newParquetFeeData, parquet.KeyValueMetadata(denominationKey, denom.name))
+}
+
+// writeParquet writes one row per item of [items], as converted by [toRow],
+// in batches of ctxCheckInterval rows so that rows are never all held at once
+func writeParquet[T, R any](path string, items []T, toRow func(T) R, options ...parquet.WriterOption) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	options = append(options, parquet.Compression(&parquet.Zstd))
+	w := parquet.NewGenericWriter[R](f, options...)
+	batch := make([]R, 0, min(len(items), ctxCheckInterval))
+	for i, item := range items {
+		batch = append(batch, toRow(item))
+		if len(batch) < cap(batch) && i < len(items)-1 {
+			continue
+		}
+		if _, err := w.Write(batch); err != nil {
+			return fmt.Errorf("failed writing %s: %w", path, err)
+		}
+		batch = batch[:0]
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// forEachParquetRecord reads [filePath] in batches of rows and hands each
+// record to [fn], as forEachRecord does for CSV files.
+// Columns are matched by name, so that their order does not matter and
+// columns other than those of parquetRecord are ignored.
+func forEachParquetRecord(ctx context.Context, filePath string, fn func(complexity.Record) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to read input file %s: %w", filePath, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to read input file %s: %w", filePath, err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		return fmt.Errorf("unable to parse file as Parquet for %s: %w", filePath, err)
+	}
+	if err := checkParquetSchema(pf.Schema(), parquet.SchemaOf(parquetRecord{})); err != nil {
+		return fmt.Errorf("%s: %w", filePath, err)
+	}
+
+	r := parquet.NewGenericReader[parquetRecord](pf)
+	defer r.Close()
+
+	rows := make([]parquetRecord, ctxCheckInterval)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, err := r.Read(rows)
+		for _, row := range rows[:n] {
+			if err := fn(row.record()); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed reading %s: %w", filePath, err)
+		}
+	}
+}
+
+// checkParquetSchema checks that [got] has every column of [expected],
+// with the same physical type, but for optional columns which may be missing.
+// Complexity columns may be missing as well, as long as one is there: as with
+// CSV files, dimensions missing from the dataset are read as no complexity.
+func checkParquetSchema(got, expected *parquet.Schema) error {
+	hasComplexity := false
+	for _, path := range expected.Columns() {
+		want, _ := expected.Lookup(path...)
+		name := strings.Join(path, ".")
+		col, ok := got.Lookup(path...)
+		isComplexity := slices.Contains(complexityColumns[:], name)
+		switch {
+		case !ok && (want.Node.Optional() || isComplexity):
+			continue
+		case !ok:
+			return fmt.Errorf("%w, missing column %s", errParquetSchema, name)
+		case col.Node.Type().Kind() != want.Node.Type().Kind() || col.Node.Type().Length() != want.Node.Type().Length():
+			return fmt.Errorf("%w, column %s is %s, expected %s", errParquetSchema, name, col.Node.Type(), want.Node.Type())
+		}
+		hasComplexity = hasComplexity || isComplexity
+	}
+	if !hasComplexity {
+		return fmt.Errorf("%w, no complexity dimension, one of %s", errParquetSchema, strings.Join(complexityColumns[:], ", "))
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/parquet-go/parquet-go"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// readRecords reads all records of [path] through forEachRecord
+func readRecords(t testing.TB, path string) []complexity.Record {
+	t.Helper()
+
+	res := make([]complexity.Record, 0)
+	err := forEachRecord(context.Background(), path, defaultColumns, onErrorAbort, func(r complexity.Record) error {
+		res = append(res, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func TestParquetRecordsRoundTrip(t *testing.T) {
+	records := make([]complexity.Record, 0, 3*ctxCheckInterval)
+	for h := uint64(1); h <= 3*ctxCheckInterval; h++ {
+		r := complexity.Record{
+			ID:            ids.GenerateTestID(),
+			BlkHeightTime: complexity.BlkHeightTime{Height: h, Time: 1_700_000_000 + 2*h},
+		}
+		for d := range r.Complexity {
+			r.Complexity[d] = h * uint64(d+1)
+		}
+		if h%3 == 0 {
+			r.ObservedFee, r.HasObservedFee = 1_000*h, true
+		}
+		if h%4 == 0 {
+			r.TxType = "ImportTx"
+		}
+		records = append(records, r)
+	}
+	// values above the int64 range survive the round trip
+	records[0].Height = 1<<64 - 1
+	records[1].Complexity[0] = 1<<64 - 1
+	records[2].ObservedFee = 1<<64 - 1
+
+	path := filepath.Join(t.TempDir(), "records.parquet")
+	if err := writeRecords(path, records); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRecords(t, path); !slices.Equal(got, records) {
+		t.Fatalf("expected %d records to round trip, got %d different ones", len(records), len(got))
+	}
+}
+
+func TestWriteFeeParquet(t *testing.T) {
+	denom, err := getDenomination("nanoavax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []complexity.FeeData{
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 100, Time: 1_700_000_000}, GasPrice: 10, ExcessGas: 0, Fee: 4_820},
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 101, Time: 1_700_000_002}, GasPrice: 12, ExcessGas: 35_000, Fee: 6_516},
+		{BlkHeightTime: complexity.BlkHeightTime{Height: 102, Time: 1_700_000_002}, GasPrice: 1<<64 - 1, ExcessGas: 1<<64 - 1, Fee: 123_456.5},
+	}
+
+	var (
+		explorerURL = chains[0].explorerURL
+		linked      = ids.GenerateTestID()
+		path        = filepath.Join(t.TempDir(), "fees.parquet")
+	)
+	if err := writeFeeData(path, data, denom, map[uint64]ids.ID{101: linked}, explorerURL); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := parquet.ReadFile[parquetFeeData](path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(data) {
+		t.Fatalf("expected %d rows, got %d", len(data), len(rows))
+	}
+	for i, row := range rows {
+		expected := newParquetFeeData(feeDataEntry{FeeData: data[i]})
+		if data[i].Height == 101 {
+			expected.Explorer = blockURL(explorerURL, linked)
+		}
+		if row != expected {
+			t.Fatalf("row %d: expected %+v, got %+v", i, expected, row)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pf, err := parquet.OpenFile(f, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := pf.Lookup(denominationKey); got != denom.name {
+		t.Fatalf("expected denomination %s, got %q", denom.name, got)
+	}
+}
+
+// TestReadParquetFixture reads a file written by another Parquet writer,
+// see testdata/records_arrow.py
+func TestReadParquetFixture(t *testing.T) {
+	var (
+		heights  = []uint64{15_000_000, 15_000_001, 15_000_002, 15_000_003, 15_000_004}
+		times    = []uint64{1_700_000_000, 1_700_000_002, 1_700_000_002, 1_700_000_005, 1_700_000_011}
+		dims     = [][4]uint64{{612, 3, 2, 2_500}, {1_024, 7, 5, 7_000}, {0, 0, 0, 0}, {2_048, 12, 9, 12_000}, {1<<64 - 1, 1, 1, 1}}
+		observed = map[uint64]uint64{15_000_000: 1_000_000, 15_000_003: 2_750_000}
+	)
+	expected := make([]complexity.Record, 0, len(heights))
+	for i, h := range heights {
+		r := complexity.Record{BlkHeightTime: complexity.BlkHeightTime{Height: h, Time: times[i]}}
+		for j := range r.ID {
+			r.ID[j] = byte(i + 1)
+		}
+		r.Complexity = dims[i]
+		r.ObservedFee, r.HasObservedFee = observed[h]
+		expected = append(expected, r)
+	}
+
+	got := readRecords(t, filepath.Join("testdata", "records_arrow.parquet"))
+	if !slices.Equal(got, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, got)
+	}
+}
+
+// missingCompute is a Parquet schema of records lacking the compute dimension
+type missingCompute struct {
+	ID        [32]byte `parquet:"id"`
+	Height    uint64   `parquet:"height"`
+	Time      uint64   `parquet:"time"`
+	Bandwidth uint64   `parquet:"bandwidth"`
+	DBRead    uint64   `parquet:"db_read"`
+	DBWrite   uint64   `parquet:"db_write"`
+}
+
+func TestReadParquetMissingDimensions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.parquet")
+	rows := []missingCompute{
+		{Height: 1, Time: 10, Bandwidth: 100, DBRead: 2, DBWrite: 3},
+		{Height: 2, Time: 12, Bandwidth: 200, DBRead: 4, DBWrite: 6},
+	}
+	if err := parquet.WriteFile(path, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	got := readRecords(t, path)
+	if len(got) != len(rows) {
+		t.Fatalf("expected %d records, got %d", len(rows), len(got))
+	}
+	for i, r := range got {
+		expected := commonfee.Dimensions{rows[i].Bandwidth, rows[i].DBRead, rows[i].DBWrite, 0}
+		if r.Complexity != expected {
+			t.Fatalf("record %d: expected complexity %v, got %v", i, expected, r.Complexity)
+		}
+	}
+}
+
+func TestReadParquetRejectsUnexpectedSchemas(t *testing.T) {
+	type missingHeight struct {
+		ID        [32]byte `parquet:"id"`
+		Time      uint64   `parquet:"time"`
+		Bandwidth uint64   `parquet:"bandwidth"`
+	}
+	type noComplexity struct {
+		ID     [32]byte `parquet:"id"`
+		Height uint64   `parquet:"height"`
+		Time   uint64   `parquet:"time"`
+	}
+	type stringID struct {
+		ID        string `parquet:"id"`
+		Height    uint64 `parquet:"height"`
+		Time      uint64 `parquet:"time"`
+		Bandwidth uint64 `parquet:"bandwidth"`
+		DBRead    uint64 `parquet:"db_read"`
+		DBWrite   uint64 `parquet:"db_write"`
+		Compute   uint64 `parquet:"compute"`
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		write func(path string) error
+	}{
+		{
+			name: "missing column",
+			write: func(path string) error {
+				return parquet.WriteFile(path, []missingHeight{{Time: 1}})
+			},
+		},
+		{
+			name: "no complexity column",
+			write: func(path string) error {
+				return parquet.WriteFile(path, []noComplexity{{Height: 1}})
+			},
+		},
+		{
+			name: "mismatching column type",
+			write: func(path string) error {
+				return parquet.WriteFile(path, []stringID{{ID: ids.GenerateTestID().String(), Height: 1}})
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.name+".parquet")
+			if err := tt.write(path); err != nil {
+				t.Fatal(err)
+			}
+			err := forEachRecord(context.Background(), path, defaultColumns, onErrorAbort, func(complexity.Record) error {
+				return nil
+			})
+			if !errors.Is(err, errParquetSchema) {
+				t.Fatalf("expected %v, got %v", errParquetSchema, err)
+			}
+		})
+	}
+}
+
+// BenchmarkReadRecords compares reading the same records from CSV and Parquet
+func BenchmarkReadRecords(b *testing.B) {
+	const rows = 300_000
+	csvPath := benchmarkCSV(b, rows)
+	parquetPath := filepath.Join(b.TempDir(), "complexities.parquet")
+	if err := writeRecordsParquet(parquetPath, readRecords(b, csvPath)); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, path := range []string{csvPath, parquetPath} {
+		b.Run(filepath.Ext(path)[1:], func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if got := readRecords(b, path); len(got) != rows {
+					b.Fatalf("expected %d records, got %d", rows, len(got))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,874 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gonum.org/v1/plot/plotter"
+
+	"process_data/pkg/complexity"
+
+	"github.com/ava-labs/avalanchego/ids"
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// topPeaksCount is the number of peaks retained per dimension
+const topPeaksCount = 10
+
+// analysis carries results across the stages of the pipeline, so that
+// each subcommand runs only the stages it needs, in order.
+type analysis struct {
+	opts *options
+
+	// stdout receives results printed as text, it discards them if results
+	// are printed as JSON once the subcommand is done
+	stdout io.Writer
+
+	records []complexity.Record
+	derived complexity.Derived
+
+	// datasetKey identifies records in cache keys, it is empty if they are not cached
+	datasetKey string
+
+	targetBlockDelay     uint64
+	targetComplexityRate commonfee.Dimensions
+	quantileTargets      []complexity.QuantileTargets
+	maxComplexities      commonfee.Dimensions
+	topBlocks            [commonfee.NumDimensions][]complexity.TopBlock
+
+	topPeaks      [][]complexity.Peak
+	totalGasPeaks []complexity.Peak
+
+	// window holds the records around the selected peak,
+	// from height low up to height up
+	window  []complexity.Record
+	low, up uint64
+
+	allFeeRates []complexity.FeeData
+	feeTraces   []feeTrace
+	feeReports  []FeeReport
+
+	// datasetFees holds fees computed with the first fee config over all records
+	datasetFees  []complexity.FeeData
+	penalties    []complexity.PenaltyPeriod
+	verification complexity.FeeVerification
+
+	// idsByHeight indexes block IDs for explorer links, built on first use
+	idsByHeight map[uint64]ids.ID
+}
+
+// loadRecords reads, validates and filters input records
+func loadRecords(ctx context.Context, o *options) *analysis {
+	records, digests := readInput(ctx, o)
+	if o.timestampsPath != "" {
+		times, err := readTimestamps(o.timestampsPath)
+		if err != nil {
+			fatal(err)
+		}
+		backfilled, reliable := complexity.BackfillTimes(records, times, o.chain.minHeight)
+		slog.Info("backfilled block timestamps", "records", backfilled, "first_height", reliable, "previous", o.chain.minHeight)
+		o.chain.minHeight = reliable
+	}
+	records = cleanRecords(records, o)
+	if err := complexity.ValidateOrdering(records); err != nil {
+		fatal(err)
+	}
+	if o.minTime != 0 || o.maxTime != math.MaxUint64 {
+		records = complexity.FilterRecordsByTime(records, o.minTime, o.maxTime)
+		slog.Info("filtered records by time", "from", o.fromTime, "to", o.toTime, "records", len(records))
+		if len(records) == 0 {
+			fatal(fmt.Errorf("no records between %s and %s", o.fromTime, o.toTime))
+		}
+	}
+	if o.minHeight != 0 || o.maxHeight != math.MaxUint64 {
+		records = complexity.FilterRecordsByHeight(records, o.minHeight, o.maxHeight)
+		slog.Info("filtered records by height", "min", o.minHeight, "max", o.maxHeight, "records", len(records))
+		if len(records) == 0 {
+			fatal(fmt.Errorf("no records between heights %d and %d", o.minHeight, o.maxHeight))
+		}
+	}
+	if gaps := complexity.FindHeightGaps(records); len(gaps) > 0 {
+		missing := uint64(0)
+		for _, g := range gaps {
+			missing += g.To - g.From + 1
+			slog.Debug("height gap", "from", g.From, "to", g.To)
+		}
+		slog.Warn("found height gaps", "count", len(gaps), "missing", missing, "first", fmt.Sprintf("%+v", gaps[0]))
+	}
+	records = resampleRecords(records, o)
+	records = gasRecords(records, o)
+
+	// fee replays are cached only if the whole input can be digested
+	datasetKey := ""
+	if digests != nil {
+		timestampsDigest := ""
+		if o.timestampsPath != "" {
+			var err error
+			if timestampsDigest, err = fileDigest(o.timestampsPath); err != nil {
+				fatal(fmt.Errorf("failed reading timestamps %s: %w", o.timestampsPath, err))
+			}
+		}
+		datasetKey = cacheKey("dataset", digests, o.cols, o.onError, o.cleanMode, o.outlierFactor, timestampsDigest, o.minTime, o.maxTime, o.minHeight, o.maxHeight, o.resampleSpec, o.units, o.gasWeights)
+	}
+
+	var stdout io.Writer = os.Stdout
+	if o.output == outputJSON {
+		stdout = io.Discard
+	}
+	return &analysis{
+		opts:       o,
+		stdout:     stdout,
+		datasetKey: datasetKey,
+		records:    records,
+		// traces shared by target and peaks analyses
+		derived: complexity.Derive(records),
+	}
+}
+
+// readInput reads records from the node at -rpc or the block store at -db if set,
+// from -csv otherwise. Digests of -csv files are returned too, see readCsvFiles;
+// they are nil for other inputs, which are not cached.
+func readInput(ctx context.Context, o *options) ([]complexity.Record, []string) {
+	if o.dbPath != "" {
+		store, err := openBlockStore(o.dbPath, false)
+		if err != nil {
+			fatal(err)
+		}
+		defer store.close()
+		records, err := store.query(ctx, o.minHeight, o.maxHeight, o.minTime, o.maxTime)
+		if err != nil {
+			fatal(fmt.Errorf("failed reading block store %s: %w", o.dbPath, err))
+		}
+		slog.Info("read block store", "path", o.dbPath, "records", len(records))
+		return records, nil
+	}
+	if o.rpcURI != "" {
+		records, err := fetchRecords(ctx, o)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Info("fetched records", "node", o.rpcURI, "records", len(records))
+		return records, nil
+	}
+
+	paths, err := expandInputPaths(strings.Split(o.csvPaths, ","))
+	if err != nil {
+		fatal(err)
+	}
+	return readCsvFiles(ctx, paths, o.cols, o.onError, o.cache)
+}
+
+// cleanRecords checks [records] for implausible values, handling them as the clean mode tells,
+// and writes those found to the outliers file, if any
+func cleanRecords(records []complexity.Record, o *options) []complexity.Record {
+	if o.cleanMode == complexity.CleanOff {
+		return records
+	}
+	limits, hasLimits := complexity.OutlierLimits(records, o.outlierFactor)
+	cleaned, outliers := complexity.CleanRecords(records, o.cleanMode, limits, hasLimits)
+	if len(outliers) > 0 {
+		slog.Warn("found implausible records", "count", len(outliers), "mode", o.cleanMode, "kept", len(cleaned), "first", fmt.Sprintf("%+v", outliers[0]))
+	} else {
+		slog.Info("found no implausible records", "records", len(records))
+	}
+	if o.outliersPath != "" {
+		if err := writeOutliersCSV(o.outliersPath, outliers); err != nil {
+			fatal(err)
+		}
+	}
+	return cleaned
+}
+
+// resampleBlockDelay resamples records by their median block delay, see -resample
+const resampleBlockDelay = "block-delay"
+
+// resampleRecords aggregates [records] into the fixed time buckets -resample asks for, if any
+func resampleRecords(records []complexity.Record, o *options) []complexity.Record {
+	if o.resampleWidth == 0 && !o.resampleByDelay {
+		return records
+	}
+	if o.resampleByDelay {
+		o.resampleWidth = max(1, complexity.Summarize(records).MedianBlockDelay)
+	}
+	res := complexity.Resample(records, o.resampleWidth)
+	slog.Info("resampled records", "width", time.Duration(o.resampleWidth)*time.Second, "records", len(records), "buckets", len(res))
+	return res
+}
+
+// Units records are analyzed in, see -units
+const (
+	unitsComplexity = "complexity"
+	unitsGas        = "gas"
+)
+
+var unitModes = []string{unitsComplexity, unitsGas}
+
+// unitWeights leave gas as is, once records are converted into gas
+var unitWeights = func() commonfee.Dimensions {
+	var res commonfee.Dimensions
+	for d := range res {
+		res[d] = 1
+	}
+	return res
+}()
+
+// gasRecords converts [records] into gas, as -units asks. Fee configs then weigh
+// dimensions evenly, since records already account for weights, so that fees are unchanged
+// as long as configs share the conversion weights.
+func gasRecords(records []complexity.Record, o *options) []complexity.Record {
+	if o.units != unitsGas {
+		return records
+	}
+	for i, c := range o.feeCfgs {
+		if c.cfg.Weights != o.gasWeights {
+			slog.Warn("fee config weights differ from gas weights, fees follow gas weights", "config", c.name, "weights", c.cfg.Weights, "gas_weights", o.gasWeights)
+		}
+		o.feeCfgs[i].cfg.Weights = unitWeights
+	}
+	slog.Info("converted records into gas", "weights", o.gasWeights)
+	return complexity.ToGas(records, o.gasWeights)
+}
+
+func (a *analysis) printStats() {
+	stats := complexity.Summarize(a.records)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		fmt.Fprintf(a.stdout, "%s stats: %+v\n", complexity.DimensionStrings[d], stats.Dimensions[d])
+	}
+	fmt.Fprintf(a.stdout, "median block delay: %v\n", stats.MedianBlockDelay)
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+func (a *analysis) printHistograms(out plotOutput) {
+	if out.format == htmlFormat {
+		if err := printHTMLHistograms(out, a.records, a.opts.bins); err != nil {
+			handleError(a.opts.onError, err)
+		}
+		return
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		data := complexity.PullComplexityFromRecords(a.records, d)
+		if err := printHistogram(out, data, d, a.opts.bins); err != nil {
+			handleError(a.opts.onError, err)
+		}
+	}
+}
+
+func (a *analysis) computeTargets() {
+	var err error
+	a.targetBlockDelay, a.targetComplexityRate, err = complexity.TargetComplexityRate(
+		a.derived,
+		a.opts.chain.minHeight, /*skip blocks predating analyzed rules*/
+		a.opts.quantile,
+		a.opts.blockDelayQuantile,
+	)
+	if err != nil {
+		fatal(err)
+	}
+	if a.targetBlockDelay < a.opts.minBlockDelay {
+		slog.Warn("target block delay below floor, falling back to floor", "delay", a.targetBlockDelay, "floor", a.opts.minBlockDelay)
+		a.targetBlockDelay = a.opts.minBlockDelay
+	}
+	fmt.Fprintf(a.stdout, "target block delay: %v\n", a.targetBlockDelay)
+	fmt.Fprintf(a.stdout, "target complexities: %v\n", a.targetComplexityRate)
+	fmt.Fprintf(a.stdout, "\n")
+
+	if len(a.opts.quantiles) > 0 {
+		a.quantileTargets, err = complexity.TargetsByQuantile(a.derived, a.opts.chain.minHeight, a.opts.quantiles)
+		if err != nil {
+			fatal(err)
+		}
+		printQuantileTable(a.stdout, a.quantileTargets)
+	}
+
+	// historical max complexity. This may be way more than
+	// the max complexity we would like to allow post E upgrade
+	a.maxComplexities = complexity.MaxComplexity(a.records)
+	fmt.Fprintf(a.stdout, "max complexities: %v\n", a.maxComplexities)
+	fmt.Fprintf(a.stdout, "\n")
+
+	if a.opts.topBlocks > 0 {
+		a.topBlocks = complexity.TopComplexityBlocks(a.records, a.opts.topBlocks)
+		for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+			for i, b := range a.topBlocks[d] {
+				fmt.Fprintf(a.stdout, "top %s block n° %d: %d, height %d, time %d, ID %s%s\n", complexity.DimensionStrings[d], i+1, b.Complexity, b.Height, b.Time, b.ID, formatLink(blockURL(a.opts.explorerURL, b.ID)))
+			}
+		}
+		fmt.Fprintf(a.stdout, "\n")
+	}
+
+	exceedances := complexity.CapacityExceedances(a.derived, a.maxComplexities, a.targetComplexityRate)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		fmt.Fprintf(a.stdout, "%s blocks above target capacity: %d (%.2f%%)\n", complexity.DimensionStrings[d], exceedances[d].Count, 100*exceedances[d].Fraction)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+func (a *analysis) findPeaks(ctx context.Context) {
+	var (
+		o     = a.opts
+		start = time.Now()
+		err   error
+	)
+	a.topPeaks, err = complexity.FindAllDimensionPeaks(ctx, o.detector, a.derived, a.maxComplexities, a.targetComplexityRate, topPeaksCount, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		fatal(err)
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		slog.Debug("found peaks", "dimension", complexity.DimensionStrings[d], "count", len(a.topPeaks[d]))
+	}
+	slog.Info("peaks analysis done", "elapsed", time.Since(start))
+
+	// find top peaks of the weighted gas, which is what the fee mechanism charges
+	feeCfg := o.feeCfg()
+	a.totalGasPeaks, err = complexity.FindTotalGasPeaks(ctx, o.detector, a.records, feeCfg.Weights, uint64(feeCfg.TargetGasPerSecond), topPeaksCount, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		fatal(err)
+	}
+	if len(a.totalGasPeaks) > 0 {
+		fmt.Fprintf(a.stdout, "top total gas peak: %s\n", a.formatPeak(a.totalGasPeaks[len(a.totalGasPeaks)-1]))
+		fmt.Fprintf(a.stdout, "\n")
+	}
+	a.exportPeaks(ctx)
+}
+
+// exportPeaks writes the top peaks of each dimension and of the total gas or,
+// if requested, all detected ones
+func (a *analysis) exportPeaks(ctx context.Context) {
+	o := a.opts
+	if o.peaksOutPath == "" {
+		return
+	}
+
+	dimensionPeaks, totalGasPeaks := a.topPeaks, a.totalGasPeaks
+	if o.allPeaks {
+		var err error
+		dimensionPeaks, err = complexity.FindAllDimensionPeaks(ctx, o.detector, a.derived, a.maxComplexities, a.targetComplexityRate, math.MaxInt, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+		if err != nil {
+			fatal(err)
+		}
+		feeCfg := o.feeCfg()
+		totalGasPeaks, err = complexity.FindTotalGasPeaks(ctx, o.detector, a.records, feeCfg.Weights, uint64(feeCfg.TargetGasPerSecond), math.MaxInt, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+		if err != nil {
+			fatal(err)
+		}
+	}
+
+	entries := make([]peakEntry, 0)
+	for d, peaks := range dimensionPeaks {
+		entries = append(entries, peakEntries(complexity.DimensionStrings[d], peaks, o.explorerURL)...)
+	}
+	entries = append(entries, peakEntries(totalGasName, totalGasPeaks, o.explorerURL)...)
+	if err := writePeaks(o.peaksOutPath, entries); err != nil {
+		fatal(err)
+	}
+	slog.Info("exported peaks", "path", o.peaksOutPath, "peaks", len(entries))
+}
+
+func (a *analysis) printPeaks() {
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		for i, p := range topPeaksFirst(a.topPeaks[d]) {
+			fmt.Fprintf(a.stdout, "peak n° %d, dimension %s: %s\n", i+1, complexity.DimensionStrings[d], a.formatPeak(p))
+			overlaps := complexity.PeakOverlaps(a.derived, p, a.targetComplexityRate)
+			for other, overlap := range overlaps {
+				if other == int(d) || overlap.BlocksAboveTarget == 0 {
+					continue
+				}
+				fmt.Fprintf(a.stdout, "    %s above target in %d blocks, excess complexity %d, max utilization %.2f%%\n",
+					complexity.DimensionStrings[other], overlap.BlocksAboveTarget, overlap.ExcessComplexity, overlap.MaxUtilization)
+			}
+		}
+		fmt.Fprintf(a.stdout, "\n")
+	}
+	for i, p := range topPeaksFirst(a.totalGasPeaks) {
+		fmt.Fprintf(a.stdout, "peak n° %d, %s gas: %s\n", i+1, totalGasName, a.formatPeak(p))
+	}
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+// printQuantileTable prints one aligned row per quantile, with its block delay
+// and complexity rates
+func printQuantileTable(w io.Writer, targets []complexity.QuantileTargets) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "quantile\tblock_delay")
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		fmt.Fprintf(tw, "\t%s", snakeCase(complexity.DimensionStrings[d]))
+	}
+	fmt.Fprintf(tw, "\n")
+	for _, t := range targets {
+		fmt.Fprintf(tw, "p%v\t%d", 100*t.Quantile, t.BlockDelay)
+		for _, rate := range t.Rates {
+			fmt.Fprintf(tw, "\t%d", rate)
+		}
+		fmt.Fprintf(tw, "\n")
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "\n")
+}
+
+// formatPeak prints [p] without its block IDs, which are too many to be read on a terminal.
+// The explorer page of its first block is linked instead.
+func (a *analysis) formatPeak(p complexity.Peak) string {
+	return fmt.Sprintf(
+		"start height %d, blocks %d, start time %d, duration %ds, cumulated complexity %d%s",
+		p.StartHeight, p.BlocksCount, p.LowTimestamp, p.ElapsedTime, p.CumulatedComplexity, formatLink(peakURL(a.opts.explorerURL, p)),
+	)
+}
+
+// formatLink appends [url] to printed lines, unless links are disabled
+func formatLink(url string) string {
+	if url == "" {
+		return ""
+	}
+	return ", " + url
+}
+
+// blockIDs returns the IDs of all records, keyed by height
+func (a *analysis) blockIDs() map[uint64]ids.ID {
+	if a.idsByHeight == nil {
+		a.idsByHeight = make(map[uint64]ids.ID, len(a.records))
+		for _, r := range a.records {
+			a.idsByHeight[r.Height] = r.ID
+		}
+	}
+	return a.idsByHeight
+}
+
+// selectWindow picks the records around the peak of the chosen dimension, or of the total gas.
+// The peak containing the chosen height or time is picked if any is set, the peak of the chosen rank otherwise.
+// Peaks containing a height or time are searched among all peaks, not just top ones.
+func (a *analysis) selectWindow(ctx context.Context) {
+	var (
+		o              = a.opts
+		dimension      = o.dimension
+		dimensionPeaks = a.topPeaks[dimension]
+		name           = complexity.DimensionStrings[dimension]
+	)
+	if o.totalGasWindow {
+		dimensionPeaks, name = a.totalGasPeaks, totalGasName
+	}
+
+	var targetPeak complexity.Peak
+	switch {
+	case o.peakAtHeight != 0 || o.peakTime != 0:
+		peaks, err := a.allPeaks(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		idx := slices.IndexFunc(peaks, func(p complexity.Peak) bool {
+			if o.peakAtHeight != 0 {
+				return p.ContainsHeight(o.peakAtHeight)
+			}
+			return p.ContainsTime(o.peakTime)
+		})
+		if idx < 0 && o.peakAtHeight != 0 {
+			fatal(fmt.Errorf("no %s peak contains height %d", name, o.peakAtHeight))
+		}
+		if idx < 0 {
+			fatal(fmt.Errorf("no %s peak spans time %s", name, o.peakAtTime))
+		}
+		targetPeak = peaks[idx]
+		// peaks are sorted increasingly
+		slog.Info("found peak", "dimension", name, "rank", len(peaks)-idx, "peaks", len(peaks))
+	default:
+		if len(dimensionPeaks) < o.peakIndex {
+			fatal(fmt.Errorf("peak n° %d requested, but only %d %s peaks found", o.peakIndex, len(dimensionPeaks), name))
+		}
+		targetPeak = dimensionPeaks[len(dimensionPeaks)-o.peakIndex]
+	}
+
+	var (
+		minHeight = targetPeak.StartHeight + 1
+		maxHeight = minHeight + uint64(targetPeak.BlocksCount)
+		low       = minHeight - min(minHeight, o.marginBefore)
+		up        = maxHeight + min(math.MaxUint64-maxHeight, o.marginAfter)
+	)
+	a.low, a.up = low, up
+	a.window = complexity.FilterRecordsByHeight(a.records, low, up)
+	slog.Info("selected peak window", "dimension", name, "low", low, "up", up, "records", len(a.window))
+}
+
+// allPeaks returns all peaks of the chosen dimension, or of the total gas,
+// sorted increasingly as top peaks are
+func (a *analysis) allPeaks(ctx context.Context) ([]complexity.Peak, error) {
+	o := a.opts
+	if o.totalGasWindow {
+		feeCfg := o.feeCfg()
+		return complexity.FindTotalGasPeaks(ctx, o.detector, a.records, feeCfg.Weights, uint64(feeCfg.TargetGasPerSecond), math.MaxInt, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	}
+	trace := complexity.MovingAverage(a.derived.Traces[o.dimension], o.smoothWindow)
+	return complexity.FindPeaks(ctx, o.detector, a.derived.HeightsAndTimes, a.derived.IDs, trace, a.maxComplexities[o.dimension], a.targetComplexityRate[o.dimension], o.thresholdWindow, o.peakOrder)
+}
+
+// simulateThrottling simulates which blocks a gas cap would have rejected over the whole dataset
+func (a *analysis) simulateThrottling() {
+	throttleCfg := a.opts.feeCfg()
+	if a.opts.maxGasPerSecond != 0 {
+		throttleCfg.MaxGasPerSecond = commonfee.Gas(a.opts.maxGasPerSecond)
+	}
+	throttling := complexity.SimulateThrottling(a.records, throttleCfg)
+	fmt.Fprintf(a.stdout, "throttled blocks at %d gas per second: %d, excess gas: %d, ranges: %d\n", throttleCfg.MaxGasPerSecond, throttling.Blocks, throttling.ExcessGas, len(throttling.Ranges))
+	fmt.Fprintf(a.stdout, "\n")
+	if a.opts.throttleOutPath != "" {
+		if err := writeThrottlingCSV(a.opts.throttleOutPath, throttling.Ranges); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// computeFees calculates gas prices over the selected window, once per fee config
+func (a *analysis) computeFees(ctx context.Context) {
+	var (
+		o     = a.opts
+		denom = o.denom
+	)
+	if o.verifyOutPath != "" {
+		a.verifyFees(ctx)
+	}
+	if o.excessOutPath != "" {
+		a.trackExcessGas(ctx)
+	}
+
+	a.feeTraces = make([]feeTrace, 0, len(o.feeCfgs))
+	a.feeReports = make([]FeeReport, 0, len(o.feeCfgs))
+	for i, c := range o.feeCfgs {
+		slog.Debug("computing fees", "config", c.name, "params", fmt.Sprintf("%+v", c.cfg))
+		excessGas := a.initialExcess(ctx, c)
+		if excessGas > 0 {
+			fmt.Fprintf(a.stdout, "Initial excess gas %s: %d\n", c.name, excessGas)
+		}
+		start := time.Now()
+		feeRates, err := a.calculateFeeData(ctx, a.window, c.cfg, excessGas)
+		if err != nil {
+			fatal(err)
+		}
+		slog.Debug("fees computed", "config", c.name, "elapsed", time.Since(start))
+		if i == 0 {
+			a.allFeeRates = feeRates
+		}
+
+		var (
+			fees        = complexity.PullFees(feeRates, a.low /*up*/, a.window[len(a.window)-1].Height)
+			maxFee      = slices.Max(fees)
+			total, mean = complexity.TotalFees(fees)
+		)
+		fmt.Fprintf(a.stdout, "Max fee %s: %s %s\n", c.name, denom.format(maxFee), denom.label)
+		fmt.Fprintf(a.stdout, "Total fees %s: %s %s, mean fee per block: %s %s\n", c.name, denom.format(total), denom.label, denom.format(mean), denom.label)
+		fmt.Fprintf(a.stdout, "\n")
+
+		a.feeTraces = append(a.feeTraces, feeTrace{name: c.name, fees: fees, gasPrices: complexity.PullGasPrices(feeRates), excessGas: complexity.PullExcessGas(feeRates)})
+		a.feeReports = append(a.feeReports, FeeReport{
+			Config:       c.name,
+			Denomination: denom.name,
+			MaxFee:       maxFee,
+			TotalFees:    total,
+			MeanFee:      mean,
+
+			InitialExcessGas: uint64(excessGas),
+		})
+	}
+
+	if o.feeOutPath != "" {
+		if err := writeFeeData(o.feeOutPath, a.allFeeRates, denom, a.blockIDs(), o.explorerURL); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// initialExcess returns the excess gas fee replays of [c] over the window start from,
+// as -initial-excess tells. Starting from the midpoint of the top total gas peak
+// replays the whole dataset with [c] to find the excess gas accrued there.
+func (a *analysis) initialExcess(ctx context.Context, c namedFeeConfig) commonfee.Gas {
+	o := a.opts
+	if !o.initialExcessAtPeak {
+		return commonfee.Gas(o.initialExcessGas)
+	}
+	if len(a.totalGasPeaks) == 0 {
+		slog.Warn("no total gas peak, starting fee replay from no excess gas", "config", c.name)
+		return 0
+	}
+
+	fees, err := a.calculateFeeData(ctx, a.records, c.cfg, 0)
+	if err != nil {
+		fatal(err)
+	}
+	excessGas, height, ok := complexity.PeakMidpointExcess(fees, a.totalGasPeaks[len(a.totalGasPeaks)-1])
+	if !ok {
+		fatal(fmt.Errorf("failed finding excess gas of config %s, height %d: block not found", c.name, height))
+	}
+	slog.Info("starting fee replay from peak midpoint", "config", c.name, "height", height, "excess_gas", excessGas)
+	return excessGas
+}
+
+// calculateFeeData replays [records], a contiguous run of the analyzed records, with [cfg]
+// starting from [excessGas], see complexity.CalculateFeeDataFrom. Results are reused
+// from previous runs over the same dataset if cached.
+func (a *analysis) calculateFeeData(ctx context.Context, records []complexity.Record, cfg commonfee.Config, excessGas commonfee.Gas) ([]complexity.FeeData, error) {
+	key := ""
+	if a.datasetKey != "" && len(records) > 0 {
+		key = cacheKey("fees", a.datasetKey, records[0].Height, records[len(records)-1].Height, len(records), cfg, a.opts.denom.unit, excessGas)
+		var fees []complexity.FeeData
+		if a.opts.cache.load(key, &fees) {
+			return fees, nil
+		}
+	}
+
+	fees, err := complexity.CalculateFeeDataFrom(ctx, records, cfg, a.opts.denom.unit, excessGas)
+	if err != nil {
+		return nil, err
+	}
+	if key != "" {
+		a.opts.cache.store(key, fees)
+	}
+	return fees, nil
+}
+
+// verifyFees replays the whole dataset with the first fee config and compares
+// resulting fees with the observed ones, if the dataset carries them
+func (a *analysis) verifyFees(ctx context.Context) {
+	denom := a.opts.denom
+	verification, err := complexity.VerifyFees(a.records, a.simulateDataset(ctx), denom.unit)
+	if err != nil {
+		fatal(err)
+	}
+	if len(verification.Diffs) == 0 {
+		slog.Warn("no observed fees in dataset, skipping verification")
+		return
+	}
+
+	a.verification = verification
+
+	fmt.Fprintf(a.stdout, "verified %d blocks, fee RMSE: %s %s\n", len(verification.Diffs), denom.format(verification.RMSE), denom.label)
+	fmt.Fprintf(a.stdout, "revenue simulated: %s %s, observed: %s %s, delta: %s %s",
+		denom.format(verification.ComputedTotal), denom.label, denom.format(verification.ObservedTotal), denom.label, denom.format(verification.RevenueDelta()), denom.label)
+	if verification.ObservedTotal != 0 {
+		fmt.Fprintf(a.stdout, " (%.2f%%)", 100*verification.RevenueDelta()/verification.ObservedTotal)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+	fmt.Fprintf(a.stdout, "\n")
+	if err := writeFeeVerificationCSV(a.opts.verifyOutPath, verification, denom); err != nil {
+		fatal(err)
+	}
+}
+
+// simulateDataset replays the whole dataset with the first fee config,
+// once no matter how many stages need it
+func (a *analysis) simulateDataset(ctx context.Context) []complexity.FeeData {
+	if a.datasetFees != nil {
+		return a.datasetFees
+	}
+	fees, err := a.calculateFeeData(ctx, a.records, a.opts.feeCfg(), 0)
+	if err != nil {
+		fatal(err)
+	}
+	a.datasetFees = fees
+	return fees
+}
+
+// trackExcessGas exports excess gas over the whole dataset and summarizes
+// the periods gas price stays above its minimum after gas peaks
+func (a *analysis) trackExcessGas(ctx context.Context) {
+	var (
+		feeCfg = a.opts.feeCfg()
+		fees   = a.simulateDataset(ctx)
+	)
+	a.penalties = complexity.PenaltyPeriods(fees, feeCfg.MinGasPrice)
+
+	fmt.Fprintf(a.stdout, "periods above min gas price: %d\n", len(a.penalties))
+	if len(a.penalties) > 0 {
+		longest := slices.MaxFunc(a.penalties, func(p, q complexity.PenaltyPeriod) int {
+			return cmp.Compare(p.Duration(), q.Duration())
+		})
+		var total uint64
+		for _, p := range a.penalties {
+			total += p.Duration()
+		}
+		fmt.Fprintf(a.stdout, "longest period: %d seconds, heights [%d, %d], max excess gas: %d\n", longest.Duration(), longest.From.Height, longest.To.Height, longest.MaxExcessGas)
+		fmt.Fprintf(a.stdout, "total time above min gas price: %d seconds\n", total)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+
+	if err := writeFeeData(a.opts.excessOutPath, fees, a.opts.denom, a.blockIDs(), a.opts.explorerURL); err != nil {
+		fatal(err)
+	}
+}
+
+// report collects the results of the stages run so far
+func (a *analysis) report() Report {
+	r := Report{
+		TargetBlockDelay: a.targetBlockDelay,
+		TopTotalGasPeaks: topPeaksFirst(a.totalGasPeaks),
+		Fees:             a.feeReports,
+	}
+	if a.penalties != nil {
+		r.PenaltyPeriods = a.penalties
+	}
+	if len(a.verification.Diffs) > 0 {
+		r.Verification = &VerificationReport{
+			Config:        a.opts.feeCfgs[0].name,
+			Denomination:  a.opts.denom.name,
+			Blocks:        len(a.verification.Diffs),
+			RMSE:          a.verification.RMSE,
+			ComputedTotal: a.verification.ComputedTotal,
+			ObservedTotal: a.verification.ObservedTotal,
+			RevenueDelta:  a.verification.RevenueDelta(),
+		}
+	}
+	if a.targetComplexityRate != (commonfee.Dimensions{}) {
+		r.TargetComplexityRate = dimensionsByName(a.targetComplexityRate)
+	}
+	for _, t := range a.quantileTargets {
+		r.TargetsByQuantile = append(r.TargetsByQuantile, QuantileReport{
+			Quantile:             t.Quantile,
+			BlockDelay:           t.BlockDelay,
+			TargetComplexityRate: dimensionsByName(t.Rates),
+		})
+	}
+	if a.maxComplexities != (commonfee.Dimensions{}) {
+		r.MaxComplexities = dimensionsByName(a.maxComplexities)
+	}
+	if a.opts.topBlocks > 0 && a.maxComplexities != (commonfee.Dimensions{}) {
+		r.TopBlocks = make(map[string][]complexity.TopBlock, commonfee.NumDimensions)
+		for d, blocks := range a.topBlocks {
+			r.TopBlocks[complexity.DimensionStrings[d]] = blocks
+		}
+	}
+	if a.topPeaks != nil {
+		r.TopPeaks = peaksByDimension(a.topPeaks)
+		r.TopPeakOverlaps = make(map[string][]map[string]complexity.DimensionOverlap, commonfee.NumDimensions)
+		for d, peaks := range a.topPeaks {
+			name := complexity.DimensionStrings[d]
+			for _, p := range topPeaksFirst(peaks) {
+				overlaps := complexity.PeakOverlaps(a.derived, p, a.targetComplexityRate)
+				byName := make(map[string]complexity.DimensionOverlap, commonfee.NumDimensions)
+				for other, overlap := range overlaps {
+					byName[complexity.DimensionStrings[other]] = overlap
+				}
+				r.TopPeakOverlaps[name] = append(r.TopPeakOverlaps[name], byName)
+			}
+		}
+	}
+	return r
+}
+
+func (a *analysis) writeReport() {
+	if a.opts.reportPath == "" {
+		return
+	}
+	if err := writeReport(a.opts.reportPath, a.report()); err != nil {
+		fatal(err)
+	}
+}
+
+// printOutput prints results as a JSON document, if requested.
+// Text results are printed as stages run instead.
+func (a *analysis) printOutput() {
+	if a.opts.output != outputJSON {
+		return
+	}
+	r := a.report()
+	r.FeeTrace = a.allFeeRates
+	if err := printJSON(r); err != nil {
+		fatal(err)
+	}
+}
+
+// utilizations returns target and utilization traces over the selected window,
+// indexed by dimension
+func (a *analysis) utilizations() ([][]uint64, [][]float64) {
+	var (
+		targets      = make([][]uint64, commonfee.NumDimensions)
+		utilizations = make([][]float64, commonfee.NumDimensions)
+		err          error
+	)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		targets[d] = complexity.TargetComplexityTrace(a.window, a.maxComplexities[d], a.targetComplexityRate[d], a.opts.sameTime)
+		utilizations[d], err = complexity.Utilization(a.window, targets[d], d)
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if a.opts.utilizationOutPath != "" {
+		if err := writeUtilizationCSV(a.opts.utilizationOutPath, a.window, utilizations); err != nil {
+			fatal(err)
+		}
+	}
+	return targets, utilizations
+}
+
+// plot draws the selected window
+func (a *analysis) plot(out plotOutput, targets [][]uint64, utilizations [][]float64) {
+	var (
+		o      = a.opts
+		feeCfg = o.feeCfg()
+		r      = a.window
+
+		// plots ranges of complexities
+		x = buildXAxis(complexity.PullTimesHeightsFromRecords(r), o.xAxisMode, o.maxXGap)
+
+		gasPrices = complexity.PullGasPrices(a.allFeeRates)
+
+		dimensionMarks [][]complexity.Peak
+		totalGasMarks  plotter.XYs
+		priceMarks     plotter.XYs
+	)
+	totalGas := complexity.PullGasFromRecords(r, feeCfg.Weights)
+	totalTarget := complexity.TargetComplexityTrace(r, slices.Max(totalGas), uint64(feeCfg.TargetGasPerSecond), o.sameTime)
+	if out.format == htmlFormat {
+		if err := printHTMLCharts(out, x, r, targets, totalGas, totalTarget, a.feeTraces, o.denom); err != nil {
+			handleError(o.onError, err)
+		}
+		return
+	}
+
+	if o.annotatePeaks {
+		dimensionMarks = a.topPeaks
+	}
+	a.tolerate(printImages(out, x, r, targets, utilizations, dimensionMarks))
+	if o.panel {
+		a.tolerate(printDimensionsPanel(out, x, r, targets))
+	}
+	a.tolerate(printFeeImage(out, x, a.feeTraces, o.denom))
+
+	if o.annotatePeaks {
+		totalGasMarks = peakMarks(x, r, totalGas, a.totalGasPeaks)
+		priceMarks = peakMarks(x, r, gasPrices, a.totalGasPeaks)
+	}
+	a.tolerate(printGasImage(out, x, totalGas, totalTarget, totalGasMarks, totalGasName))
+	a.tolerate(printGasPriceImage(out, x, a.feeTraces, priceMarks))
+	a.tolerate(printExcessGasImage(out, x, complexity.PullExcessGas(a.allFeeRates), "excess_gas"))
+	if a.datasetFees != nil {
+		a.tolerate(printExcessGasImage(out, buildXAxis(complexity.PullTimesHeightsFromRecords(a.records), o.xAxisMode, o.maxXGap), complexity.PullExcessGas(a.datasetFees), "excess_gas_dataset"))
+	}
+	if len(a.verification.Diffs) > 0 {
+		a.tolerate(printFeeComparisonImage(out, a.verification.Diffs, o.denom, o.xAxisMode, o.maxXGap))
+	}
+}
+
+// plotFees overlays fees and gas prices of all fee configs over the selected window,
+// one line per config, so that configs can be compared over the same peak
+func (a *analysis) plotFees(out plotOutput) {
+	o := a.opts
+	x := buildXAxis(complexity.PullTimesHeightsFromRecords(a.window), o.xAxisMode, o.maxXGap)
+	if out.format == htmlFormat {
+		a.tolerate(writeHTMLCharts(out, "fees", "fee configs comparison", feeCharts(x, blockHover(a.window), a.feeTraces, o.denom)))
+		return
+	}
+	a.tolerate(printFeeImage(out, x, a.feeTraces, o.denom))
+	a.tolerate(printGasPriceImage(out, x, a.feeTraces, nil))
+}
+
+// tolerate handles [err], if any, as the -on-error flag tells
+func (a *analysis) tolerate(err error) {
+	if err != nil {
+		handleError(a.opts.onError, err)
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"unicode"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+	"gonum.org/v1/plot/vg/draw"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var errUnevenXY = errors.New("uneven x and y")
+
+// plotFormats lists the image formats plot.Save is able to infer from file extension,
+// followed by [htmlFormat]
+var plotFormats = []string{"eps", "jpg", "jpeg", "pdf", "png", "svg", "tex", "tif", "tiff", htmlFormat}
+
+const (
+	xAxisHeight    = "height"
+	xAxisTime      = "time"
+	xAxisSynthetic = "synthetic"
+)
+
+var xAxisModes = []string{xAxisHeight, xAxisTime, xAxisSynthetic}
+
+// xAxis holds the values along which traces are plotted
+type xAxis struct {
+	label  string
+	values []float64
+}
+
+// buildXAxis returns the x values for [points] according to [mode]:
+//   - height spaces data points equally, even if blocks are pretty distant in time.
+//   - time places data points at their timestamp. Blocks sharing a timestamp are spread
+//     evenly within its second, so that they do not stack on a vertical line.
+//     It may also show a spike in target capacity if blocks are far in time.
+//   - synthetic picks the timestamp but artificially increments it if consecutive
+//     blocks have the same time, i.e. x[i] = x[i-1] + max(dHeight, dTime).
+//
+// With time and synthetic modes, gaps among consecutive blocks longer than [maxGap]
+// seconds are shrunk to [maxGap], so that idle periods do not squash the rest of
+// the plot. A zero [maxGap] keeps gaps as they are.
+// Assumes [mode] is one of [xAxisModes]
+func buildXAxis(points []complexity.BlkHeightTime, mode string, maxGap uint64) xAxis {
+	values := make([]float64, len(points))
+	gap := func(i int) uint64 {
+		dTime := complexity.TimeDelta(points[i-1].Time, points[i].Time)
+		if maxGap > 0 {
+			dTime = min(dTime, maxGap)
+		}
+		return dTime
+	}
+	gapsLabel := ""
+	if maxGap > 0 {
+		gapsLabel = fmt.Sprintf(", gaps capped at %ds", maxGap)
+	}
+
+	switch mode {
+	case xAxisTime:
+		for start := 0; start < len(points); {
+			end := start + 1
+			for end < len(points) && points[end].Time == points[start].Time {
+				end++
+			}
+
+			base := float64(points[start].Time)
+			if start > 0 {
+				base = values[start-1] - fractional(values[start-1]) + float64(gap(start))
+			}
+			for i := start; i < end; i++ {
+				values[i] = base + float64(i-start)/float64(end-start)
+			}
+			start = end
+		}
+		return xAxis{label: "block time (unix" + gapsLabel + ")", values: values}
+
+	case xAxisSynthetic:
+		if len(points) > 0 {
+			values[0] = float64(points[0].Time)
+		}
+		for i := 1; i < len(points); i++ {
+			// unsorted heights would underflow, as unsorted times would in TimeDelta
+			dHeight := uint64(0)
+			if points[i].Height > points[i-1].Height {
+				dHeight = points[i].Height - points[i-1].Height
+			}
+			values[i] = values[i-1] + float64(max(dHeight, gap(i)))
+		}
+		return xAxis{label: "synthetic time" + gapsLabel, values: values}
+
+	default:
+		for i, p := range points {
+			values[i] = float64(p.Height)
+		}
+		return xAxis{label: "block heights", values: values}
+	}
+}
+
+// fractional returns the fractional part of non-negative [v]
+func fractional(v float64) float64 {
+	return v - math.Floor(v)
+}
+
+// plotOutput determines where plots are saved and in which format
+type plotOutput struct {
+	dir    string
+	format string
+}
+
+func newPlotOutput(dir, format string) (plotOutput, error) {
+	if !slices.Contains(plotFormats, format) {
+		return plotOutput{}, fmt.Errorf("unsupported plot format %q, supported formats are %v", format, plotFormats)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return plotOutput{}, fmt.Errorf("failed creating output dir %s: %w", dir, err)
+	}
+	return plotOutput{
+		dir:    dir,
+		format: format,
+	}, nil
+}
+
+// path returns the file path of the plot named [name]
+func (o plotOutput) path(name string) string {
+	return filepath.Join(o.dir, name+"."+o.format)
+}
+
+// printImages assumes [targets], [utilizations] and [peaks] are indexed by dimension.
+// Peaks are marked on gas plots unless [peaks] is nil.
+func printImages(out plotOutput, x xAxis, r []complexity.Record, targets [][]uint64, utilizations [][]float64, peaks [][]complexity.Peak) error {
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		var (
+			data  = complexity.PullComplexityFromRecords(r, d)
+			marks plotter.XYs
+		)
+		if peaks != nil {
+			marks = peakMarks(x, r, data, peaks[d])
+		}
+		if err := printGasImage(out, x, data, targets[d], marks, complexity.DimensionStrings[d]); err != nil {
+			return err
+		}
+		if err := printUtilizationImage(out, x, utilizations[d], d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// peakMarks returns the points of [data] belonging to any of [peaks],
+// to be overlaid on the trace they were detected from
+func peakMarks(x xAxis, r []complexity.Record, data []uint64, peaks []complexity.Peak) plotter.XYs {
+	res := make(plotter.XYs, 0)
+	for i, record := range r {
+		for _, peak := range peaks {
+			if peak.ContainsHeight(record.Height) {
+				res = append(res, plotter.XY{X: x.values[i], Y: float64(data[i])})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// feeTrace holds the fees, gas prices and excess gas computed with a given fee config
+type feeTrace struct {
+	name      string
+	fees      []float64
+	gasPrices []uint64
+	excessGas []uint64
+}
+
+// printFeeImage plots fees of all [traces], one line per fee config,
+// into fee file
+func printFeeImage(out plotOutput, x xAxis, traces []feeTrace, denom denomination) error {
+	p := plot.New()
+	p.Title.Text = "fee"
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "fee (" + denom.label + ")"
+	p.Y.Tick.Marker = feeTicks{denom: denom}
+
+	lines := make([]interface{}, 0, 2*len(traces))
+	for _, t := range traces {
+		pts, err := traceFloat64ToPlotter(x.values, t.fees)
+		if err != nil {
+			return fmt.Errorf("failed plotting %s fees: %w", t.name, err)
+		}
+		lines = append(lines, t.name, pts)
+	}
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return err
+	}
+
+	path := out.path("fee")
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printFeeComparisonImage plots simulated vs observed fees of verified blocks
+// into fee_comparison file. The x axis is built from [diffs] themselves, rather
+// than reused from the analyzed window, since they may cover a different range.
+func printFeeComparisonImage(out plotOutput, diffs []complexity.FeeDiff, denom denomination, xMode string, maxGap uint64) error {
+	points := make([]complexity.BlkHeightTime, len(diffs))
+	for i, d := range diffs {
+		points[i] = d.BlkHeightTime
+	}
+	x := buildXAxis(points, xMode, maxGap)
+
+	p := plot.New()
+	p.Title.Text = "simulated vs observed fee"
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "fee (" + denom.label + ")"
+	p.Y.Tick.Marker = feeTicks{denom: denom}
+
+	var (
+		computed = make(plotter.XYs, len(diffs))
+		observed = make(plotter.XYs, len(diffs))
+	)
+	for i, d := range diffs {
+		computed[i] = plotter.XY{X: x.values[i], Y: d.Computed}
+		observed[i] = plotter.XY{X: x.values[i], Y: d.Observed}
+	}
+	if err := plotutil.AddLines(p, "simulated", computed, "observed", observed); err != nil {
+		return err
+	}
+
+	path := out.path("fee_comparison")
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printDimensionsPanel plots consumed vs target complexity of all dimensions
+// as stacked panels of a single gas_all_dimensions file, sharing the x axis,
+// so that correlations across dimensions are visible at a glance.
+// Assumes [targets] is indexed by dimension.
+func printDimensionsPanel(out plotOutput, x xAxis, r []complexity.Record, targets [][]uint64) error {
+	plots := make([][]*plot.Plot, commonfee.NumDimensions)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		p := plot.New()
+		p.Title.Text = complexity.DimensionStrings[d]
+		p.Y.Label.Text = "gas consumed"
+		if d == commonfee.NumDimensions-1 {
+			p.X.Label.Text = x.label
+		}
+
+		consumed, err := traceUint64ToPlotter(x.values, complexity.PullComplexityFromRecords(r, d))
+		if err != nil {
+			return fmt.Errorf("failed plotting %s gas: %w", complexity.DimensionStrings[d], err)
+		}
+		target, err := traceUint64ToPlotter(x.values, targets[d])
+		if err != nil {
+			return fmt.Errorf("failed plotting %s target: %w", complexity.DimensionStrings[d], err)
+		}
+		if err := plotutil.AddLinePoints(p, "consumed gas", consumed, "target gas", target); err != nil {
+			return err
+		}
+		plots[d] = []*plot.Plot{p}
+	}
+
+	c, err := draw.NewFormattedCanvas(6*vg.Inch, 12*vg.Inch, out.format)
+	if err != nil {
+		return fmt.Errorf("failed creating %s canvas: %w", out.format, err)
+	}
+	tiles := draw.Tiles{
+		Rows:      commonfee.NumDimensions,
+		Cols:      1,
+		PadY:      vg.Millimeter * 4,
+		PadTop:    vg.Millimeter * 2,
+		PadBottom: vg.Millimeter * 2,
+		PadLeft:   vg.Millimeter * 2,
+		PadRight:  vg.Millimeter * 2,
+	}
+	canvases := plot.Align(plots, tiles, draw.New(c))
+	for i := range plots {
+		plots[i][0].Draw(canvases[i][0])
+	}
+
+	path := out.path("gas_all_dimensions")
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := c.WriteTo(f); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed closing %s: %w", path, err)
+	}
+	return nil
+}
+
+// printGasPriceImage plots the gas price, in nAvax per unit of gas, of all [traces],
+// one line per fee config, into price file. [peakMarks], if any, show how the price
+// reacts to gas peaks.
+func printGasPriceImage(out plotOutput, x xAxis, traces []feeTrace, peakMarks plotter.XYs) error {
+	p := plot.New()
+
+	p.Title.Text = "gas price"
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "gas price (nAvax)"
+
+	lines := make([]interface{}, 0, 2*len(traces))
+	for _, t := range traces {
+		pts, err := traceUint64ToPlotter(x.values, t.gasPrices)
+		if err != nil {
+			return fmt.Errorf("failed plotting %s gas price: %w", t.name, err)
+		}
+		lines = append(lines, t.name, pts)
+	}
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return err
+	}
+	if len(peakMarks) > 0 {
+		if err := plotutil.AddScatters(p, "total gas peaks", peakMarks); err != nil {
+			return err
+		}
+	}
+
+	path := out.path("price")
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printExcessGasImage plots the excess gas driving gas price
+// into [name] file
+func printExcessGasImage(out plotOutput, x xAxis, excessGas []uint64, name string) error {
+	p := plot.New()
+
+	p.Title.Text = "excess gas"
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "excess gas"
+
+	pts, err := traceUint64ToPlotter(x.values, excessGas)
+	if err != nil {
+		return fmt.Errorf("failed plotting excess gas: %w", err)
+	}
+	if err := plotutil.AddLinePoints(p, "excess gas", pts); err != nil {
+		return err
+	}
+
+	path := out.path(name)
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printExcessGasComparisonImage plots the excess gas of all [traces],
+// one line per fee config, into excess_gas file
+func printExcessGasComparisonImage(out plotOutput, x xAxis, traces []feeTrace) error {
+	p := plot.New()
+
+	p.Title.Text = "excess gas"
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "excess gas"
+
+	lines := make([]interface{}, 0, 2*len(traces))
+	for _, t := range traces {
+		pts, err := traceUint64ToPlotter(x.values, t.excessGas)
+		if err != nil {
+			return fmt.Errorf("failed plotting %s excess gas: %w", t.name, err)
+		}
+		lines = append(lines, t.name, pts)
+	}
+	if err := plotutil.AddLinePoints(p, lines...); err != nil {
+		return err
+	}
+
+	path := out.path("excess_gas")
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printGasImage plots consumed vs target gas of the trace named [name],
+// either a dimension or the weighted total, into gas_<name> file.
+// Non-empty [peakMarks] are overlaid as a scatter series.
+func printGasImage(out plotOutput, x xAxis, data, targetComplexity []uint64, peakMarks plotter.XYs, name string) error {
+	p := plot.New()
+
+	p.Title.Text = "High gas usage period, " + name
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "gas consumed"
+
+	consumed, err := traceUint64ToPlotter(x.values, data)
+	if err != nil {
+		return fmt.Errorf("failed plotting %s gas: %w", name, err)
+	}
+	target, err := traceUint64ToPlotter(x.values, targetComplexity)
+	if err != nil {
+		return fmt.Errorf("failed plotting %s target: %w", name, err)
+	}
+	if err := plotutil.AddLinePoints(p, "consumed gas", consumed, "target gas", target); err != nil {
+		return err
+	}
+	if len(peakMarks) > 0 {
+		if err := plotutil.AddScatters(p, "peaks", peakMarks); err != nil {
+			return err
+		}
+	}
+
+	path := out.path("gas_" + snakeCase(name))
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printUtilizationImage plots consumed over target gas percentage of dimension [d]
+// into utilization_<dimension> file
+func printUtilizationImage(out plotOutput, x xAxis, utilization []float64, d commonfee.Dimension) error {
+	p := plot.New()
+
+	p.Title.Text = "Utilization, " + complexity.DimensionStrings[d]
+	p.X.Label.Text = x.label
+	p.Y.Label.Text = "consumed / target gas (%)"
+
+	pts, err := traceFloat64ToPlotter(x.values, utilization)
+	if err != nil {
+		return fmt.Errorf("failed plotting %s utilization: %w", complexity.DimensionStrings[d], err)
+	}
+	if err := plotutil.AddLinePoints(p, "utilization", pts); err != nil {
+		return err
+	}
+
+	path := out.path("utilization_" + snakeCase(complexity.DimensionStrings[d]))
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printHistogram plots the distribution of per block complexity [data]
+// of dimension [d], split into [bins] buckets, into hist_<dimension> file
+func printHistogram(out plotOutput, data []uint64, d commonfee.Dimension, bins int) error {
+	if bins <= 0 {
+		return fmt.Errorf("bins must be positive, got %d", bins)
+	}
+
+	values := make(plotter.Values, len(data))
+	for i, v := range data {
+		values[i] = float64(v)
+	}
+	h, err := plotter.NewHist(values, bins)
+	if err != nil {
+		return fmt.Errorf("failed building %s histogram: %w", complexity.DimensionStrings[d], err)
+	}
+
+	p := plot.New()
+	p.Title.Text = "Complexity distribution, " + complexity.DimensionStrings[d]
+	p.X.Label.Text = "gas consumed"
+	p.Y.Label.Text = "blocks count"
+	p.Add(h)
+
+	path := out.path("hist_" + snakeCase(complexity.DimensionStrings[d]))
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// snakeCase turns dimension names into snake case,
+// e.g. "DBRead" into "db_read", to be used in file names
+func snakeCase(s string) string {
+	name := []rune(s)
+	res := make([]rune, 0, len(name)+2)
+	for i, c := range name {
+		if i > 0 && unicode.IsUpper(c) {
+			prevLower := unicode.IsLower(name[i-1])
+			nextLower := i+1 < len(name) && unicode.IsLower(name[i+1])
+			if prevLower || (unicode.IsUpper(name[i-1]) && nextLower) {
+				res = append(res, '_')
+			}
+		}
+		res = append(res, unicode.ToLower(c))
+	}
+	return string(res)
+}
+
+func traceUint64ToPlotter(x []float64, trace []uint64) (plotter.XYs, error) {
+	if len(x) != len(trace) {
+		return nil, fmt.Errorf("%w: %d x values, %d y values", errUnevenXY, len(x), len(trace))
+	}
+	// max := slices.Max(trace)
+	pts := make(plotter.XYs, len(trace))
+	for i, v := range trace {
+		pts[i].X = x[i]
+		pts[i].Y = float64(v) // / float64(max)
+	}
+	return pts, nil
+}
+
+func traceFloat64ToPlotter(x, trace []float64) (plotter.XYs, error) {
+	if len(x) != len(trace) {
+		return nil, fmt.Errorf("%w: %d x values, %d y values", errUnevenXY, len(x), len(trace))
+	}
+	// max := slices.Max(trace)
+	pts := make(plotter.XYs, len(trace))
+	for i, v := range trace {
+		pts[i].X = x[i]
+		pts[i].Y = v // / max
+	}
+	return pts, nil
+}
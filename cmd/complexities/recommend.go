@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// recommendationNote explains how a recommended fee config value was derived
+type recommendationNote struct {
+	Field     string `json:"field"`
+	Value     string `json:"value"`
+	Rationale string `json:"rationale"`
+}
+
+// recommendation is a fee config derived from historical complexities.
+// Config can be passed as is to -fee-config.
+type recommendation struct {
+	Config feeConfigFile        `json:"config"`
+	Notes  []recommendationNote `json:"rationale"`
+}
+
+// runRecommend derives a fee config from target complexity rates, historical max
+// block gas and the top total gas peak, and explains each of its values
+func runRecommend(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+	a.computeTargets()
+
+	r, err := recommendFeeConfig(ctx, a, o.feeCfg(), o.priceFactor)
+	if err != nil {
+		fatal(err)
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(r); err != nil {
+			fatal(err)
+		}
+	} else {
+		w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(w, "field\tvalue\trationale\n")
+		for _, n := range r.Notes {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", n.Field, n.Value, n.Rationale)
+		}
+		w.Flush()
+		fmt.Fprintf(a.stdout, "\n")
+	}
+	if o.recommendOutPath != "" {
+		if err := writeJSON(o.recommendOutPath, r.Config); err != nil {
+			fatal(err)
+		}
+		fmt.Fprintf(a.stdout, "recommended fee config written to %s\n", o.recommendOutPath)
+	}
+}
+
+// recommendFeeConfig derives each value of a fee config from the targets computed
+// in [a]. The min gas price is not derived from data and is kept from [base].
+// The update denominator is set so that the gas price grows
+// by [priceFactor] over the top total gas peak, as gas price grows exponentially
+// with excess gas over update denominator.
+func recommendFeeConfig(ctx context.Context, a *analysis, base commonfee.Config, priceFactor float64) (recommendation, error) {
+	var (
+		weights       = complexity.RecommendWeights(a.targetComplexityRate, base.Weights)
+		gasTargetRate = complexity.WeightedRate(a.targetComplexityRate, weights)
+		maxBlockGas   = slices.Max(complexity.PullGasFromRecords(a.records, weights))
+		cfg           = base
+		notes         = make([]recommendationNote, 0)
+	)
+	if gasTargetRate == 0 {
+		return recommendation{}, errZeroGasTargetRate
+	}
+
+	cfg.Weights = weights
+	notes = append(notes, recommendationNote{
+		Field:     "fee_dimension_weights",
+		Value:     fmt.Sprint(weights),
+		Rationale: fmt.Sprintf("inverse of target complexity rates %v, so that each dimension at target accrues about the same gas", a.targetComplexityRate),
+	})
+
+	cfg.TargetGasPerSecond = commonfee.Gas(gasTargetRate)
+	notes = append(notes, recommendationNote{
+		Field:     "gas_target_rate",
+		Value:     fmt.Sprint(gasTargetRate),
+		Rationale: fmt.Sprintf("target complexity rates, at quantile %v of historical rates, weighted by the recommended weights", a.opts.quantile),
+	})
+
+	blockDelay := max(1, a.targetBlockDelay)
+	maxGasPerSecond := max(gasTargetRate, (maxBlockGas+blockDelay-1)/blockDelay)
+	cfg.MaxGasPerSecond = commonfee.Gas(maxGasPerSecond)
+	notes = append(notes, recommendationNote{
+		Field:     "max_gas_per_second",
+		Value:     fmt.Sprint(maxGasPerSecond),
+		Rationale: fmt.Sprintf("historical max block gas %d spread over the target block delay of %ds, so that no historical block would have been throttled", maxBlockGas, blockDelay),
+	})
+
+	peaks, err := complexity.FindTotalGasPeaks(ctx, a.opts.detector, a.records, weights, gasTargetRate, 1, a.opts.smoothWindow, a.opts.thresholdWindow, a.opts.peakOrder)
+	if err != nil {
+		return recommendation{}, err
+	}
+	excessGas := uint64(0)
+	if len(peaks) > 0 {
+		excessGas = complexity.PeakExcessGas(peaks[len(peaks)-1], gasTargetRate)
+	}
+	if excessGas > 0 {
+		denominator := max(1, uint64(math.Round(float64(excessGas)/math.Log(priceFactor))))
+		cfg.ExcessConversionConstant = commonfee.Gas(denominator)
+		notes = append(notes, recommendationNote{
+			Field:     "update_denominator",
+			Value:     fmt.Sprint(denominator),
+			Rationale: fmt.Sprintf("top total gas peak at height %d accrues %d excess gas, which multiplies the gas price by %v", peaks[len(peaks)-1].StartHeight, excessGas, priceFactor),
+		})
+	} else {
+		notes = append(notes, recommendationNote{
+			Field:     "update_denominator",
+			Value:     fmt.Sprint(uint64(cfg.ExcessConversionConstant)),
+			Rationale: "kept from the base fee config, no total gas peak accrues excess gas over the recommended target rate",
+		})
+	}
+
+	notes = append(notes, recommendationNote{
+		Field:     "min_gas_price",
+		Value:     fmt.Sprint(uint64(cfg.MinGasPrice)),
+		Rationale: "kept from the base fee config, it is a policy choice rather than a property of the load",
+	})
+
+	if err := validateFeeConfig(cfg); err != nil {
+		return recommendation{}, fmt.Errorf("invalid recommended fee config: %w", err)
+	}
+	return recommendation{
+		Config: feeConfigFile{
+			MinGasPrice:              uint64(cfg.MinGasPrice),
+			ExcessConversionConstant: uint64(cfg.ExcessConversionConstant),
+			TargetGasPerSecond:       uint64(cfg.TargetGasPerSecond),
+			Weights:                  cfg.Weights,
+			MaxGasPerSecond:          uint64(cfg.MaxGasPerSecond),
+		},
+		Notes: notes,
+	}, nil
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	reportMarkdown = "md"
+	reportHTML     = "html"
+)
+
+var reportFormats = []string{reportMarkdown, reportHTML}
+
+// reportImageFormats lists the plot formats browsers and Markdown viewers render
+var reportImageFormats = []string{"png", "jpg", "jpeg", "svg"}
+
+// reportTable is a titled table of preformatted cells
+type reportTable struct {
+	Title  string
+	Header []string
+	Rows   [][]string
+}
+
+// reportImage is a plot, whose Src is a path relative to the report
+// or, for HTML reports, a data URI embedding it
+type reportImage struct {
+	Name string
+	Src  string
+}
+
+type reportPage struct {
+	Title    string
+	Scenario string
+	Notes    []string
+	Tables   []reportTable
+	Images   []reportImage
+}
+
+var markdownReport = template.Must(template.New("md").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"rule": func(n int) string { return strings.Repeat("| --- ", n) + "|" },
+}).Parse(`# {{.Title}}
+
+Scenario: ` + "`{{.Scenario}}`" + `
+{{range .Notes}}
+{{.}}
+{{end}}{{range .Tables}}
+## {{.Title}}
+
+| {{join .Header " | "}} |
+{{rule (len .Header)}}
+{{range .Rows}}| {{join . " | "}} |
+{{end}}{{end}}{{if .Images}}
+## Plots
+{{range .Images}}
+![{{.Name}}]({{.Src}})
+{{end}}{{end}}`))
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(htmltemplate.FuncMap{
+	// image sources are data URIs we build, which html/template would otherwise reject
+	"url": func(s string) htmltemplate.URL { return htmltemplate.URL(s) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 2px 8px; text-align: right; }
+figure { display: inline-block; margin: 4px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Scenario: <code>{{.Scenario}}</code></p>
+{{range .Notes}}<p>{{.}}</p>
+{{end}}{{range .Tables}}<h2>{{.Title}}</h2>
+<table>
+<tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</table>
+{{end}}{{if .Images}}<h2>Plots</h2>
+{{range .Images}}<figure><img src="{{url .Src}}" alt="{{.Name}}"><figcaption>{{.Name}}</figcaption></figure>
+{{end}}{{end}}</body>
+</html>
+`))
+
+// runReport runs a scenario and renders targets, max complexities, top peaks,
+// fee summaries and plots into a single Markdown or HTML report
+func runReport(ctx context.Context, o *options) {
+	so, s := scenarioOptions(o)
+	if !slices.Contains(reportImageFormats, so.plotFormat) {
+		so.plotFormat = "png"
+	}
+	path := o.reportOutPath
+	if path == "" {
+		path = filepath.Join(so.resultsDir(), "report."+o.reportFormat)
+	}
+
+	start := time.Now()
+	a, _ := analyze(ctx, so)
+
+	page := reportPage{
+		Title:    "Complexities report",
+		Scenario: o.scenarioPath,
+		Tables:   reportTables(a.report(), so.denom),
+	}
+	page.Notes = append(page.Notes, fmt.Sprintf(
+		"Dataset of %d blocks, from height %d to %d. Targets are computed at quantile %v of complexity rates, fees are in %s.",
+		len(a.records), a.records[0].Height, a.records[len(a.records)-1].Height, so.quantile, so.denom.label,
+	))
+	if !so.noPlot {
+		images, err := reportImages(so.outDir, filepath.Dir(path), start, o.reportFormat == reportHTML)
+		if err != nil {
+			fatal(err)
+		}
+		page.Images = images
+	}
+
+	if err := writeReportPage(path, o.reportFormat, page); err != nil {
+		fatal(err)
+	}
+	fmt.Fprintf(a.stdout, "report written to %s\n", path)
+	if so.run != nil {
+		if err := writeScenarioManifest(so.run.dir, o.scenarioPath, s, start); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// reportTables formats the sections of [r] shown in reports
+func reportTables(r Report, denom denomination) []reportTable {
+	var (
+		res     = make([]reportTable, 0)
+		targets = reportTable{
+			Title:  "Targets",
+			Header: []string{"dimension", "target complexity rate", "max block complexity"},
+		}
+	)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		name := complexity.DimensionStrings[d]
+		targets.Rows = append(targets.Rows, []string{
+			name,
+			strconv.FormatUint(r.TargetComplexityRate[name], 10),
+			strconv.FormatUint(r.MaxComplexities[name], 10),
+		})
+	}
+	targets.Rows = append(targets.Rows, []string{"block delay (s)", strconv.FormatUint(r.TargetBlockDelay, 10), ""})
+	res = append(res, targets)
+
+	if len(r.TargetsByQuantile) > 0 {
+		t := reportTable{
+			Title:  "Targets by quantile",
+			Header: []string{"quantile", "block delay (s)"},
+		}
+		for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+			t.Header = append(t.Header, complexity.DimensionStrings[d])
+		}
+		for _, q := range r.TargetsByQuantile {
+			row := []string{strconv.FormatFloat(q.Quantile, 'g', -1, 64), strconv.FormatUint(q.BlockDelay, 10)}
+			for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+				row = append(row, strconv.FormatUint(q.TargetComplexityRate[complexity.DimensionStrings[d]], 10))
+			}
+			t.Rows = append(t.Rows, row)
+		}
+		res = append(res, t)
+	}
+
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		name := complexity.DimensionStrings[d]
+		if peaks := r.TopPeaks[name]; len(peaks) > 0 {
+			res = append(res, peaksTable("Top "+name+" peaks", peaks))
+		}
+	}
+	if len(r.TopTotalGasPeaks) > 0 {
+		res = append(res, peaksTable("Top total gas peaks", r.TopTotalGasPeaks))
+	}
+
+	if len(r.Fees) > 0 {
+		t := reportTable{
+			Title:  fmt.Sprintf("Fees over the selected peak, in %s", denom.label),
+			Header: []string{"config", "max fee", "mean fee", "total fees"},
+		}
+		for _, f := range r.Fees {
+			t.Rows = append(t.Rows, []string{
+				f.Config,
+				denom.format(f.MaxFee),
+				denom.format(f.MeanFee),
+				denom.format(f.TotalFees),
+			})
+		}
+		res = append(res, t)
+	}
+	if v := r.Verification; v != nil {
+		res = append(res, reportTable{
+			Title:  fmt.Sprintf("Computed vs observed fees, in %s", denom.label),
+			Header: []string{"config", "blocks", "rmse", "computed total", "observed total", "revenue delta"},
+			Rows: [][]string{{
+				v.Config,
+				strconv.Itoa(v.Blocks),
+				denom.format(v.RMSE),
+				denom.format(v.ComputedTotal),
+				denom.format(v.ObservedTotal),
+				denom.format(v.RevenueDelta),
+			}},
+		})
+	}
+	return res
+}
+
+// peaksTable lists [peaks], top peak first
+func peaksTable(title string, peaks []complexity.Peak) reportTable {
+	t := reportTable{
+		Title:  title,
+		Header: []string{"rank", "start height", "blocks", "start time", "duration (s)", "cumulated complexity", "power"},
+	}
+	for i, p := range peaks {
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(i + 1),
+			strconv.FormatUint(p.StartHeight, 10),
+			strconv.Itoa(p.BlocksCount),
+			time.Unix(int64(p.LowTimestamp), 0).UTC().Format(time.RFC3339),
+			strconv.FormatUint(p.ElapsedTime, 10),
+			strconv.FormatUint(p.CumulatedComplexity, 10),
+			strconv.FormatFloat(p.Power(), 'f', 2, 64),
+		})
+	}
+	return t
+}
+
+// reportImages lists the plots of [dir] written since [since], sorted by name.
+// They are embedded as data URIs if [embed], linked relatively to [reportDir] otherwise.
+func reportImages(dir, reportDir string, since time.Time, embed bool) ([]reportImage, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed listing plots in %s: %w", dir, err)
+	}
+
+	res := make([]reportImage, 0)
+	for _, e := range entries {
+		ext := strings.TrimPrefix(filepath.Ext(e.Name()), ".")
+		if e.IsDir() || !slices.Contains(reportImageFormats, ext) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed reading %s: %w", e.Name(), err)
+		}
+		if info.ModTime().Before(since) {
+			continue
+		}
+
+		var (
+			path = filepath.Join(dir, e.Name())
+			img  = reportImage{Name: strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))}
+		)
+		if embed {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading %s: %w", path, err)
+			}
+			img.Src = "data:" + mime.TypeByExtension("."+ext) + ";base64," + base64.StdEncoding.EncodeToString(b)
+		} else if img.Src, err = filepath.Rel(reportDir, path); err != nil {
+			img.Src = path
+		}
+		res = append(res, img)
+	}
+	return res, nil
+}
+
+func writeReportPage(path, format string, page reportPage) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var render func(io.Writer, any) error = markdownReport.Execute
+	if format == reportHTML {
+		render = htmlReport.Execute
+	}
+	if err := render(f, page); err != nil {
+		return fmt.Errorf("failed writing %s: %w", path, err)
+	}
+	return f.Close()
+}
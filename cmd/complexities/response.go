@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+)
+
+// responseEntry tells how a fee config reacted to the idealized input
+type responseEntry struct {
+	Config        string  `json:"config"`
+	InputGasRate  float64 `json:"input_gas_rate"` // gas per second of excited blocks
+	GasTargetRate uint64  `json:"gas_target_rate"`
+	MinGasPrice   uint64  `json:"min_gas_price"`
+	MaxGasPrice   uint64  `json:"max_gas_price"`
+	MaxExcessGas  uint64  `json:"max_excess_gas"`
+	RiseTime      uint64  `json:"rise_time"`
+	Risen         bool    `json:"risen"`
+	RecoveryTime  uint64  `json:"recovery_time"`
+	Recovered     bool    `json:"recovered"`
+}
+
+// runStepResponse feeds each fee config an idealized trace, a step, an impulse or
+// a square wave of complexities, and reports and plots how gas price and excess gas
+// respond, so that the controller time constants can be told apart from the
+// irregularities of historical data
+func runStepResponse(ctx context.Context, o *options) {
+	var (
+		cfg = complexity.WaveformConfig{
+			Waveform:   o.waveform,
+			Blocks:     o.responseBlocks,
+			BlockDelay: o.responseBlockDelay,
+			Onset:      o.onsetBlocks,
+			Period:     o.periodBlocks,
+			Baseline:   o.baseline,
+			Amplitude:  o.amplitude,
+		}
+		records = complexity.GenerateWaveform(cfg, complexity.BlkHeightTime{})
+		excited = records[cfg.Onset]
+		onset   = excited.BlkHeightTime
+		last    = onset
+	)
+	for i := cfg.Onset; i < len(records); i++ {
+		if cfg.Excited(i) {
+			last = records[i].BlkHeightTime
+		}
+	}
+	slog.Info("generated idealized blocks", "waveform", cfg.Waveform, "blocks", len(records), "onset", onset.Time, "last_excited", last.Time)
+
+	if o.syntheticOutPath != "" {
+		if err := writeRecords(o.syntheticOutPath, records); err != nil {
+			fatal(err)
+		}
+	}
+
+	var (
+		entries = make([]responseEntry, 0, len(o.feeCfgs))
+		traces  = make([]feeTrace, 0, len(o.feeCfgs))
+	)
+	for _, c := range o.feeCfgs {
+		fees, err := complexity.CalculateFeeData(ctx, records, c.cfg, o.denom.unit)
+		if err != nil {
+			handleError(o.onError, fmt.Errorf("failed simulating fee config %s: %w", c.name, err))
+			continue
+		}
+		r := complexity.RespondToInput(fees, onset, last, c.cfg.MinGasPrice)
+		entries = append(entries, responseEntry{
+			Config:        c.name,
+			InputGasRate:  float64(complexity.WeightedGas(excited, c.cfg.Weights)) / cfg.BlockDelay,
+			GasTargetRate: uint64(c.cfg.TargetGasPerSecond),
+			MinGasPrice:   uint64(c.cfg.MinGasPrice),
+			MaxGasPrice:   uint64(r.MaxGasPrice),
+			MaxExcessGas:  uint64(r.MaxExcessGas),
+			RiseTime:      r.RiseTime,
+			Risen:         r.Risen,
+			RecoveryTime:  r.RecoveryTime,
+			Recovered:     r.Recovered,
+		})
+		traces = append(traces, feeTrace{
+			name:      c.name,
+			fees:      complexity.PullFees(fees, records[0].Height, records[len(records)-1].Height),
+			gasPrices: complexity.PullGasPrices(fees),
+			excessGas: complexity.PullExcessGas(fees),
+		})
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(entries); err != nil {
+			fatal(err)
+		}
+	} else {
+		printResponseTable(entries)
+	}
+
+	if o.noPlot || len(traces) == 0 {
+		return
+	}
+	out, err := newPlotOutput(o.outDir, o.plotFormat)
+	if err != nil {
+		fatal(err)
+	}
+	if err := plotResponse(out, records, traces, o); err != nil {
+		handleError(o.onError, err)
+	}
+}
+
+// printResponseTable prints one aligned row per fee config on stdout.
+// Times never reached are printed as -.
+func printResponseTable(entries []responseEntry) {
+	formatTime := func(t uint64, reached bool) string {
+		if !reached {
+			return "-"
+		}
+		return strconv.FormatUint(t, 10)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "config\tinput_gas_rate\tgas_target_rate\tmin_gas_price\tmax_gas_price\tmax_excess_gas\trise_time\trecovery_time\n")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			e.Config, strconv.FormatFloat(e.InputGasRate, 'f', -1, 64), e.GasTargetRate, e.MinGasPrice, e.MaxGasPrice, e.MaxExcessGas,
+			formatTime(e.RiseTime, e.Risen), formatTime(e.RecoveryTime, e.Recovered))
+	}
+	w.Flush()
+	fmt.Printf("gas prices in nAvax, times in seconds. rise time is taken from the input onset to e times the min gas price, recovery time from the last excited block back to the min gas price\n")
+	fmt.Printf("\n")
+}
+
+// plotResponse plots gas price and excess gas of all [traces], along with
+// the input gas under the first fee config, against seconds since the trace start
+func plotResponse(out plotOutput, records []complexity.Record, traces []feeTrace, o *options) error {
+	var (
+		x        = buildXAxis(complexity.PullTimesHeightsFromRecords(records), xAxisTime, 0)
+		feeCfg   = o.feeCfg()
+		input    = complexity.PullGasFromRecords(records, feeCfg.Weights)
+		target   = make([]uint64, len(records))
+		perBlock = uint64(math.Round(float64(feeCfg.TargetGasPerSecond) * o.responseBlockDelay))
+	)
+	x.label = "time (s)"
+	for i := range target {
+		target[i] = perBlock
+	}
+
+	if out.format == htmlFormat {
+		charts := feeCharts(x, blockHover(records), traces, o.denom)
+		charts = append(charts, excessGasChart(x, traces), htmlChart{
+			ID:     "input",
+			Title:  "input gas, " + o.feeCfgs[0].name,
+			XLabel: x.label,
+			YLabel: "gas consumed",
+			Traces: []htmlTrace{htmlLine("consumed gas", x, input, nil), htmlLine("target gas", x, target, nil)},
+		})
+		return writeHTMLCharts(out, "step_response", o.waveform+" response", charts)
+	}
+	if err := printGasImage(out, x, input, target, nil, "input"); err != nil {
+		return err
+	}
+	if err := printGasPriceImage(out, x, traces, nil); err != nil {
+		return err
+	}
+	return printExcessGasComparisonImage(out, x, traces)
+}
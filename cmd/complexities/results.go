@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Layouts of the output dir
+const (
+	// layoutFlat saves plots into the output dir and other files where their flags point
+	layoutFlat = "flat"
+	// layoutRun gathers all files of a run into a fresh timestamped dir, see startRun
+	layoutRun = "run"
+)
+
+var layouts = []string{layoutFlat, layoutRun}
+
+// Subdirs of a run dir
+const (
+	runPlotsDir   = "plots"
+	runExportsDir = "exports"
+	runLogsDir    = "logs"
+	runLogName    = "run.log"
+)
+
+// runDirLayout names run dirs after the UTC time runs start at, so that they sort chronologically
+const runDirLayout = "20060102T150405Z"
+
+// resultsRun is the dir all files produced by a run are gathered into
+type resultsRun struct {
+	dir     string
+	started time.Time
+}
+
+// runManifest records what a run produced. Scenario runs record a scenarioManifest instead.
+type runManifest struct {
+	Command   string           `json:"command"`
+	Args      []string         `json:"args"`
+	StartedAt time.Time        `json:"started_at"`
+	Elapsed   string           `json:"elapsed"`
+	Outputs   []scenarioOutput `json:"outputs"`
+}
+
+// startRun creates, with the run layout, a fresh dir under the output dir, then points
+// plots to its plots subdir and relative paths of exported files to its exports subdir,
+// and tees logs into its logs subdir. Absolute export paths are kept as they are.
+// Scenario runs are started once their scenario is loaded, see scenarioOptions.
+func (o *options) startRun() error {
+	if o.layout != layoutRun || o.scenarioPath != "" {
+		return nil
+	}
+	run, err := newResultsRun(o.outDir, time.Now())
+	if err != nil {
+		return err
+	}
+	o.run = run
+	o.outDir = filepath.Join(run.dir, runPlotsDir)
+
+	exports := []*string{
+		&o.outliersPath, &o.peaksOutPath, &o.feeOutPath, &o.verifyOutPath, &o.excessOutPath,
+		&o.throttleOutPath, &o.utilizationOutPath, &o.reportPath, &o.sweepOutPath,
+		&o.sensitivityOutPath, &o.recommendOutPath, &o.syntheticOutPath, &o.reportOutPath,
+		&o.capacityOutPath, &o.revenueOutPath, &o.rollingOutPath,
+	}
+	for _, path := range exports {
+		if *path != "" && !filepath.IsAbs(*path) {
+			*path = filepath.Join(run.dir, runExportsDir, *path)
+		}
+	}
+
+	logPath := filepath.Join(run.dir, runLogsDir, runLogName)
+	// the log is left open until the process exits, so that late diagnostics are kept too
+	f, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", logPath, err)
+	}
+	if err := setupLogging(o.logLevel, f); err != nil {
+		return err
+	}
+	slog.Info("results gathered in run dir", "dir", run.dir)
+	return nil
+}
+
+// newResultsRun creates a run dir, with its subdirs, under [outDir], named after [started].
+// A counter is appended to the name if it is taken, so that prior results are never overwritten.
+func newResultsRun(outDir string, started time.Time) (*resultsRun, error) {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed creating output dir %s: %w", outDir, err)
+	}
+	name := started.UTC().Format(runDirLayout)
+	for i := 1; ; i++ {
+		dir := filepath.Join(outDir, name)
+		if i > 1 {
+			dir += "-" + strconv.Itoa(i)
+		}
+		// Mkdir fails on existing dirs, unlike MkdirAll
+		err := os.Mkdir(dir, 0o755)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed creating run dir %s: %w", dir, err)
+		}
+		for _, sub := range []string{runPlotsDir, runExportsDir, runLogsDir} {
+			if err := os.Mkdir(filepath.Join(dir, sub), 0o755); err != nil {
+				return nil, fmt.Errorf("failed creating run dir %s: %w", dir, err)
+			}
+		}
+		return &resultsRun{dir: dir, started: started}, nil
+	}
+}
+
+// finish writes the manifest of the run of [command] with [args]
+func (r *resultsRun) finish(command string, args []string) error {
+	outputs, err := scenarioOutputs(r.dir, r.started)
+	if err != nil {
+		return err
+	}
+	manifest := runManifest{
+		Command:   command,
+		Args:      args,
+		StartedAt: r.started.UTC(),
+		Elapsed:   time.Since(r.started).String(),
+		Outputs:   outputs,
+	}
+	path := filepath.Join(r.dir, scenarioManifestName)
+	if err := writeJSON(path, manifest); err != nil {
+		return err
+	}
+	slog.Info("run done", "manifest", path, "outputs", len(outputs))
+	return nil
+}
+
+// resultsDir returns the dir reports and manifests are written into:
+// the run dir with the run layout, the output dir otherwise
+func (o *options) resultsDir() string {
+	if o.run != nil {
+		return o.run.dir
+	}
+	return o.outDir
+}
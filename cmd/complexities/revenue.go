@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"process_data/pkg/complexity"
+)
+
+// revenueEntry sums the fees a fee config collects over the dataset and by period
+type revenueEntry struct {
+	Config  string               `json:"config"`
+	Total   complexity.Revenue   `json:"total"`
+	Periods []complexity.Revenue `json:"periods"`
+
+	// Change is the change of total fees from the first config, in percent
+	Change float64 `json:"change"`
+}
+
+type revenueReport struct {
+	Denomination string         `json:"denomination"`
+	Period       string         `json:"period"`
+	Configs      []revenueEntry `json:"configs"`
+}
+
+// runRevenue replays the whole dataset with each fee config and sums the fees blocks
+// would have paid, over the dataset and by day or week, so that revenue of configs can be compared.
+// Fees are split into those due at the min gas price and those due to congestion.
+func runRevenue(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+
+	r := revenueReport{
+		Denomination: o.denom.name,
+		Period:       o.revenuePeriod,
+		Configs:      make([]revenueEntry, 0, len(o.feeCfgs)),
+	}
+	for _, c := range o.feeCfgs {
+		fees, err := a.calculateFeeData(ctx, a.records, c.cfg, 0)
+		if err != nil {
+			handleError(o.onError, fmt.Errorf("failed replaying fee config %s: %w", c.name, err))
+			continue
+		}
+		e := revenueEntry{
+			Config:  c.name,
+			Total:   complexity.TotalRevenue(a.records, fees, c.cfg, o.denom.unit),
+			Periods: complexity.RevenueByPeriod(a.records, fees, c.cfg, o.denom.unit, o.revenuePeriod),
+		}
+		if len(r.Configs) > 0 && r.Configs[0].Total.Fees != 0 {
+			e.Change = 100 * (e.Total.Fees/r.Configs[0].Total.Fees - 1)
+		}
+		r.Configs = append(r.Configs, e)
+	}
+
+	if o.revenueOutPath != "" {
+		if err := writeRevenueCSV(o.revenueOutPath, r.Configs, o.denom); err != nil {
+			fatal(err)
+		}
+	}
+	if o.output == outputJSON {
+		if err := printJSON(r); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	if len(r.Configs) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "config\tblocks\tfees (%s)\tat min gas price\tcongestion\tvs %s\n", o.denom.label, r.Configs[0].Config)
+	for _, e := range r.Configs {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%+.2f%%\n",
+			e.Config,
+			e.Total.Blocks,
+			o.denom.format(e.Total.Fees),
+			o.denom.format(e.Total.BaseFees),
+			o.denom.format(e.Total.CongestionFees()),
+			e.Change,
+		)
+	}
+	w.Flush()
+	fmt.Fprintf(a.stdout, "\n")
+
+	// periods follow block times, so they are the same for all configs
+	w = tabwriter.NewWriter(a.stdout, 0, 0, 2, ' ', 0)
+	header := []string{o.revenuePeriod, "blocks"}
+	for _, e := range r.Configs {
+		header = append(header, e.Config)
+	}
+	fmt.Fprintf(w, "%s\n", strings.Join(header, "\t"))
+	for i, p := range r.Configs[0].Periods {
+		row := []string{formatPeriodStart(p.Start), strconv.Itoa(p.Blocks)}
+		for _, e := range r.Configs {
+			row = append(row, o.denom.format(e.Periods[i].Fees))
+		}
+		fmt.Fprintf(w, "%s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintf(a.stdout, "fees by %s, in %s\n", o.revenuePeriod, o.denom.label)
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+// formatPeriodStart prints Unix time [t] as a UTC date
+func formatPeriodStart(t uint64) string {
+	return time.Unix(int64(t), 0).UTC().Format(time.DateOnly)
+}
+
+// writeRevenueCSV writes one row per period and fee config with the fees collected,
+// preceded by a header. Fees are expressed in [denom].
+func writeRevenueCSV(path string, entries []revenueEntry, denom denomination) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"period_start", "config", "blocks", "fees", "base_fees", "congestion_fees"}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, e := range entries {
+		for _, p := range e.Periods {
+			row := []string{
+				formatPeriodStart(p.Start),
+				e.Config,
+				strconv.Itoa(p.Blocks),
+				denom.format(p.Fees),
+				denom.format(p.BaseFees),
+				denom.format(p.CongestionFees()),
+			}
+			if err := w.Write(row); err != nil {
+				return fmt.Errorf("failed writing %s period %s to %s: %w", e.Config, formatPeriodStart(p.Start), path, err)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/plotutil"
+	"gonum.org/v1/plot/vg"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// rollingEvalsPerWindow sets how often rolling rates are evaluated:
+// once every shortest window divided by rollingEvalsPerWindow
+const rollingEvalsPerWindow = 60
+
+// rollingSeries holds the rolling rates of a trace over a window
+type rollingSeries struct {
+	trace  string
+	window time.Duration
+	rates  []complexity.RollingRate
+}
+
+// parseWindows parses a comma separated list of durations, e.g. 1h,6h,24h
+func parseWindows(spec string) ([]time.Duration, error) {
+	res := make([]time.Duration, 0)
+	for _, s := range strings.Split(spec, ",") {
+		w, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil {
+			return nil, fmt.Errorf("invalid window %q: %w", s, err)
+		}
+		if w < time.Second {
+			return nil, fmt.Errorf("invalid window %q, it must be at least one second", s)
+		}
+		res = append(res, w)
+	}
+	return res, nil
+}
+
+// runRolling evaluates sustained and spiky complexity rates of each dimension
+// and of total gas over rolling windows
+func runRolling(ctx context.Context, o *options) {
+	var out plotOutput
+	if !o.noPlot {
+		var err error
+		if out, err = newPlotOutput(o.outDir, o.plotFormat); err != nil {
+			fatal(err)
+		}
+	}
+
+	a := loadRecords(ctx, o)
+	var (
+		heightsAndTimes = complexity.PullTimesHeightsFromRecords(a.records)
+		step            = uint64(o.rollingWindows[0].Seconds()) / rollingEvalsPerWindow
+		traces          = make(map[string][]uint64, commonfee.NumDimensions+1)
+		names           = make([]string, 0, commonfee.NumDimensions+1)
+	)
+	for _, w := range o.rollingWindows {
+		step = min(step, uint64(w.Seconds())/rollingEvalsPerWindow)
+	}
+	step = max(1, step)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		name := snakeCase(complexity.DimensionStrings[d])
+		names = append(names, name)
+		traces[name] = a.derived.Traces[d]
+	}
+	totalName := snakeCase(totalGasName)
+	names = append(names, totalName)
+	traces[totalName] = complexity.PullGasFromRecords(a.records, o.feeCfg().Weights)
+
+	series := make([]rollingSeries, 0, len(names)*len(o.rollingWindows))
+	for _, name := range names {
+		for _, w := range o.rollingWindows {
+			rates, err := complexity.RollingRates(heightsAndTimes, traces[name], uint64(w.Seconds()), step, o.rollingQuantile)
+			if err != nil {
+				fatal(fmt.Errorf("failed computing %s rolling rates over %v: %w", name, w, err))
+			}
+			series = append(series, rollingSeries{trace: name, window: w, rates: rates})
+		}
+	}
+
+	for _, s := range series {
+		var peakMean, peakQuantile float64
+		for _, r := range s.rates {
+			peakMean = max(peakMean, r.Mean)
+			peakQuantile = max(peakQuantile, r.Quantile)
+		}
+		fmt.Fprintf(a.stdout, "%s over %v: max sustained rate %.2f, max p%v rate %.2f\n", s.trace, s.window, peakMean, 100*o.rollingQuantile, peakQuantile)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+
+	if o.rollingOutPath != "" {
+		if err := writeRollingCSV(o.rollingOutPath, series, o.rollingQuantile); err != nil {
+			fatal(err)
+		}
+	}
+	if !o.noPlot {
+		for _, name := range names {
+			a.tolerate(printRollingImage(out, name, series, o.rollingQuantile, o.xAxisMode, o.maxXGap))
+		}
+	}
+}
+
+// printRollingImage plots the rolling rates of trace [name] over all windows
+// into rolling_<name> file
+func printRollingImage(out plotOutput, name string, series []rollingSeries, quantile float64, xMode string, maxGap uint64) error {
+	if out.format == htmlFormat {
+		return printHTMLRolling(out, name, series, quantile, xMode, maxGap)
+	}
+
+	p := plot.New()
+	p.Title.Text = "Rolling complexity rate, " + name
+	p.Y.Label.Text = "complexity per second"
+
+	lines := make([]interface{}, 0)
+	for _, s := range series {
+		if s.trace != name {
+			continue
+		}
+		var (
+			x         = rollingXAxis(s.rates, xMode, maxGap)
+			means     = make(plotter.XYs, len(s.rates))
+			quantiles = make(plotter.XYs, len(s.rates))
+		)
+		p.X.Label.Text = x.label
+		for i, r := range s.rates {
+			means[i] = plotter.XY{X: x.values[i], Y: r.Mean}
+			quantiles[i] = plotter.XY{X: x.values[i], Y: r.Quantile}
+		}
+		lines = append(lines,
+			fmt.Sprintf("mean %v", s.window), means,
+			fmt.Sprintf("p%v %v", 100*quantile, s.window), quantiles,
+		)
+	}
+	if err := plotutil.AddLines(p, lines...); err != nil {
+		return err
+	}
+
+	path := out.path("rolling_" + name)
+	if err := p.Save(4*vg.Inch, 4*vg.Inch, path); err != nil {
+		return fmt.Errorf("failed saving %s: %w", path, err)
+	}
+	return nil
+}
+
+// printHTMLRolling renders the rolling rates of trace [name] over all windows
+// as an interactive chart into rolling_<name> file
+func printHTMLRolling(out plotOutput, name string, series []rollingSeries, quantile float64, xMode string, maxGap uint64) error {
+	chart := htmlChart{
+		ID:     "rolling_" + name,
+		Title:  "Rolling complexity rate, " + name,
+		YLabel: "complexity per second",
+	}
+	for _, s := range series {
+		if s.trace != name {
+			continue
+		}
+		var (
+			x         = rollingXAxis(s.rates, xMode, maxGap)
+			hover     = make([]string, len(s.rates))
+			means     = make([]float64, len(s.rates))
+			quantiles = make([]float64, len(s.rates))
+		)
+		chart.XLabel = x.label
+		for i, r := range s.rates {
+			hover[i] = fmt.Sprintf("height %d<br>time %d", r.Height, r.Time)
+			means[i] = r.Mean
+			quantiles[i] = r.Quantile
+		}
+		chart.Traces = append(chart.Traces,
+			htmlLine(fmt.Sprintf("mean %v", s.window), x, means, hover),
+			htmlLine(fmt.Sprintf("p%v %v", 100*quantile, s.window), x, quantiles, hover),
+		)
+	}
+	return writeHTMLCharts(out, "rolling_"+name, chart.Title, []htmlChart{chart})
+}
+
+// rollingXAxis places [rates] along the x axis as buildXAxis does with blocks
+func rollingXAxis(rates []complexity.RollingRate, mode string, maxGap uint64) xAxis {
+	points := make([]complexity.BlkHeightTime, len(rates))
+	for i, r := range rates {
+		points[i] = r.BlkHeightTime
+	}
+	return buildXAxis(points, mode, maxGap)
+}
+
+// writeRollingCSV writes one row per trace, window and evaluation, preceded by a header
+func writeRollingCSV(path string, series []rollingSeries, quantile float64) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"trace", "window_seconds", "height", "time", "mean_rate", fmt.Sprintf("p%v_rate", 100*quantile)}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, s := range series {
+		for _, r := range s.rates {
+			row := []string{
+				s.trace,
+				strconv.FormatFloat(s.window.Seconds(), 'g', -1, 64),
+				strconv.FormatUint(r.Height, 10),
+				strconv.FormatUint(r.Time, 10),
+				strconv.FormatFloat(r.Mean, 'g', -1, 64),
+				strconv.FormatFloat(r.Quantile, 'g', -1, 64),
+			}
+			if err := w.Write(row); err != nil {
+				return fmt.Errorf("failed writing height %d to %s: %w", r.Height, path, err)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
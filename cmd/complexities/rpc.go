@@ -0,0 +1,217 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"process_data/pkg/complexity"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/utils/rpc"
+	"github.com/ava-labs/avalanchego/vms/platformvm/block"
+	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
+	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// rpcLogInterval is the number of fetched blocks between progress logs
+const rpcLogInterval = 10_000
+
+var (
+	errAboveTip    = errors.New("min height above the node tip")
+	errUnmeteredTx = errors.New("tx type not metered by dynamic fees")
+)
+
+// pChainClient sends the P-chain API requests fetchRecords needs. The platformvm
+// client is not used as its package pulls in the whole VM.
+type pChainClient struct {
+	requester rpc.EndpointRequester
+}
+
+func newPChainClient(uri string) *pChainClient {
+	return &pChainClient{requester: rpc.NewEndpointRequester(uri + "/ext/P")}
+}
+
+func (c *pChainClient) getHeight(ctx context.Context) (uint64, error) {
+	res := &api.GetHeightResponse{}
+	err := c.requester.SendRequest(ctx, "platform.getHeight", struct{}{}, res)
+	return uint64(res.Height), err
+}
+
+func (c *pChainClient) getBlockByHeight(ctx context.Context, height uint64) ([]byte, error) {
+	res := &api.FormattedBlock{}
+	err := c.requester.SendRequest(ctx, "platform.getBlockByHeight", &api.GetBlockByHeightArgs{
+		Height:   json.Uint64(height),
+		Encoding: formatting.HexNC,
+	}, res)
+	if err != nil {
+		return nil, err
+	}
+	return formatting.Decode(res.Encoding, res.Block)
+}
+
+// fetchedBlock is the outcome of fetching the block at a height: either its record,
+// or whether it was left out for carrying unmetered txs
+type fetchedBlock struct {
+	record    complexity.Record
+	unmetered bool
+}
+
+// fetchRecords walks P-chain blocks from height [o.minHeight] up to [o.maxHeight],
+// capped to the node tip, through the node API at [o.rpcURI] and meters their complexities.
+// Blocks are fetched concurrently by a pool of -rpc-parallelism workers; records keep
+// the height order. Blocks carrying txs the dynamic fees do not meter are left out,
+// see txComplexity. Blocks predating Banff carry no timestamp and are returned
+// with time 0, which -timestamps can backfill.
+func fetchRecords(ctx context.Context, o *options) ([]complexity.Record, error) {
+	if !strings.EqualFold(o.chain.name, "P") {
+		return nil, fmt.Errorf("fetching from a node is supported for the P-chain only, got %s", o.chain.name)
+	}
+
+	client := newPChainClient(o.rpcURI)
+	tip, err := client.getHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed fetching P-chain height from %s: %w", o.rpcURI, err)
+	}
+	up := min(o.maxHeight, tip)
+	if o.minHeight > up {
+		return nil, fmt.Errorf("%w: %d above %d", errAboveTip, o.minHeight, tip)
+	}
+
+	slog.Info("fetching blocks", "node", o.rpcURI, "from", o.minHeight, "to", up, "parallelism", o.rpcParallelism)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		fetched = make([]fetchedBlock, up-o.minHeight+1)
+		heights = make(chan uint64)
+		done    atomic.Int64
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < min(o.rpcParallelism, len(fetched)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for height := range heights {
+				b, err := fetchBlock(ctx, client, height)
+				if err != nil {
+					cancel(err)
+					continue
+				}
+				fetched[height-o.minHeight] = b
+				if n := done.Add(1); n%rpcLogInterval == 0 {
+					slog.Info("fetched blocks", "count", n, "of", len(fetched))
+				}
+			}
+		}()
+	}
+	for height := o.minHeight; height <= up && ctx.Err() == nil; height++ {
+		heights <- height
+		if height == up {
+			break
+		}
+	}
+	close(heights)
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+
+	res := make([]complexity.Record, 0, len(fetched))
+	for _, b := range fetched {
+		if !b.unmetered {
+			res = append(res, b.record)
+		}
+	}
+	if unmetered := len(fetched) - len(res); unmetered > 0 {
+		slog.Warn("left out blocks carrying unmetered txs", "blocks", unmetered)
+	}
+	return res, nil
+}
+
+// fetchBlock fetches and meters the block at [height]
+func fetchBlock(ctx context.Context, client *pChainClient, height uint64) (fetchedBlock, error) {
+	blkBytes, err := client.getBlockByHeight(ctx, height)
+	if err != nil {
+		return fetchedBlock{}, fmt.Errorf("failed fetching block at height %d: %w", height, err)
+	}
+	blk, err := block.Parse(block.Codec, blkBytes)
+	if err != nil {
+		return fetchedBlock{}, fmt.Errorf("failed parsing block at height %d: %w", height, err)
+	}
+	r, err := blockRecord(blk)
+	if errors.Is(err, errUnmeteredTx) {
+		slog.Debug("leaving out block", "err", err)
+		return fetchedBlock{unmetered: true}, nil
+	}
+	return fetchedBlock{record: r}, err
+}
+
+// blockRecord returns the record of [blk], whose complexity sums up the complexities of its txs
+func blockRecord(blk block.Block) (complexity.Record, error) {
+	r := complexity.Record{
+		ID: blk.ID(),
+		BlkHeightTime: complexity.BlkHeightTime{
+			Height: blk.Height(),
+		},
+	}
+	if banffBlk, ok := blk.(block.BanffBlock); ok {
+		r.Time = uint64(banffBlk.Timestamp().Unix())
+	}
+	for _, tx := range blk.Txs() {
+		c, err := txComplexity(tx)
+		if err != nil {
+			return complexity.Record{}, fmt.Errorf("height %d: %w", r.Height, err)
+		}
+		for d := range r.Complexity {
+			r.Complexity[d] += c[d]
+		}
+	}
+	return r, nil
+}
+
+// txComplexity meters [tx] along the fee dimensions: bandwidth is the signed tx size,
+// reads are the consumed UTXOs, writes the consumed UTXOs being deleted plus the produced ones,
+// and compute the signatures to verify. It approximates the platformvm fee calculator
+// of later avalanchego releases, which the pinned one does not ship.
+// Produced outputs include stake outputs, returned to stakers once staking ends, and
+// outputs exported to other chains. Tx types that calculator does not meter are rejected
+// with errUnmeteredTx: pre-Durango staking txs, subnet transforms, and the advance time
+// and reward txs the chain issues itself.
+func txComplexity(tx *txs.Tx) (commonfee.Dimensions, error) {
+	var (
+		res      commonfee.Dimensions
+		consumed = uint64(tx.Unsigned.InputIDs().Len())
+		produced = uint64(len(tx.Unsigned.Outputs()))
+	)
+	switch utx := tx.Unsigned.(type) {
+	case *txs.AddPermissionlessValidatorTx:
+		produced += uint64(len(utx.StakeOuts))
+	case *txs.AddPermissionlessDelegatorTx:
+		produced += uint64(len(utx.StakeOuts))
+	case *txs.ExportTx:
+		produced += uint64(len(utx.ExportedOutputs))
+	case *txs.BaseTx, *txs.ImportTx, *txs.CreateChainTx, *txs.CreateSubnetTx,
+		*txs.AddSubnetValidatorTx, *txs.RemoveSubnetValidatorTx, *txs.TransferSubnetOwnershipTx:
+	default:
+		return commonfee.Dimensions{}, fmt.Errorf("%w: %T", errUnmeteredTx, utx)
+	}
+
+	res[commonfee.Bandwidth] = uint64(len(tx.Bytes()))
+	res[commonfee.DBRead] = consumed
+	res[commonfee.DBWrite] = consumed + produced
+	for _, cred := range tx.Creds {
+		if secpCred, ok := cred.(*secp256k1fx.Credential); ok {
+			res[commonfee.Compute] += uint64(len(secpCred.Sigs))
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/constants"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	avajson "github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/vms/components/avax"
+	"github.com/ava-labs/avalanchego/vms/components/verify"
+	"github.com/ava-labs/avalanchego/vms/platformvm/block"
+	"github.com/ava-labs/avalanchego/vms/platformvm/txs"
+	"github.com/ava-labs/avalanchego/vms/secp256k1fx"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// testInputs returns [n] inputs, each consuming a distinct UTXO
+func testInputs(n int) []*avax.TransferableInput {
+	res := make([]*avax.TransferableInput, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, &avax.TransferableInput{
+			UTXOID: avax.UTXOID{TxID: ids.GenerateTestID()},
+			Asset:  avax.Asset{ID: ids.GenerateTestID()},
+			In: &secp256k1fx.TransferInput{
+				Amt:   1,
+				Input: secp256k1fx.Input{SigIndices: []uint32{0}},
+			},
+		})
+	}
+	return res
+}
+
+// testOutputs returns [n] outputs
+func testOutputs(n int) []*avax.TransferableOutput {
+	res := make([]*avax.TransferableOutput, 0, n)
+	for i := 0; i < n; i++ {
+		res = append(res, &avax.TransferableOutput{
+			Asset: avax.Asset{ID: ids.GenerateTestID()},
+			Out: &secp256k1fx.TransferOutput{
+				Amt: 1,
+				OutputOwners: secp256k1fx.OutputOwners{
+					Threshold: 1,
+					Addrs:     []ids.ShortID{ids.GenerateTestShortID()},
+				},
+			},
+		})
+	}
+	return res
+}
+
+// testBaseTx returns a base tx consuming [ins] UTXOs and producing [outs] outputs
+func testBaseTx(ins, outs int) txs.BaseTx {
+	return txs.BaseTx{BaseTx: avax.BaseTx{
+		NetworkID:    constants.MainnetID,
+		BlockchainID: constants.PlatformChainID,
+		Ins:          testInputs(ins),
+		Outs:         testOutputs(outs),
+	}}
+}
+
+// testTx signs [utx] with [sigs] signatures
+func testTx(t *testing.T, utx txs.UnsignedTx, sigs int) *txs.Tx {
+	t.Helper()
+
+	tx := &txs.Tx{
+		Unsigned: utx,
+		Creds:    []verify.Verifiable{&secp256k1fx.Credential{Sigs: make([][65]byte, sigs)}},
+	}
+	if err := tx.Initialize(txs.Codec); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestTxComplexity(t *testing.T) {
+	validator := txs.Validator{NodeID: ids.GenerateTestNodeID(), Start: 1, End: 2, Wght: 1}
+	tests := []struct {
+		name          string
+		utx           txs.UnsignedTx
+		sigs          int
+		expectedRead  uint64
+		expectedWrite uint64
+		expectedErr   error
+	}{
+		{
+			name:          "base tx",
+			utx:           &txs.BaseTx{BaseTx: testBaseTx(2, 3).BaseTx},
+			sigs:          2,
+			expectedRead:  2,
+			expectedWrite: 5,
+		},
+		{
+			name: "stake outputs are written",
+			utx: &txs.AddPermissionlessDelegatorTx{
+				BaseTx:                 testBaseTx(1, 1),
+				Validator:              validator,
+				Subnet:                 constants.PrimaryNetworkID,
+				StakeOuts:              testOutputs(2),
+				DelegationRewardsOwner: &secp256k1fx.OutputOwners{},
+			},
+			sigs:          1,
+			expectedRead:  1,
+			expectedWrite: 4,
+		},
+		{
+			name: "exported outputs are written",
+			utx: &txs.ExportTx{
+				BaseTx:           testBaseTx(1, 1),
+				DestinationChain: ids.GenerateTestID(),
+				ExportedOutputs:  testOutputs(3),
+			},
+			sigs:          1,
+			expectedRead:  1,
+			expectedWrite: 5,
+		},
+		{
+			name: "pre-Durango staking tx",
+			utx: &txs.AddDelegatorTx{
+				BaseTx:                 testBaseTx(1, 1),
+				Validator:              validator,
+				StakeOuts:              testOutputs(1),
+				DelegationRewardsOwner: &secp256k1fx.OutputOwners{},
+			},
+			expectedErr: errUnmeteredTx,
+		},
+		{
+			name:        "advance time tx",
+			utx:         &txs.AdvanceTimeTx{Time: 1},
+			expectedErr: errUnmeteredTx,
+		},
+		{
+			name:        "reward validator tx",
+			utx:         &txs.RewardValidatorTx{TxID: ids.GenerateTestID()},
+			expectedErr: errUnmeteredTx,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testTx(t, tt.utx, tt.sigs)
+			got, err := txComplexity(tx)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedErr != nil {
+				return
+			}
+			expected := commonfee.Dimensions{
+				commonfee.Bandwidth: uint64(len(tx.Bytes())),
+				commonfee.DBRead:    tt.expectedRead,
+				commonfee.DBWrite:   tt.expectedWrite,
+				commonfee.Compute:   uint64(tt.sigs),
+			}
+			if got != expected {
+				t.Fatalf("expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+// testNode serves the P-chain API fetchRecords uses, from [blocks] indexed by height
+func testNode(t *testing.T, blocks map[uint64]block.Block, tip uint64) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                   `json:"method"`
+			Params api.GetBlockByHeightArgs `json:"params"`
+			ID     any                      `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "platform.getHeight":
+			result = api.GetHeightResponse{Height: avajson.Uint64(tip)}
+		case "platform.getBlockByHeight":
+			blk, ok := blocks[uint64(req.Params.Height)]
+			if !ok {
+				http.Error(w, "unknown height", http.StatusNotFound)
+				return
+			}
+			encoded, err := formatting.Encode(formatting.HexNC, blk.Bytes())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			result = api.FormattedBlock{Block: encoded, Encoding: formatting.HexNC}
+		default:
+			http.Error(w, "unknown method", http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "result": result, "id": req.ID})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestFetchRecords(t *testing.T) {
+	const (
+		low, tip = 100, 130
+		rewarded = 110 // proposal block rewarding a validator, left out
+	)
+	blocks := make(map[uint64]block.Block)
+	for h := uint64(low); h <= tip; h++ {
+		var (
+			blk block.Block
+			err error
+		)
+		if h == rewarded {
+			blk, err = block.NewApricotProposalBlock(ids.GenerateTestID(), h, testTx(t, &txs.RewardValidatorTx{TxID: ids.GenerateTestID()}, 0))
+		} else {
+			baseTx := testBaseTx(1, 1)
+			blk, err = block.NewBanffStandardBlock(time.Unix(int64(1_700_000_000+h), 0), ids.GenerateTestID(), h, []*txs.Tx{testTx(t, &baseTx, 1)})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		blocks[h] = blk
+	}
+
+	o := defaultOptions()
+	o.chain = chains[0]
+	o.rpcURI = testNode(t, blocks, tip).URL
+	o.rpcParallelism = 4
+	o.minHeight = low
+
+	records, err := fetchRecords(context.Background(), o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heights := make([]uint64, 0, len(records))
+	for _, r := range records {
+		if r.ID != blocks[r.Height].ID() || r.Time != 1_700_000_000+r.Height {
+			t.Fatalf("unexpected record %+v at height %d", r, r.Height)
+		}
+		heights = append(heights, r.Height)
+	}
+	expected := make([]uint64, 0, tip-low)
+	for h := uint64(low); h <= tip; h++ {
+		if h != rewarded {
+			expected = append(expected, h)
+		}
+	}
+	if !slices.Equal(heights, expected) {
+		t.Fatalf("expected heights %v, got %v", expected, heights)
+	}
+
+	// a failing block fails the whole fetch
+	delete(blocks, 120)
+	if _, err := fetchRecords(context.Background(), o); err == nil {
+		t.Fatal("expected fetching a missing block to fail")
+	}
+
+	o.minHeight = tip + 1
+	if _, err := fetchRecords(context.Background(), o); !errors.Is(err, errAboveTip) {
+		t.Fatalf("expected %v, got %v", errAboveTip, err)
+	}
+}
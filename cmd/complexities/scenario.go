@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	scenarioReportName   = "report.json"
+	scenarioManifestName = "manifest.json"
+)
+
+// scenarioFile bundles the inputs of an analysis, so that it can be rerun and shared.
+// Relative paths are resolved against the scenario file directory.
+// Fields missing from the file keep the defaults of the analyze subcommand.
+type scenarioFile struct {
+	Dataset            string    `json:"dataset"              yaml:"dataset"`
+	Chain              string    `json:"chain"                yaml:"chain"`
+	Columns            string    `json:"columns"              yaml:"columns"`
+	MinHeight          uint64    `json:"min_height"           yaml:"min_height"`
+	MaxHeight          uint64    `json:"max_height"           yaml:"max_height"`
+	From               string    `json:"from"                 yaml:"from"`
+	To                 string    `json:"to"                   yaml:"to"`
+	FeeConfigs         []string  `json:"fee_configs"          yaml:"fee_configs"`
+	Denomination       string    `json:"denomination"         yaml:"denomination"`
+	Quantile           float64   `json:"quantile"             yaml:"quantile"`
+	BlockDelayQuantile float64   `json:"block_delay_quantile" yaml:"block_delay_quantile"`
+	Quantiles          []float64 `json:"quantiles"            yaml:"quantiles"`
+	Dimension          string    `json:"dimension"            yaml:"dimension"`
+	Peak               int       `json:"peak"                 yaml:"peak"`
+	OutDir             string    `json:"out_dir"              yaml:"out_dir"`
+	Format             string    `json:"format"               yaml:"format"`
+	NoPlot             bool      `json:"no_plot"              yaml:"no_plot"`
+}
+
+// scenarioOutput is a file produced by a scenario run
+type scenarioOutput struct {
+	Path   string `json:"path"` // relative to the output dir
+	Bytes  int64  `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// scenarioManifest records what a scenario run produced
+type scenarioManifest struct {
+	Scenario  string           `json:"scenario"`
+	Inputs    scenarioFile     `json:"inputs"`
+	StartedAt time.Time        `json:"started_at"`
+	Elapsed   string           `json:"elapsed"`
+	Outputs   []scenarioOutput `json:"outputs"`
+}
+
+func loadScenario(path string) (scenarioFile, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return scenarioFile{}, fmt.Errorf("failed reading scenario %s: %w", path, err)
+	}
+	var s scenarioFile
+	if err := unmarshalConfig(path, b, &s); err != nil {
+		return scenarioFile{}, fmt.Errorf("failed parsing scenario %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || p == stdinPath || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	s.Dataset = resolve(s.Dataset)
+	for i, c := range s.FeeConfigs {
+		s.FeeConfigs[i] = resolve(c)
+	}
+	s.OutDir = resolve(s.OutDir)
+	return s, nil
+}
+
+// apply overrides [o] with the fields set in [s]
+func (s scenarioFile) apply(o *options) {
+	setString := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	setString(&o.csvPaths, s.Dataset)
+	setString(&o.chainName, s.Chain)
+	setString(&o.columnsSpec, s.Columns)
+	setString(&o.fromTime, s.From)
+	setString(&o.toTime, s.To)
+	setString(&o.feeConfigPaths, strings.Join(s.FeeConfigs, ","))
+	setString(&o.denomName, s.Denomination)
+	setString(&o.dimensionName, s.Dimension)
+	setString(&o.outDir, s.OutDir)
+	setString(&o.plotFormat, s.Format)
+
+	if s.MinHeight != 0 {
+		o.minHeight = s.MinHeight
+	}
+	if s.MaxHeight != 0 {
+		o.maxHeight = s.MaxHeight
+	}
+	if s.Quantile != 0 {
+		o.quantile = s.Quantile
+	}
+	if s.BlockDelayQuantile != 0 {
+		o.blockDelayQuantile = s.BlockDelayQuantile
+	}
+	if len(s.Quantiles) > 0 {
+		specs := make([]string, 0, len(s.Quantiles))
+		for _, q := range s.Quantiles {
+			specs = append(specs, strconv.FormatFloat(q, 'g', -1, 64))
+		}
+		o.quantilesSpec = strings.Join(specs, ",")
+	}
+	if s.Peak != 0 {
+		o.peakIndex = s.Peak
+	}
+	o.noPlot = o.noPlot || s.NoPlot
+}
+
+// scenarioOptions loads the scenario set in [o] and returns the options it describes.
+// Scenario values replace defaults, not flags of other subcommands.
+func scenarioOptions(o *options) (*options, scenarioFile) {
+	if o.scenarioPath == "" {
+		fatal(fmt.Errorf("a scenario file must be set with -scenario"))
+	}
+	s, err := loadScenario(o.scenarioPath)
+	if err != nil {
+		fatal(err)
+	}
+
+	so := defaultOptions()
+	so.logLevel = o.logLevel
+	so.onError = o.onError
+	so.output = o.output
+	so.layout = o.layout
+	s.apply(so)
+	if err := so.resolve(); err != nil {
+		fatal(fmt.Errorf("invalid scenario %s: %w", o.scenarioPath, err))
+	}
+	if err := os.MkdirAll(so.outDir, 0o755); err != nil {
+		fatal(fmt.Errorf("failed creating output dir %s: %w", so.outDir, err))
+	}
+	so.reportPath = scenarioReportName
+	if err := so.startRun(); err != nil {
+		fatal(err)
+	}
+	if so.run == nil {
+		so.reportPath = filepath.Join(so.outDir, scenarioReportName)
+	}
+	return so, s
+}
+
+// runScenario runs the whole analysis as described by a scenario file, writing
+// the JSON report and a manifest of all files produced into the scenario output dir
+func runScenario(ctx context.Context, o *options) {
+	so, s := scenarioOptions(o)
+
+	start := time.Now()
+	runAnalyze(ctx, so)
+	if err := writeScenarioManifest(so.resultsDir(), o.scenarioPath, s, start); err != nil {
+		fatal(err)
+	}
+}
+
+// writeScenarioManifest writes into [dir] the manifest of the run of scenario [s],
+// read from [path], listing the files of [dir] written since [start]
+func writeScenarioManifest(dir, path string, s scenarioFile, start time.Time) error {
+	outputs, err := scenarioOutputs(dir, start)
+	if err != nil {
+		return err
+	}
+	manifest := scenarioManifest{
+		Scenario:  path,
+		Inputs:    s,
+		StartedAt: start.UTC(),
+		Elapsed:   time.Since(start).String(),
+		Outputs:   outputs,
+	}
+	manifestPath := filepath.Join(dir, scenarioManifestName)
+	if err := writeJSON(manifestPath, manifest); err != nil {
+		return err
+	}
+	slog.Info("scenario done", "manifest", manifestPath, "outputs", len(outputs))
+	return nil
+}
+
+// scenarioOutputs lists the files of [dir] written since [since], with their digest
+func scenarioOutputs(dir string, since time.Time) ([]scenarioOutput, error) {
+	res := make([]scenarioOutput, 0)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// run logs are still written to, their digest would not hold
+		if d.IsDir() || d.Name() == scenarioManifestName || d.Name() == runLogName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(since) {
+			return nil
+		}
+
+		digest, err := fileDigest(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		res = append(res, scenarioOutput{
+			Path:   rel,
+			Bytes:  info.Size(),
+			SHA256: digest,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed listing outputs in %s: %w", dir, err)
+	}
+	return res, nil
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// sensitivityEntry reports fees and top total gas peak duration once
+// a dimension weight is scaled, along with their change from the base config
+type sensitivityEntry struct {
+	Dimension    string  `json:"dimension"`
+	Factor       float64 `json:"factor"`
+	Weight       uint64  `json:"weight"`
+	Denomination string  `json:"denomination"`
+	MaxFee       float64 `json:"max_fee"`
+	MedianFee    float64 `json:"median_fee"`
+	PeakDuration uint64  `json:"peak_duration"`
+
+	// changes from the base config, in percent
+	MaxFeeChange       float64 `json:"max_fee_change"`
+	MedianFeeChange    float64 `json:"median_fee_change"`
+	PeakDurationChange float64 `json:"peak_duration_change"`
+}
+
+// parseFactors parses a comma separated list of positive multipliers
+func parseFactors(spec string) ([]float64, error) {
+	res := make([]float64, 0)
+	for _, s := range strings.Split(spec, ",") {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weight factor %q: %w", s, err)
+		}
+		if f <= 0 {
+			return nil, fmt.Errorf("weight factor must be positive, got %v", f)
+		}
+		res = append(res, f)
+	}
+	return res, nil
+}
+
+// runSensitivity scales each dimension weight of the first fee config in turn,
+// replays the whole dataset and reports how fees and the top total gas peak change
+func runSensitivity(ctx context.Context, o *options) {
+	factors, err := parseFactors(o.weightFactors)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := loadRecords(ctx, o)
+	base := o.feeCfg()
+	baseEntry, err := weightSensitivity(ctx, a, base, o)
+	if err != nil {
+		fatal(err)
+	}
+	baseEntry.Dimension, baseEntry.Factor = "base", 1
+
+	entries := []sensitivityEntry{baseEntry}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		for _, f := range factors {
+			cfg := base
+			cfg.Weights[d] = uint64(math.Round(float64(base.Weights[d]) * f))
+			e, err := weightSensitivity(ctx, a, cfg, o)
+			if err != nil {
+				handleError(o.onError, fmt.Errorf("failed scaling %s weight by %v: %w", complexity.DimensionStrings[d], f, err))
+				continue
+			}
+			e.Dimension = complexity.DimensionStrings[d]
+			e.Factor = f
+			e.Weight = cfg.Weights[d]
+			e.MaxFeeChange = percentChange(baseEntry.MaxFee, e.MaxFee)
+			e.MedianFeeChange = percentChange(baseEntry.MedianFee, e.MedianFee)
+			e.PeakDurationChange = percentChange(float64(baseEntry.PeakDuration), float64(e.PeakDuration))
+			entries = append(entries, e)
+		}
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(entries); err != nil {
+			fatal(err)
+		}
+	} else {
+		printSensitivityTable(entries, o.denom)
+	}
+	if o.sensitivityOutPath != "" {
+		if err := writeSensitivityCSV(o.sensitivityOutPath, entries, o.denom); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// weightSensitivity replays the records of [a] with [cfg] and returns its max and median fee
+// and the duration of its top total gas peak
+func weightSensitivity(ctx context.Context, a *analysis, cfg commonfee.Config, o *options) (sensitivityEntry, error) {
+	fees, err := a.calculateFeeData(ctx, a.records, cfg, 0)
+	if err != nil {
+		return sensitivityEntry{}, err
+	}
+	summary := complexity.SummarizeFees(fees, cfg.MinGasPrice)
+
+	peaks, err := complexity.FindTotalGasPeaks(ctx, o.detector, a.records, cfg.Weights, uint64(cfg.TargetGasPerSecond), 1, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		return sensitivityEntry{}, err
+	}
+	e := sensitivityEntry{
+		Denomination: o.denom.name,
+		MaxFee:       summary.MaxFee,
+		MedianFee:    summary.MedianFee,
+	}
+	if len(peaks) > 0 {
+		e.PeakDuration = peaks[len(peaks)-1].ElapsedTime
+	}
+	return e, nil
+}
+
+// percentChange returns the change from [base] to [v], in percent. It is zero if [base] is.
+func percentChange(base, v float64) float64 {
+	if base == 0 {
+		return 0
+	}
+	return 100 * (v - base) / base
+}
+
+var sensitivityHeader = []string{"dimension", "factor", "weight", "max_fee", "median_fee", "peak_duration", "max_fee_change_pct", "median_fee_change_pct", "peak_duration_change_pct"}
+
+// sensitivityRow formats [e], fees being printed as [denom] tells
+func sensitivityRow(e sensitivityEntry, denom denomination) []string {
+	return []string{
+		e.Dimension,
+		strconv.FormatFloat(e.Factor, 'g', -1, 64),
+		strconv.FormatUint(e.Weight, 10),
+		denom.format(e.MaxFee),
+		denom.format(e.MedianFee),
+		strconv.FormatUint(e.PeakDuration, 10),
+		strconv.FormatFloat(e.MaxFeeChange, 'f', 2, 64),
+		strconv.FormatFloat(e.MedianFeeChange, 'f', 2, 64),
+		strconv.FormatFloat(e.PeakDurationChange, 'f', 2, 64),
+	}
+}
+
+// printSensitivityTable prints one aligned row per scaled weight on stdout
+func printSensitivityTable(entries []sensitivityEntry, denom denomination) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\n", strings.Join(sensitivityHeader, "\t"))
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\n", strings.Join(sensitivityRow(e, denom), "\t"))
+	}
+	w.Flush()
+	fmt.Printf("fees in %s, peak duration in seconds\n", denom.label)
+	fmt.Printf("\n")
+}
+
+// writeSensitivityCSV writes one row per scaled weight, preceded by a header.
+// Fees are expressed in [denom].
+func writeSensitivityCSV(path string, entries []sensitivityEntry, denom denomination) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := make([]string, len(sensitivityHeader))
+	copy(header, sensitivityHeader)
+	header[3] += "_" + denom.name
+	header[4] += "_" + denom.name
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, e := range entries {
+		if err := w.Write(sensitivityRow(e, denom)); err != nil {
+			return fmt.Errorf("failed writing sensitivity row to %s: %w", path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
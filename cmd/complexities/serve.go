@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// metricsPrefix namespaces exposed metrics
+const metricsPrefix = "complexities_"
+
+// feeMarket holds the latest state of the simulated fee market,
+// one fee state per fee config
+type feeMarket struct {
+	lock sync.RWMutex
+
+	blocks uint64
+	latest complexity.Record
+	fees   []complexity.FeeData
+}
+
+// writeMetrics writes the fee market state in the Prometheus text exposition format
+func (m *feeMarket) writeMetrics(w io.Writer, cfgs []namedFeeConfig, denom denomination) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	gauge := func(name, help string) {
+		fmt.Fprintf(w, "# HELP %s%s %s\n# TYPE %s%s gauge\n", metricsPrefix, name, help, metricsPrefix, name)
+	}
+
+	fmt.Fprintf(w, "# HELP %sblocks_total blocks accepted since start\n# TYPE %sblocks_total counter\n", metricsPrefix, metricsPrefix)
+	fmt.Fprintf(w, "%sblocks_total %d\n", metricsPrefix, m.blocks)
+	if m.blocks == 0 {
+		return
+	}
+
+	gauge("block_height", "height of the latest block")
+	fmt.Fprintf(w, "%sblock_height %d\n", metricsPrefix, m.latest.Height)
+	gauge("block_time", "unix timestamp of the latest block")
+	fmt.Fprintf(w, "%sblock_time %d\n", metricsPrefix, m.latest.Time)
+	gauge("block_complexity", "complexity of the latest block")
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		fmt.Fprintf(w, "%sblock_complexity{dimension=%q} %d\n", metricsPrefix, snakeCase(complexity.DimensionStrings[d]), m.latest.Complexity[d])
+	}
+
+	gauge("gas_price", "gas price, in nAvax, once the latest block is accepted")
+	for i, c := range cfgs {
+		fmt.Fprintf(w, "%sgas_price{config=%q} %d\n", metricsPrefix, c.name, m.fees[i].GasPrice)
+	}
+	gauge("excess_gas", "excess gas once the latest block is accepted")
+	for i, c := range cfgs {
+		fmt.Fprintf(w, "%sexcess_gas{config=%q} %d\n", metricsPrefix, c.name, m.fees[i].ExcessGas)
+	}
+	gauge("fee", "fee paid by the latest block")
+	for i, c := range cfgs {
+		fmt.Fprintf(w, "%sfee{config=%q,denomination=%q} %v\n", metricsPrefix, c.name, denom.name, m.fees[i].Fee)
+	}
+}
+
+// runServe tails blocks from a single input, replays them through each fee config
+// as they arrive and exposes the resulting fee market state as Prometheus metrics.
+// Alert rules, if any, are checked against the first fee config.
+// Metrics keep being served once input ends, until interrupted.
+func runServe(ctx context.Context, o *options) {
+	if o.rpcURI != "" || o.dbPath != "" {
+		fatal(fmt.Errorf("serve tails CSV input, it does not support -rpc and -db"))
+	}
+	paths := strings.Split(o.csvPaths, ",")
+	if len(paths) != 1 {
+		fatal(fmt.Errorf("serve tails a single input, got %d", len(paths)))
+	}
+
+	var (
+		market = &feeMarket{fees: make([]complexity.FeeData, len(o.feeCfgs))}
+		sims   = make([]*complexity.FeeSimulator, 0, len(o.feeCfgs))
+	)
+	for _, c := range o.feeCfgs {
+		sims = append(sims, complexity.NewFeeSimulator(c.cfg, o.denom.unit))
+	}
+	var alerts *alerter
+	if o.alerts != nil {
+		alerts = newAlerter(o)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		market.writeMetrics(w, o.feeCfgs, o.denom)
+	})
+	server := &http.Server{
+		Addr:              o.listenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		slog.Info("serving metrics", "addr", o.listenAddr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal(fmt.Errorf("failed serving metrics: %w", err))
+		}
+	}()
+
+	err := forEachRecord(ctx, paths[0], o.cols, o.onError, func(r complexity.Record) error {
+		if r.Height < o.minHeight || r.Height > o.maxHeight || r.Time < o.minTime || r.Time > o.maxTime {
+			return nil
+		}
+		if market.blocks > 0 && r.Height <= market.latest.Height {
+			slog.Warn("skipping out of order block", "height", r.Height, "latest", market.latest.Height)
+			return nil
+		}
+
+		fees := make([]complexity.FeeData, 0, len(sims))
+		for _, sim := range sims {
+			data, err := sim.Next(r)
+			if err != nil {
+				return err
+			}
+			fees = append(fees, data)
+		}
+
+		market.lock.Lock()
+		market.blocks++
+		market.latest = r
+		market.fees = fees
+		market.lock.Unlock()
+
+		if alerts != nil {
+			alerts.observe(ctx, r, fees[0], nil)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal(err)
+	}
+	if err == nil {
+		slog.Info("input ended, serving latest state", "blocks", market.blocks)
+		<-ctx.Done()
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		fatal(fmt.Errorf("failed shutting down metrics server: %w", err))
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// simulationEntry summarizes fees and throttling of a fee config over synthetic blocks
+type simulationEntry struct {
+	Config             string  `json:"config"`
+	Denomination       string  `json:"denomination"`
+	MaxFee             float64 `json:"max_fee"`
+	MedianFee          float64 `json:"median_fee"`
+	TimeAboveThreshold uint64  `json:"time_above_threshold"`
+	ThrottledBlocks    int     `json:"throttled_blocks"`
+}
+
+// runSimulate generates synthetic blocks whose complexities are sampled from
+// the dataset and replays each fee config over them
+func runSimulate(ctx context.Context, o *options) {
+	a := loadRecords(ctx, o)
+	sampler, err := complexity.FitComplexities(a.records)
+	if err != nil {
+		fatal(err)
+	}
+
+	var (
+		first = a.records[0]
+		last  = a.records[len(a.records)-1]
+		cfg   = complexity.SyntheticConfig{
+			Blocks:           o.syntheticBlocks,
+			MeanBlockDelay:   o.syntheticBlockDelay,
+			BurstProbability: o.burstProbability,
+			BurstBlocks:      o.burstBlocks,
+			BurstFactor:      o.burstFactor,
+			Scale:            o.scale,
+			Seed:             o.seed,
+		}
+	)
+	if cfg.MeanBlockDelay == 0 && len(a.records) > 1 {
+		cfg.MeanBlockDelay = float64(complexity.TimeDelta(first.Time, last.Time)) / float64(len(a.records)-1)
+	}
+	// synthetic blocks follow the dataset
+	synthetic := complexity.GenerateRecords(sampler, cfg, complexity.BlkHeightTime{Height: last.Height + 1, Time: last.Time})
+	slog.Info("generated synthetic blocks", "blocks", len(synthetic), "delay", cfg.MeanBlockDelay)
+
+	if o.syntheticOutPath != "" {
+		if err := writeRecords(o.syntheticOutPath, synthetic); err != nil {
+			fatal(err)
+		}
+	}
+
+	entries := make([]simulationEntry, 0, len(o.feeCfgs))
+	for _, c := range o.feeCfgs {
+		fees, err := complexity.CalculateFeeData(ctx, synthetic, c.cfg, o.denom.unit)
+		if err != nil {
+			handleError(o.onError, fmt.Errorf("failed simulating fee config %s: %w", c.name, err))
+			continue
+		}
+		threshold := c.cfg.MinGasPrice
+		if o.priceThreshold != 0 {
+			threshold = commonfee.GasPrice(o.priceThreshold)
+		}
+		summary := complexity.SummarizeFees(fees, threshold)
+		entries = append(entries, simulationEntry{
+			Config:             c.name,
+			Denomination:       o.denom.name,
+			MaxFee:             summary.MaxFee,
+			MedianFee:          summary.MedianFee,
+			TimeAboveThreshold: summary.TimeAboveThreshold,
+			ThrottledBlocks:    complexity.SimulateThrottling(synthetic, c.cfg).Blocks,
+		})
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(entries); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	printSimulationTable(entries, o.denom)
+}
+
+// printSimulationTable prints one aligned row per fee config on stdout
+func printSimulationTable(entries []simulationEntry, denom denomination) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "config\tmax_fee\tmedian_fee\ttime_above_threshold\tthrottled_blocks\n")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", e.Config, denom.format(e.MaxFee), denom.format(e.MedianFee), e.TimeAboveThreshold, e.ThrottledBlocks)
+	}
+	w.Flush()
+	fmt.Printf("fees in %s, time in seconds\n", denom.label)
+	fmt.Printf("\n")
+}
+
+// writeRecords writes [records] as Parquet if [path] has a .parquet extension, as CSV otherwise
+func writeRecords(path string, records []complexity.Record) error {
+	if isParquet(path) {
+		return writeRecordsParquet(path, records)
+	}
+	return writeRecordsCSV(path, records)
+}
+
+// writeRecordsCSV writes [records] in the default layout documented in readCsvFile,
+// without header, so that they can be read back with -csv
+func writeRecordsCSV(path string, records []complexity.Record) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	for _, r := range records {
+		row := make([]string, 0, recordsLen)
+		row = append(row,
+			r.ID.String(),
+			strconv.FormatUint(r.Height, 10),
+			strconv.FormatUint(r.Time, 10),
+		)
+		for _, c := range r.Complexity {
+			row = append(row, strconv.FormatUint(c, 10))
+		}
+		if err := w.Write(row); err != nil {
+			return fmt.Errorf("failed writing height %d to %s: %w", r.Height, path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
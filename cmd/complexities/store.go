@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	_ "modernc.org/sqlite"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// blockStoreSchema lays out one row per block, keyed by height. Height is the
+// rowid of the table and time has its own index, so that height and time ranges
+// are looked up without scanning the whole table.
+// SQLite integers are signed, so uint64 values are stored as the int64 sharing
+// their bits, see toStored and fromStored. Heights and times are not, so that
+// they sort as numbers, and must fit an int64.
+const blockStoreSchema = `
+CREATE TABLE IF NOT EXISTS blocks (
+	height       INTEGER PRIMARY KEY,
+	time         INTEGER NOT NULL,
+	id           BLOB    NOT NULL,
+	bandwidth    INTEGER NOT NULL,
+	db_read      INTEGER NOT NULL,
+	db_write     INTEGER NOT NULL,
+	compute      INTEGER NOT NULL,
+	observed_fee INTEGER,
+	tx_type      TEXT
+);
+CREATE INDEX IF NOT EXISTS blocks_time ON blocks (time);
+`
+
+// upsertBlockQuery stores a block, replacing the one stored at the same height if any,
+// so that ingesting overlapping inputs again updates blocks rather than failing
+const upsertBlockQuery = `
+INSERT INTO blocks (height, time, id, bandwidth, db_read, db_write, compute, observed_fee, tx_type)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (height) DO UPDATE SET
+	time = excluded.time,
+	id = excluded.id,
+	bandwidth = excluded.bandwidth,
+	db_read = excluded.db_read,
+	db_write = excluded.db_write,
+	compute = excluded.compute,
+	observed_fee = excluded.observed_fee,
+	tx_type = excluded.tx_type
+`
+
+const queryBlocksQuery = `
+SELECT height, time, id, bandwidth, db_read, db_write, compute, observed_fee, tx_type
+FROM blocks
+WHERE height BETWEEN ? AND ? AND time BETWEEN ? AND ?
+ORDER BY height
+`
+
+var (
+	errEmptyBlockStore = errors.New("empty block store, run ingest first")
+	errNotStorable     = fmt.Errorf("heights and times above %d can not be stored", math.MaxInt64)
+)
+
+// blockStore is a SQLite database of records, indexed by height and time
+type blockStore struct {
+	db *sql.DB
+}
+
+// openBlockStore opens the block store at [path], creating it if [create] is set
+func openBlockStore(path string, create bool) (*blockStore, error) {
+	if !create {
+		// the driver would otherwise create an empty database
+		if _, err := os.Stat(path); err != nil {
+			return nil, fmt.Errorf("failed opening block store %s: %w", path, err)
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed opening block store %s: %w", path, err)
+	}
+	if _, err := db.Exec(blockStoreSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed opening block store %s: %w", path, err)
+	}
+	return &blockStore{db: db}, nil
+}
+
+func (s *blockStore) close() error {
+	return s.db.Close()
+}
+
+// lastHeight returns the latest stored height, if any
+func (s *blockStore) lastHeight() (uint64, bool, error) {
+	var last sql.NullInt64
+	if err := s.db.QueryRow(`SELECT MAX(height) FROM blocks`).Scan(&last); err != nil {
+		return 0, false, err
+	}
+	return uint64(last.Int64), last.Valid, nil
+}
+
+// upsert stores [records] in a single transaction, replacing blocks
+// stored at the same heights
+func (s *blockStore) upsert(ctx context.Context, records []complexity.Record) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, upsertBlockQuery)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, r := range records {
+		if r.Height > math.MaxInt64 || r.Time > math.MaxInt64 {
+			return fmt.Errorf("%w, got height %d and time %d", errNotStorable, r.Height, r.Time)
+		}
+		var observedFee sql.NullInt64
+		if r.HasObservedFee {
+			observedFee = sql.NullInt64{Int64: toStored(r.ObservedFee), Valid: true}
+		}
+		txType := sql.NullString{String: r.TxType, Valid: r.TxType != ""}
+		_, err := stmt.ExecContext(ctx,
+			int64(r.Height),
+			int64(r.Time),
+			r.ID[:],
+			toStored(r.Complexity[commonfee.Bandwidth]),
+			toStored(r.Complexity[commonfee.DBRead]),
+			toStored(r.Complexity[commonfee.DBWrite]),
+			toStored(r.Complexity[commonfee.Compute]),
+			observedFee,
+			txType,
+		)
+		if err != nil {
+			return fmt.Errorf("failed storing height %d: %w", r.Height, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// query returns the stored records within heights [minHeight, maxHeight]
+// and times [minTime, maxTime], sorted by height
+func (s *blockStore) query(ctx context.Context, minHeight, maxHeight, minTime, maxTime uint64) ([]complexity.Record, error) {
+	if _, ok, err := s.lastHeight(); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errEmptyBlockStore
+	}
+
+	rows, err := s.db.QueryContext(ctx, queryBlocksQuery,
+		storedBound(minHeight),
+		storedBound(maxHeight),
+		storedBound(minTime),
+		storedBound(maxTime),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := make([]complexity.Record, 0)
+	for rows.Next() {
+		var (
+			r           complexity.Record
+			height, t   int64
+			id          []byte
+			dims        [commonfee.NumDimensions]int64
+			observedFee sql.NullInt64
+			txType      sql.NullString
+		)
+		err := rows.Scan(
+			&height,
+			&t,
+			&id,
+			&dims[commonfee.Bandwidth],
+			&dims[commonfee.DBRead],
+			&dims[commonfee.DBWrite],
+			&dims[commonfee.Compute],
+			&observedFee,
+			&txType,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if r.ID, err = ids.ToID(id); err != nil {
+			return nil, fmt.Errorf("failed reading ID at height %d: %w", height, err)
+		}
+		r.Height, r.Time = uint64(height), uint64(t)
+		for d, v := range dims {
+			r.Complexity[d] = fromStored(v)
+		}
+		if observedFee.Valid {
+			r.ObservedFee, r.HasObservedFee = fromStored(observedFee.Int64), true
+		}
+		r.TxType = txType.String
+		res = append(res, r)
+	}
+	return res, rows.Err()
+}
+
+// toStored returns the int64 sharing the bits of [v]
+func toStored(v uint64) int64 {
+	return int64(v)
+}
+
+// fromStored returns the uint64 sharing the bits of [v], as stored by toStored
+func fromStored(v int64) uint64 {
+	return uint64(v)
+}
+
+// storedBound caps a height or time bound to the range of stored ones
+func storedBound(v uint64) int64 {
+	return int64(min(v, math.MaxInt64))
+}
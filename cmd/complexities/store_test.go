@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"process_data/pkg/complexity"
+)
+
+// storeRecords returns one record per height in [low, up], ten seconds apart,
+// with complexities, observed fees and tx types derived from their height
+func storeRecords(low, up uint64) []complexity.Record {
+	res := make([]complexity.Record, 0, up-low+1)
+	for h := low; h <= up; h++ {
+		r := complexity.Record{
+			ID:            ids.GenerateTestID(),
+			BlkHeightTime: complexity.BlkHeightTime{Height: h, Time: 1_700_000_000 + 10*h},
+		}
+		for d := range r.Complexity {
+			r.Complexity[d] = h * uint64(d+1)
+		}
+		if h%2 == 0 {
+			r.ObservedFee, r.HasObservedFee = 100*h, true
+		}
+		if h%3 == 0 {
+			r.TxType = "AddPermissionlessValidatorTx"
+		}
+		res = append(res, r)
+	}
+	return res
+}
+
+// newTestStore returns a block store in a temporary directory, holding [records]
+func newTestStore(t *testing.T, records []complexity.Record) *blockStore {
+	t.Helper()
+
+	store, err := openBlockStore(filepath.Join(t.TempDir(), "blocks.sqlite"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.close() })
+	if err := store.upsert(context.Background(), records); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestBlockStoreRoundTrip(t *testing.T) {
+	records := storeRecords(1, 20)
+	// uint64 values above the int64 range are stored as well
+	records[3].Complexity[0] = 1<<64 - 1
+	records[4].ObservedFee, records[4].HasObservedFee = 1<<63, true
+
+	store := newTestStore(t, records)
+	got, err := store.query(context.Background(), 0, 1<<64-1, 0, 1<<64-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, records) {
+		t.Fatalf("expected %+v, got %+v", records, got)
+	}
+}
+
+func TestBlockStoreQueryRanges(t *testing.T) {
+	var (
+		records = storeRecords(1, 20)
+		store   = newTestStore(t, records)
+	)
+
+	tests := []struct {
+		name                 string
+		minHeight, maxHeight uint64
+		minTime, maxTime     uint64
+		expected             []uint64 // heights of the records read
+	}{
+		{name: "height range", minHeight: 5, maxHeight: 7, maxTime: 1<<64 - 1, expected: []uint64{5, 6, 7}},
+		{name: "time range", maxHeight: 1<<64 - 1, minTime: 1_700_000_095, maxTime: 1_700_000_120, expected: []uint64{10, 11, 12}},
+		{name: "both ranges", minHeight: 11, maxHeight: 20, minTime: 1_700_000_000, maxTime: 1_700_000_120, expected: []uint64{11, 12}},
+		{name: "out of stored heights", minHeight: 21, maxHeight: 30, maxTime: 1<<64 - 1, expected: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.query(context.Background(), tt.minHeight, tt.maxHeight, tt.minTime, tt.maxTime)
+			if err != nil {
+				t.Fatal(err)
+			}
+			heights := make([]uint64, 0, len(got))
+			for _, r := range got {
+				heights = append(heights, r.Height)
+			}
+			if !slices.Equal(heights, tt.expected) {
+				t.Fatalf("expected heights %v, got %v", tt.expected, heights)
+			}
+		})
+	}
+}
+
+func TestBlockStoreUpsert(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		store = newTestStore(t, storeRecords(1, 10))
+	)
+
+	// blocks 9 and 10 are exported again with updated complexities, along with new blocks
+	update := storeRecords(9, 15)
+	update[0].Complexity[0], update[1].Complexity[0] = 1_000, 2_000
+	if err := store.upsert(ctx, update); err != nil {
+		t.Fatal(err)
+	}
+
+	last, ok, err := store.lastHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok || last != 15 {
+		t.Fatalf("expected last height 15, got %d", last)
+	}
+	got, err := store.query(ctx, 9, 15, 0, 1<<64-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, update) {
+		t.Fatalf("expected %+v, got %+v", update, got)
+	}
+}
+
+func TestBlockStoreEmpty(t *testing.T) {
+	store := newTestStore(t, nil)
+
+	if _, ok, err := store.lastHeight(); err != nil || ok {
+		t.Fatalf("expected no last height, got %v, %v", ok, err)
+	}
+	if _, err := store.query(context.Background(), 0, 1<<64-1, 0, 1<<64-1); !errors.Is(err, errEmptyBlockStore) {
+		t.Fatalf("expected %v, got %v", errEmptyBlockStore, err)
+	}
+}
+
+func TestBlockStoreRejectsUnstorableHeights(t *testing.T) {
+	var (
+		store   = newTestStore(t, nil)
+		records = storeRecords(1, 2)
+	)
+	records[1].Height = 1 << 63
+
+	if err := store.upsert(context.Background(), records); !errors.Is(err, errNotStorable) {
+		t.Fatalf("expected %v, got %v", errNotStorable, err)
+	}
+	// the whole batch is rolled back
+	if _, ok, _ := store.lastHeight(); ok {
+		t.Fatal("expected no record stored")
+	}
+}
+
+func TestOpenBlockStoreMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sqlite")
+	if _, err := openBlockStore(path, false); err == nil {
+		t.Fatalf("expected opening missing store %s to fail", path)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// sweepResult pairs a swept fee config with the summary of its fees
+// and its response to the top total gas peak
+type sweepResult struct {
+	cfg      commonfee.Config
+	summary  complexity.FeeSummary
+	response complexity.PeakResponse
+}
+
+// feasible returns whether [r] satisfies the constraints set in [o]
+func (r sweepResult) feasible(o *options) bool {
+	switch {
+	case o.maxMedianFee != 0 && r.summary.MedianFee > o.maxMedianFee:
+		return false
+	case o.minPeakFee != 0 && r.response.MaxFee < o.minPeakFee:
+		return false
+	case o.maxRecovery != 0 && (!r.response.Recovered || r.response.RecoveryTime > uint64(o.maxRecovery.Seconds())):
+		return false
+	default:
+		return true
+	}
+}
+
+// dominates returns whether [r] is at least as good as [other] on median fee,
+// peak fee and recovery time, and strictly better on at least one of them.
+// A config which never recovers is worse than any which does.
+func (r sweepResult) dominates(other sweepResult) bool {
+	var (
+		recovery      = recoveryRank(r.response)
+		otherRecovery = recoveryRank(other.response)
+	)
+	noWorse := r.summary.MedianFee <= other.summary.MedianFee &&
+		r.response.MaxFee >= other.response.MaxFee &&
+		recovery <= otherRecovery
+	better := r.summary.MedianFee < other.summary.MedianFee ||
+		r.response.MaxFee > other.response.MaxFee ||
+		recovery < otherRecovery
+	return noWorse && better
+}
+
+func recoveryRank(r complexity.PeakResponse) uint64 {
+	if !r.Recovered {
+		return math.MaxUint64
+	}
+	return r.RecoveryTime
+}
+
+// paretoFront returns the results of [results] which no other result dominates,
+// in their original order
+func paretoFront(results []sweepResult) []sweepResult {
+	res := make([]sweepResult, 0)
+	for i, r := range results {
+		dominated := false
+		for j, other := range results {
+			if i != j && other.dominates(r) {
+				dominated = true
+				break
+			}
+		}
+		if !dominated {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// parseSweepValues parses either a comma separated list of values or
+// a start:stop:step range, both ends included. An empty [spec] returns [fallback].
+func parseSweepValues(spec string, fallback uint64) ([]uint64, error) {
+	if spec == "" {
+		return []uint64{fallback}, nil
+	}
+
+	if bounds := strings.Split(spec, ":"); len(bounds) == 3 {
+		var values [3]uint64
+		for i, b := range bounds {
+			v, err := strconv.ParseUint(b, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q: %w", spec, err)
+			}
+			values[i] = v
+		}
+		start, stop, step := values[0], values[1], values[2]
+		if step == 0 || start > stop {
+			return nil, fmt.Errorf("invalid range %q, start must not exceed stop and step must be positive", spec)
+		}
+		res := make([]uint64, 0, (stop-start)/step+1)
+		for v := start; v <= stop && v >= start; v += step {
+			res = append(res, v)
+		}
+		return res, nil
+	}
+
+	res := make([]uint64, 0)
+	for _, s := range strings.Split(spec, ",") {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sweep value %q: %w", s, err)
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
+
+// sweepConfigs returns all combinations of the swept parameters,
+// taking those which are not swept from [base]
+func sweepConfigs(base commonfee.Config, o *options) ([]commonfee.Config, error) {
+	targetRates, err := parseSweepValues(o.gasTargetRates, uint64(base.TargetGasPerSecond))
+	if err != nil {
+		return nil, err
+	}
+	denominators, err := parseSweepValues(o.updateDenominators, uint64(base.ExcessConversionConstant))
+	if err != nil {
+		return nil, err
+	}
+	maxGas, err := parseSweepValues(o.maxGasPerSeconds, uint64(base.MaxGasPerSecond))
+	if err != nil {
+		return nil, err
+	}
+	minPrices, err := parseSweepValues(o.minGasPrices, uint64(base.MinGasPrice))
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]commonfee.Config, 0, len(targetRates)*len(denominators)*len(maxGas)*len(minPrices))
+	for _, targetRate := range targetRates {
+		for _, denominator := range denominators {
+			for _, m := range maxGas {
+				for _, minPrice := range minPrices {
+					cfg := base
+					cfg.TargetGasPerSecond = commonfee.Gas(targetRate)
+					cfg.ExcessConversionConstant = commonfee.Gas(denominator)
+					cfg.MaxGasPerSecond = commonfee.Gas(m)
+					cfg.MinGasPrice = commonfee.GasPrice(minPrice)
+					if err := validateFeeConfig(cfg); err != nil {
+						return nil, fmt.Errorf("invalid swept fee config %+v: %w", cfg, err)
+					}
+					res = append(res, cfg)
+				}
+			}
+		}
+	}
+	return res, nil
+}
+
+// runSweep replays the whole dataset with each combination of swept
+// fee parameters and summarizes resulting fees. Combinations are replayed
+// concurrently by a pool of -parallelism workers; results keep the sweep order.
+func runSweep(ctx context.Context, o *options) {
+	cfgs, err := sweepConfigs(o.feeCfg(), o)
+	if err != nil {
+		fatal(err)
+	}
+
+	a := loadRecords(ctx, o)
+
+	// the top peak is a property of traffic, so it is found once with the base config
+	base := o.feeCfg()
+	peaks, err := complexity.FindTotalGasPeaks(ctx, o.detector, a.records, base.Weights, uint64(base.TargetGasPerSecond), 1, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		fatal(err)
+	}
+	var topPeak complexity.Peak
+	if len(peaks) > 0 {
+		topPeak = peaks[len(peaks)-1]
+		slog.Info("top total gas peak", "start", topPeak.StartHeight, "blocks", topPeak.BlocksCount)
+	}
+	slog.Info("sweeping fee configs", "combinations", len(cfgs), "parallelism", o.parallelism)
+
+	var (
+		swept   = make([]sweepResult, len(cfgs))
+		errs    = make([]error, len(cfgs))
+		jobs    = make(chan int)
+		done    atomic.Int64
+		tracker = newSweepProgress(len(cfgs))
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < min(o.parallelism, len(cfgs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				swept[i], errs[i] = sweepConfig(ctx, a, cfgs[i], topPeak, len(peaks) > 0, o)
+				tracker.report(int(done.Add(1)))
+			}
+		}()
+	}
+	for i := range cfgs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	results := make([]sweepResult, 0, len(cfgs))
+	for i, r := range swept {
+		if errs[i] != nil {
+			handleError(o.onError, fmt.Errorf("failed sweeping fee config %+v: %w", cfgs[i], errs[i]))
+			continue
+		}
+		if !r.feasible(o) {
+			continue
+		}
+		results = append(results, r)
+	}
+	slog.Info("feasible fee configs", "count", len(results))
+	if o.pareto {
+		results = paretoFront(results)
+		slog.Info("Pareto-optimal fee configs", "count", len(results))
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(sweepEntries(results, o.denom)); err != nil {
+			fatal(err)
+		}
+	} else {
+		printSweepTable(results, o.denom)
+	}
+	if o.sweepOutPath != "" {
+		if err := writeSweepCSV(o.sweepOutPath, results, o.denom); err != nil {
+			fatal(err)
+		}
+	}
+}
+
+// sweepConfig replays the records of [a] with [cfg] and summarizes resulting fees.
+// The response to [topPeak] is evaluated only if [hasPeak].
+func sweepConfig(ctx context.Context, a *analysis, cfg commonfee.Config, topPeak complexity.Peak, hasPeak bool, o *options) (sweepResult, error) {
+	start := time.Now()
+	fees, err := a.calculateFeeData(ctx, a.records, cfg, 0)
+	if err != nil {
+		return sweepResult{}, err
+	}
+	threshold := cfg.MinGasPrice
+	if o.priceThreshold != 0 {
+		threshold = commonfee.GasPrice(o.priceThreshold)
+	}
+	r := sweepResult{
+		cfg:     cfg,
+		summary: complexity.SummarizeFees(fees, threshold),
+	}
+	if hasPeak {
+		r.response = complexity.RespondToPeak(fees, topPeak, cfg.MinGasPrice)
+	}
+	slog.Debug("fee config swept", "params", fmt.Sprintf("%+v", cfg), "elapsed", time.Since(start))
+	return r, nil
+}
+
+// sweepProgress logs how many fee configs have been swept,
+// at most once every sweepProgressInterval
+type sweepProgress struct {
+	lock    sync.Mutex
+	total   int
+	start   time.Time
+	lastLog time.Time
+}
+
+const sweepProgressInterval = 5 * time.Second
+
+func newSweepProgress(total int) *sweepProgress {
+	now := time.Now()
+	return &sweepProgress{total: total, start: now, lastLog: now}
+}
+
+// report logs progress once [done] configs are swept. The last config is always logged.
+func (p *sweepProgress) report(done int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	now := time.Now()
+	if done < p.total && now.Sub(p.lastLog) < sweepProgressInterval {
+		return
+	}
+	p.lastLog = now
+
+	elapsed := now.Sub(p.start)
+	eta := time.Duration(float64(elapsed) / float64(done) * float64(p.total-done))
+	slog.Info("sweep progress", "done", done, "total", p.total, "elapsed", elapsed.Round(time.Second), "eta", eta.Round(time.Second))
+}
+
+// sweepEntry is the JSON form of a sweepResult
+type sweepEntry struct {
+	GasTargetRate      uint64  `json:"gas_target_rate"`
+	UpdateDenominator  uint64  `json:"update_denominator"`
+	MaxGasPerSecond    uint64  `json:"max_gas_per_second"`
+	MinGasPrice        uint64  `json:"min_gas_price"`
+	Denomination       string  `json:"denomination"`
+	MaxFee             float64 `json:"max_fee"`
+	MedianFee          float64 `json:"median_fee"`
+	TimeAboveThreshold uint64  `json:"time_above_threshold"`
+	PeakFee            float64 `json:"peak_fee"`
+	RecoveryTime       uint64  `json:"recovery_time"`
+	Recovered          bool    `json:"recovered"`
+}
+
+func sweepEntries(results []sweepResult, denom denomination) []sweepEntry {
+	res := make([]sweepEntry, 0, len(results))
+	for _, r := range results {
+		res = append(res, sweepEntry{
+			GasTargetRate:      uint64(r.cfg.TargetGasPerSecond),
+			UpdateDenominator:  uint64(r.cfg.ExcessConversionConstant),
+			MaxGasPerSecond:    uint64(r.cfg.MaxGasPerSecond),
+			MinGasPrice:        uint64(r.cfg.MinGasPrice),
+			Denomination:       denom.name,
+			MaxFee:             r.summary.MaxFee,
+			MedianFee:          r.summary.MedianFee,
+			TimeAboveThreshold: r.summary.TimeAboveThreshold,
+			PeakFee:            r.response.MaxFee,
+			RecoveryTime:       r.response.RecoveryTime,
+			Recovered:          r.response.Recovered,
+		})
+	}
+	return res
+}
+
+var sweepHeader = []string{"gas_target_rate", "update_denominator", "max_gas_per_second", "min_gas_price", "max_fee", "median_fee", "time_above_threshold", "peak_fee", "recovery_time", "recovered"}
+
+// sweepRow formats [r], fees being printed as [denom] tells
+func sweepRow(r sweepResult, denom denomination) []string {
+	return []string{
+		strconv.FormatUint(uint64(r.cfg.TargetGasPerSecond), 10),
+		strconv.FormatUint(uint64(r.cfg.ExcessConversionConstant), 10),
+		strconv.FormatUint(uint64(r.cfg.MaxGasPerSecond), 10),
+		strconv.FormatUint(uint64(r.cfg.MinGasPrice), 10),
+		denom.format(r.summary.MaxFee),
+		denom.format(r.summary.MedianFee),
+		strconv.FormatUint(r.summary.TimeAboveThreshold, 10),
+		denom.format(r.response.MaxFee),
+		strconv.FormatUint(r.response.RecoveryTime, 10),
+		strconv.FormatBool(r.response.Recovered),
+	}
+}
+
+// printSweepTable prints one aligned row per swept config on stdout
+func printSweepTable(results []sweepResult, denom denomination) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\n", strings.Join(sweepHeader, "\t"))
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\n", strings.Join(sweepRow(r, denom), "\t"))
+	}
+	w.Flush()
+	fmt.Printf("fees in %s, time in seconds\n", denom.label)
+	fmt.Printf("\n")
+}
+
+// writeSweepCSV writes one row per swept config, preceded by a header.
+// Fees are expressed in [denom].
+func writeSweepCSV(path string, results []sweepResult, denom denomination) error {
+	f, err := createOutput(path)
+	if err != nil {
+		return fmt.Errorf("failed creating %s: %w", path, err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := make([]string, len(sweepHeader))
+	copy(header, sweepHeader)
+	header[4] += "_" + denom.name
+	header[5] += "_" + denom.name
+	header[7] += "_" + denom.name
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("failed writing header to %s: %w", path, err)
+	}
+	for _, r := range results {
+		if err := w.Write(sweepRow(r, denom)); err != nil {
+			return fmt.Errorf("failed writing sweep row to %s: %w", path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed flushing %s: %w", path, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,28 @@
+# Table of records_arrow.parquet, read by TestReadParquetFixture.
+#
+# The file is written by an Arrow Parquet writer rather than by parquet-go, so
+# that reading it checks compatibility with files produced by other tools:
+# columns come in another order, carry an extra tx_count column, and pages are
+# snappy compressed and dictionary encoded, as pyarrow writes them by default.
+# The committed file was written by the Apache Arrow Go writer, pqarrow, with
+# pyarrow's default writer properties and, as pyarrow, a required root group;
+# running this script with pyarrow writes an equivalent file.
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+MAX_UINT64 = 2**64 - 1
+
+table = pa.table(
+    {
+        "time": pa.array([1_700_000_000, 1_700_000_002, 1_700_000_002, 1_700_000_005, 1_700_000_011], pa.uint64()),
+        "height": pa.array([15_000_000, 15_000_001, 15_000_002, 15_000_003, 15_000_004], pa.uint64()),
+        "id": pa.array([bytes([i]) * 32 for i in range(1, 6)], pa.binary(32)),
+        "bandwidth": pa.array([612, 1_024, 0, 2_048, MAX_UINT64], pa.uint64()),
+        "db_read": pa.array([3, 7, 0, 12, 1], pa.uint64()),
+        "db_write": pa.array([2, 5, 0, 9, 1], pa.uint64()),
+        "compute": pa.array([2_500, 7_000, 0, 12_000, 1], pa.uint64()),
+        "observed_fee": pa.array([1_000_000, None, None, 2_750_000, None], pa.uint64()),
+        "tx_count": pa.array([1, 2, 0, 3, 1], pa.int64()),
+    }
+)
+pq.write_table(table, "records_arrow.parquet")
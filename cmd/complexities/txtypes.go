@@ -0,0 +1,148 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// txTypesPeakCount is the number of tx types listed per top peak
+const txTypesPeakCount = 3
+
+// txTypesPeak breaks down the top peak of a trace by tx type
+type txTypesPeak struct {
+	Trace       string                   `json:"trace"`
+	StartHeight uint64                   `json:"start_height"`
+	Blocks      int                      `json:"blocks"`
+	TxTypes     []complexity.TxTypeShare `json:"tx_types"`
+}
+
+type txTypesReport struct {
+	Dataset []complexity.TxTypeShare `json:"dataset"`
+	Peaks   []txTypesPeak            `json:"peaks"`
+}
+
+// runTxTypes reports which tx types dominate each dimension over the whole dataset
+// and which ones drive the top peak of each dimension and of the total gas.
+// Tx types are read from the tx_type column, which must be mapped with -columns.
+func runTxTypes(ctx context.Context, o *options) {
+	if o.cols.txType < 0 {
+		fatal(fmt.Errorf("%w: %s, map it with -columns", errMissingColumn, txTypeColumn))
+	}
+	a := loadRecords(ctx, o)
+	if !slices.ContainsFunc(a.records, func(r complexity.Record) bool { return r.TxType != "" }) {
+		slog.Warn("no block carries a tx type", "column", txTypeColumn)
+	}
+	a.computeTargets()
+	a.findPeaks(ctx)
+
+	weights := o.feeCfg().Weights
+	r := txTypesReport{
+		Dataset: complexity.TxTypeBreakdown(a.records, weights),
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		if peaks := a.topPeaks[d]; len(peaks) > 0 {
+			r.Peaks = append(r.Peaks, txTypesPeakOf(a.records, complexity.DimensionStrings[d], peaks[len(peaks)-1], weights))
+		}
+	}
+	if len(a.totalGasPeaks) > 0 {
+		r.Peaks = append(r.Peaks, txTypesPeakOf(a.records, totalGasName, a.totalGasPeaks[len(a.totalGasPeaks)-1], weights))
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(r); err != nil {
+			fatal(err)
+		}
+		return
+	}
+	printTxTypesTable(a.stdout, r.Dataset)
+	for _, p := range r.Peaks {
+		printTxTypesPeak(a.stdout, p)
+	}
+	fmt.Fprintf(a.stdout, "\n")
+}
+
+// txTypesPeakOf breaks down [peak] of trace [name], sorting tx types
+// by the complexity of that trace, or by gas for the total gas
+func txTypesPeakOf(records []complexity.Record, name string, peak complexity.Peak, weights commonfee.Dimensions) txTypesPeak {
+	shares := complexity.PeakTxTypes(records, peak, weights)
+	slices.SortStableFunc(shares, func(lhs, rhs complexity.TxTypeShare) int {
+		return cmp.Compare(txTypeValue(rhs, name), txTypeValue(lhs, name))
+	})
+	return txTypesPeak{
+		Trace:       name,
+		StartHeight: peak.StartHeight,
+		Blocks:      peak.BlocksCount,
+		TxTypes:     shares,
+	}
+}
+
+// txTypeValue returns the complexity of [s] along trace [name], or its gas for the total gas
+func txTypeValue(s complexity.TxTypeShare, name string) uint64 {
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		if complexity.DimensionStrings[d] == name {
+			return s.Complexity[d]
+		}
+	}
+	return s.Gas
+}
+
+// printTxTypesTable prints, for each tx type, its share of each dimension and of gas
+func printTxTypesTable(out io.Writer, shares []complexity.TxTypeShare) {
+	var totals complexity.TxTypeShare
+	for _, s := range shares {
+		for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+			totals.Complexity[d] += s.Complexity[d]
+		}
+		totals.Gas += s.Gas
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := []string{"tx type", "blocks"}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		header = append(header, complexity.DimensionStrings[d])
+	}
+	header = append(header, "gas")
+	fmt.Fprintf(w, "%s\n", strings.Join(header, "\t"))
+	for _, s := range shares {
+		row := []string{s.TxType, strconv.Itoa(s.Blocks)}
+		for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+			row = append(row, formatShare(s.Complexity[d], totals.Complexity[d]))
+		}
+		row = append(row, formatShare(s.Gas, totals.Gas))
+		fmt.Fprintf(w, "%s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	fmt.Fprintf(out, "\n")
+}
+
+// printTxTypesPeak prints the tx types contributing most to [p]
+func printTxTypesPeak(out io.Writer, p txTypesPeak) {
+	total := uint64(0)
+	for _, s := range p.TxTypes {
+		total += txTypeValue(s, p.Trace)
+	}
+	top := make([]string, 0, txTypesPeakCount)
+	for _, s := range p.TxTypes[:min(len(p.TxTypes), txTypesPeakCount)] {
+		top = append(top, fmt.Sprintf("%s %s", s.TxType, formatShare(txTypeValue(s, p.Trace), total)))
+	}
+	fmt.Fprintf(out, "top %s peak, start height %d, blocks %d: %s\n", p.Trace, p.StartHeight, p.Blocks, strings.Join(top, ", "))
+}
+
+// formatShare prints [v] as a percentage of [total]
+func formatShare(v, total uint64) string {
+	if total == 0 {
+		return "0.00%"
+	}
+	return strconv.FormatFloat(100*float64(v)/float64(total), 'f', 2, 64) + "%"
+}
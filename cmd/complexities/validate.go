@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+// Checks validate reports issues of
+const (
+	checkCSV     = "csv"     // the file is not well formed CSV
+	checkColumns = "columns" // a row lacks mapped columns
+	checkID      = "id"      // a block ID is not a valid ID
+	checkParse   = "parse"   // a numeric field is not an unsigned integer
+	checkHeight  = "height"  // heights are not strictly increasing
+	checkTime    = "time"    // a time goes backwards, predates the chain or is in the future
+)
+
+var validateChecks = []string{checkCSV, checkColumns, checkID, checkParse, checkHeight, checkTime}
+
+// saneTimeFrom is the earliest plausible block time, Avalanche mainnet launch on 2020-09-21.
+// saneTimeSlack is how far ahead of the local clock block times are deemed plausible.
+const (
+	saneTimeFrom  = 1600646400
+	saneTimeSlack = time.Hour
+)
+
+type schemaIssue struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Check  string `json:"check"`
+	Detail string `json:"detail"`
+}
+
+// schemaReport sums up the validation of input files. Issues are capped
+// at -max-issues, Counts accounting for all of them by check.
+type schemaReport struct {
+	Files  []string       `json:"files"`
+	Rows   int            `json:"rows"`
+	Valid  int            `json:"valid_rows"`
+	Gaps   int            `json:"height_gaps"`
+	Counts map[string]int `json:"counts"`
+	Issues []schemaIssue  `json:"issues"`
+}
+
+func (r *schemaReport) add(issue schemaIssue, maxIssues int) {
+	r.Counts[issue.Check]++
+	if len(r.Issues) < maxIssues {
+		r.Issues = append(r.Issues, issue)
+	}
+}
+
+// total returns the number of issues found, reported or not
+func (r *schemaReport) total() int {
+	res := 0
+	for _, c := range r.Counts {
+		res += c
+	}
+	return res
+}
+
+// runValidate checks input files against the expected layout, i.e. row length,
+// parseable fields, block IDs, strictly increasing heights and sane times, and prints
+// each issue with its line number. It exits with a non-zero code if any is found,
+// so that it can gate pipelines ahead of long analyses.
+// Files are checked independently, ordering is not checked across files.
+func runValidate(ctx context.Context, o *options) {
+	if o.rpcURI != "" || o.dbPath != "" {
+		fatal(fmt.Errorf("validate checks CSV files, it does not support -rpc and -db"))
+	}
+	paths, err := expandInputPaths(strings.Split(o.csvPaths, ","))
+	if err != nil {
+		fatal(err)
+	}
+	r := &schemaReport{
+		Files:  paths,
+		Counts: make(map[string]int, len(validateChecks)),
+		Issues: make([]schemaIssue, 0),
+	}
+	for _, path := range paths {
+		if err := validateCsvFile(ctx, path, o.cols, o.maxIssues, r); err != nil {
+			fatal(err)
+		}
+	}
+
+	if o.output == outputJSON {
+		if err := printJSON(r); err != nil {
+			fatal(err)
+		}
+	} else {
+		printSchemaReport(r)
+	}
+	if n := r.total(); n > 0 {
+		fatal(fmt.Errorf("%d issues found in %d rows", n, r.Rows))
+	}
+}
+
+// validateCsvFile adds to [r] the issues of [filePath], read as forEachRecord does.
+// Lines are numbered from 1, as editors do. A leading header row is skipped.
+func validateCsvFile(ctx context.Context, filePath string, cols columns, maxIssues int, r *schemaReport) error {
+	if isParquet(filePath) {
+		return fmt.Errorf("validate checks CSV files, got %s", filePath)
+	}
+	var in io.Reader = os.Stdin
+	if filePath != stdinPath && filePath != "" {
+		f, err := os.Open(filePath)
+		if err != nil {
+			return fmt.Errorf("unable to read input file %s: %w", filePath, err)
+		}
+		defer f.Close()
+		in = f
+	}
+	content, err := decompress(filePath, in)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	csvReader := csv.NewReader(content)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	var (
+		maxTime  = uint64(time.Now().Add(saneTimeSlack).Unix())
+		prev     rowValues
+		hasPrev  bool
+		issueFor = func(line int, check, detail string) {
+			r.add(schemaIssue{File: filePath, Line: line, Check: check, Detail: detail}, maxIssues)
+		}
+	)
+	for ri := 0; ; ri++ {
+		if ri%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				issueFor(parseErr.Line, checkCSV, parseErr.Err.Error())
+				if errors.Is(parseErr.Err, csv.ErrQuote) || errors.Is(parseErr.Err, csv.ErrBareQuote) {
+					continue
+				}
+			}
+			return fmt.Errorf("unable to parse file as CSV for %s: %w", filePath, err)
+		}
+		line, _ := csvReader.FieldPos(0)
+		if ri == 0 && isHeaderRow(row, cols) {
+			continue
+		}
+
+		r.Rows++
+		v, issues := checkRow(row, cols)
+		for _, issue := range issues {
+			issueFor(line, issue.check, issue.detail)
+		}
+		if len(issues) > 0 {
+			continue
+		}
+
+		valid := true
+		switch {
+		case v.time < saneTimeFrom:
+			issueFor(line, checkTime, fmt.Sprintf("time %d predates mainnet launch", v.time))
+			valid = false
+		case v.time > maxTime:
+			issueFor(line, checkTime, fmt.Sprintf("time %d is in the future", v.time))
+			valid = false
+		}
+		if hasPrev {
+			switch {
+			case v.height == prev.height:
+				issueFor(line, checkHeight, fmt.Sprintf("duplicate height %d, first seen at line %d", v.height, prev.line))
+				valid = false
+			case v.height < prev.height:
+				issueFor(line, checkHeight, fmt.Sprintf("height %d lower than %d at line %d", v.height, prev.height, prev.line))
+				valid = false
+			case v.time < prev.time:
+				issueFor(line, checkTime, fmt.Sprintf("time %d lower than %d at line %d", v.time, prev.time, prev.line))
+				valid = false
+			case v.height > prev.height+1:
+				r.Gaps++
+			}
+		}
+		if valid {
+			r.Valid++
+		}
+		if !hasPrev || v.height > prev.height {
+			v.line = line
+			prev, hasPrev = v, true
+		}
+	}
+}
+
+// rowValues holds the fields of a row ordering is checked on
+type rowValues struct {
+	line   int
+	height uint64
+	time   uint64
+}
+
+type rowIssue struct {
+	check  string
+	detail string
+}
+
+// checkRow checks each mapped field of [row] in turn, so that all the issues of
+// a row are reported at once, unlike parseRecord which stops at the first one
+func checkRow(row []string, cols columns) (rowValues, []rowIssue) {
+	if err := cols.checkRowLen(row, 0); err != nil {
+		detail := fmt.Sprintf("%d fields, required columns need at least %d", len(row), cols.minRowLen())
+		if cols.fixedLayout {
+			detail = fmt.Sprintf("%d fields, expected %d or %d", len(row), recordsLen, recordsWithFeeLen)
+		}
+		return rowValues{}, []rowIssue{{check: checkColumns, detail: detail}}
+	}
+
+	var (
+		res    rowValues
+		issues []rowIssue
+		parse  = func(name string, field int) uint64 {
+			v, err := strconv.ParseUint(row[field], 10, 64)
+			if err != nil {
+				issues = append(issues, rowIssue{check: checkParse, detail: fmt.Sprintf("%s %q is not an unsigned integer", name, row[field])})
+			}
+			return v
+		}
+	)
+	if _, err := ids.FromString(row[cols.id]); err != nil {
+		issues = append(issues, rowIssue{check: checkID, detail: fmt.Sprintf("block ID %q: %v", row[cols.id], err)})
+	}
+	res.height = parse("height", cols.height)
+	res.time = parse("time", cols.time)
+	for d, index := range cols.complexity {
+		if index >= 0 {
+			parse(complexityColumns[d], index)
+		}
+	}
+	if cols.hasObservedFee(row) {
+		parse("observed fee", cols.observedFee)
+	}
+	return res, issues
+}
+
+// printSchemaReport prints issues, one per line prefixed by file and line number
+// as compilers do, then the count of issues by check
+func printSchemaReport(r *schemaReport) {
+	for _, issue := range r.Issues {
+		fmt.Printf("%s:%d: %s: %s\n", issue.File, issue.Line, issue.Check, issue.Detail)
+	}
+	if n := r.total(); n > len(r.Issues) {
+		fmt.Printf("... %d more issues\n", n-len(r.Issues))
+	}
+	if len(r.Issues) > 0 {
+		fmt.Printf("\n")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "check\tissues\n")
+	for _, c := range validateChecks {
+		fmt.Fprintf(w, "%s\t%d\n", c, r.Counts[c])
+	}
+	w.Flush()
+	fmt.Printf("%d rows in %d files, %d valid, %d height gaps\n", r.Rows, len(r.Files), r.Valid, r.Gaps)
+	fmt.Printf("\n")
+}
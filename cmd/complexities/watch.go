@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"process_data/pkg/complexity"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// watcher extends the analysis with blocks appended to its input once it is done.
+// Targets and peaks are recomputed over the trailing watch window at each poll.
+type watcher struct {
+	a   *analysis
+	out plotOutput
+
+	// alerted holds, per trace, the start height of the last peak alerted about,
+	// so that each peak is alerted about once
+	alerted map[string]uint64
+
+	// sim and alerts check alert rules against appended blocks, nil if no rules are set
+	sim    *complexity.FeeSimulator
+	alerts *alerter
+}
+
+// watch follows the single input file of [a] and extends the analysis as blocks
+// are appended to it, until interrupted. Plots, unless disabled, are redrawn over
+// the watch window, and a new peak starting is alerted about.
+func (a *analysis) watch(ctx context.Context, out plotOutput) {
+	o := a.opts
+	paths := strings.Split(o.csvPaths, ",")
+	if len(paths) != 1 || paths[0] == stdinPath || compressionExt(paths[0]) != "" || isParquet(paths[0]) {
+		fatal(fmt.Errorf("watch follows a single uncompressed CSV file, got %s", o.csvPaths))
+	}
+
+	w := &watcher{
+		a:       a,
+		out:     out,
+		alerted: make(map[string]uint64),
+	}
+	last := a.records[len(a.records)-1].Height
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		w.alerted[complexity.DimensionStrings[d]] = last
+	}
+	w.alerted[totalGasName] = last
+	if o.alerts != nil {
+		// fee state carries over from the dataset, alerts only fire for appended blocks
+		w.sim, w.alerts = complexity.NewFeeSimulator(o.feeCfg(), o.denom.unit), newAlerter(o)
+		for _, r := range a.records {
+			if _, err := w.sim.Next(r); err != nil {
+				fatal(err)
+			}
+		}
+	}
+
+	slog.Info("watching input", "path", paths[0], "height", last, "poll", o.pollInterval, "window", o.watchWindow)
+	err := followCsvFile(ctx, paths[0], o.cols, o.onError, last, o.pollInterval, func(batch []complexity.Record) error {
+		return w.extend(ctx, batch)
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fatal(err)
+	}
+}
+
+// extend appends to the analysis the blocks of [batch] following its last block,
+// then refreshes targets, peaks and plots over the watch window
+func (w *watcher) extend(ctx context.Context, batch []complexity.Record) error {
+	a := w.a
+	last := a.records[len(a.records)-1]
+	fresh := make([]complexity.Record, 0, len(batch))
+	for _, r := range batch {
+		if r.Height <= last.Height {
+			slog.Warn("skipping out of order block", "height", r.Height, "latest", last.Height)
+			continue
+		}
+		if r.Time < last.Time {
+			slog.Warn("skipping block going back in time", "height", r.Height, "time", r.Time, "latest", last.Time)
+			continue
+		}
+		fresh = append(fresh, r)
+		last = r
+	}
+	if len(fresh) == 0 {
+		return nil
+	}
+	a.records = append(a.records, fresh...)
+
+	var (
+		o         = a.opts
+		window    = w.window()
+		feeCfg    = o.feeCfg()
+		gasTarget = uint64(feeCfg.TargetGasPerSecond)
+	)
+	totalGasPeaks, err := complexity.FindTotalGasPeaks(ctx, o.detector, window, feeCfg.Weights, gasTarget, math.MaxInt, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		return err
+	}
+	if err := w.checkAlerts(ctx, fresh, totalGasPeaks); err != nil {
+		return err
+	}
+
+	derived := complexity.Derive(window)
+	_, rates, err := complexity.TargetComplexityRate(derived, o.chain.minHeight, o.quantile, o.blockDelayQuantile)
+	if err != nil {
+		slog.Debug("skipping refresh", "height", last.Height, "err", err)
+		return nil
+	}
+	slog.Info("extended analysis", "blocks", len(fresh), "height", last.Height, "window_blocks", len(window), "target_rates", fmt.Sprintf("%v", rates))
+
+	var (
+		maxCompl = complexity.MaxComplexity(window)
+		gas      = complexity.PullGasFromRecords(window, feeCfg.Weights)
+		firstNew = fresh[0].Height
+	)
+	dimensionPeaks, err := complexity.FindAllDimensionPeaks(ctx, o.detector, derived, maxCompl, rates, math.MaxInt, o.smoothWindow, o.thresholdWindow, o.peakOrder)
+	if err != nil {
+		return err
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		w.alertNewPeak(complexity.DimensionStrings[d], dimensionPeaks[d], firstNew)
+	}
+	w.alertNewPeak(totalGasName, totalGasPeaks, firstNew)
+
+	if o.noPlot || len(window) < 2 {
+		return nil
+	}
+	x := buildXAxis(derived.HeightsAndTimes, o.xAxisMode, o.maxXGap)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		target := complexity.TargetComplexityTrace(window, maxCompl[d], rates[d], o.sameTime)
+		a.tolerate(printGasImage(w.out, x, derived.Traces[d], target, peakMarks(x, window, derived.Traces[d], dimensionPeaks[d]), complexity.DimensionStrings[d]))
+	}
+	totalTarget := complexity.TargetComplexityTrace(window, slices.Max(gas), gasTarget, o.sameTime)
+	a.tolerate(printGasImage(w.out, x, gas, totalTarget, peakMarks(x, window, gas, totalGasPeaks), totalGasName))
+	return nil
+}
+
+// checkAlerts replays [fresh] blocks through the fee simulator and checks alert rules
+// against them, attaching the peak of [totalGasPeaks] each block belongs to, if any
+func (w *watcher) checkAlerts(ctx context.Context, fresh []complexity.Record, totalGasPeaks []complexity.Peak) error {
+	if w.alerts == nil {
+		return nil
+	}
+	for _, r := range fresh {
+		fee, err := w.sim.Next(r)
+		if err != nil {
+			return err
+		}
+		var peak *complexity.Peak
+		for i := range totalGasPeaks {
+			if totalGasPeaks[i].ContainsHeight(r.Height) {
+				peak = &totalGasPeaks[i]
+				break
+			}
+		}
+		w.alerts.observe(ctx, r, fee, peak)
+	}
+	return nil
+}
+
+// window returns the records within the watch window of the latest one
+func (w *watcher) window() []complexity.Record {
+	var (
+		records = w.a.records
+		latest  = records[len(records)-1].Time
+		span    = uint64(w.a.opts.watchWindow / time.Second)
+		from    = uint64(0)
+	)
+	if latest > span {
+		from = latest - span
+	}
+	start, _ := slices.BinarySearchFunc(records, from, func(r complexity.Record, t uint64) int {
+		return cmp.Compare(r.Time, t)
+	})
+	return records[start:]
+}
+
+// alertNewPeak alerts about the latest of [peaks] of trace [name] if it started
+// at or after height [firstNew] and was not alerted about yet
+func (w *watcher) alertNewPeak(name string, peaks []complexity.Peak, firstNew uint64) {
+	if len(peaks) == 0 {
+		return
+	}
+	latest := slices.MaxFunc(peaks, func(lhs, rhs complexity.Peak) int {
+		return cmp.Compare(lhs.StartHeight, rhs.StartHeight)
+	})
+	if latest.StartHeight < firstNew || latest.StartHeight <= w.alerted[name] {
+		return
+	}
+	w.alerted[name] = latest.StartHeight
+	slog.Warn("peak started", "trace", name, "height", latest.StartHeight, "blocks", latest.BlocksCount, "complexity", latest.CumulatedComplexity)
+	fmt.Fprintf(w.a.stdout, "new %s peak: %s\n", name, w.a.formatPeak(latest))
+}
+
+// followCsvFile parses [filePath] as forEachRecord does, then keeps polling it every
+// [poll] for appended rows, until [ctx] is done. Records up to height [after] are skipped,
+// the others are handed to [fn] in batches, once all rows available are parsed.
+// A row is parsed once its line is complete, so that rows being written are never read partially.
+func followCsvFile(ctx context.Context, filePath string, cols columns, onError string, after uint64, poll time.Duration, fn func([]complexity.Record) error) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("unable to read input file %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	var (
+		in       = bufio.NewReader(f)
+		ticker   = time.NewTicker(poll)
+		line     []byte
+		batch    []complexity.Record
+		rowsSeen int
+	)
+	defer ticker.Stop()
+
+	for {
+		if rowsSeen%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		chunk, err := in.ReadBytes('\n')
+		line = append(line, chunk...)
+		if errors.Is(err, io.EOF) {
+			if len(batch) > 0 {
+				if err := fn(batch); err != nil {
+					return err
+				}
+				batch = batch[:0]
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-ticker.C:
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed reading %s: %w", filePath, err)
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			line = line[:0]
+			continue
+		}
+
+		row, err := csv.NewReader(bytes.NewReader(line)).Read()
+		line = line[:0]
+		if err != nil {
+			return fmt.Errorf("unable to parse file as CSV for %s: %w", filePath, err)
+		}
+		ri := rowsSeen
+		rowsSeen++
+		entry, err := parseRecord(row, ri, cols)
+		if err != nil {
+			if ri == 0 && isHeaderRow(row, cols) {
+				continue
+			}
+			if onError == onErrorAbort {
+				return err
+			}
+			handleError(onError, fmt.Errorf("skipping row of %s: %w", filePath, err))
+			continue
+		}
+		if entry.Height > after {
+			batch = append(batch, entry)
+		}
+	}
+}
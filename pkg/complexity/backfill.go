@@ -0,0 +1,33 @@
+package complexity
+
+// BackfillTimes sets the time of records below [minHeight], whose timestamps are not
+// part of the block, from [times], keyed by height. Assumes [records] are sorted by height.
+// It returns the number of backfilled records and the lowest height from which on all
+// records carry a reliable time, i.e. [minHeight] lowered through the contiguous run
+// of backfilled records right below it.
+func BackfillTimes(records []Record, times map[uint64]uint64, minHeight uint64) (int, uint64) {
+	var (
+		backfilled = 0
+		reliable   = minHeight
+		contiguous = true
+	)
+	for i := len(records) - 1; i >= 0; i-- {
+		r := &records[i]
+		if r.Height >= minHeight {
+			continue
+		}
+		t, ok := times[r.Height]
+		if !ok {
+			contiguous = false
+			continue
+		}
+		r.Time = t
+		backfilled++
+		if contiguous && r.Height+1 == reliable {
+			reliable = r.Height
+		} else {
+			contiguous = false
+		}
+	}
+	return backfilled, reliable
+}
@@ -0,0 +1,76 @@
+package complexity
+
+import (
+	"slices"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// BlockCapacity is the share of its gas budget a block consumes
+type BlockCapacity struct {
+	BlkHeightTime
+	Gas    uint64
+	Budget uint64
+
+	// Ratio is Gas over Budget, blocks above 1 are over capacity
+	Ratio float64
+}
+
+// CapacityUtilization summarizes how much of their gas budget blocks consume
+type CapacityUtilization struct {
+	Blocks []BlockCapacity
+
+	Mean float64
+	Max  float64
+
+	// Quantiles holds the ratio at each of the requested quantiles, in order
+	Quantiles []float64
+
+	// OverCapacity counts blocks consuming more than their budget
+	OverCapacity int
+}
+
+// ComputeCapacityUtilization compares the weighted gas of each block with the budget
+// [feeCfg] grants it, i.e. MaxGasPerSecond * elapsed time since its parent, with elapsed
+// time floored at one second as in SimulateThrottling. First block has no parent and is
+// left out. Utilization ratios are summarized at each of [quantiles].
+func ComputeCapacityUtilization(records []Record, feeCfg commonfee.Config, quantiles []float64) CapacityUtilization {
+	res := CapacityUtilization{
+		Blocks:    make([]BlockCapacity, 0, max(0, len(records)-1)),
+		Quantiles: make([]float64, len(quantiles)),
+	}
+	if len(records) < 2 {
+		return res
+	}
+
+	ratios := make([]float64, 0, len(records)-1)
+	sum := float64(0)
+	for i := 1; i < len(records); i++ {
+		var (
+			r       = records[i]
+			elapsed = max(1, TimeDelta(records[i-1].Time, r.Time))
+			budget  = uint64(feeCfg.MaxGasPerSecond) * elapsed
+			gas     = WeightedGas(r, feeCfg.Weights)
+			ratio   = float64(gas) / float64(budget)
+		)
+		if gas > budget {
+			res.OverCapacity++
+		}
+		res.Blocks = append(res.Blocks, BlockCapacity{
+			BlkHeightTime: r.BlkHeightTime,
+			Gas:           gas,
+			Budget:        budget,
+			Ratio:         ratio,
+		})
+		ratios = append(ratios, ratio)
+		sum += ratio
+	}
+
+	slices.Sort(ratios)
+	res.Mean = sum / float64(len(ratios))
+	res.Max = ratios[len(ratios)-1]
+	for i, q := range quantiles {
+		res.Quantiles[i] = ratios[quantileIndex(len(ratios), q)]
+	}
+	return res
+}
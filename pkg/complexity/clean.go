@@ -0,0 +1,127 @@
+package complexity
+
+import (
+	"fmt"
+	"slices"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// Cleaning modes tell what happens to implausible records, see [CleanRecords]
+const (
+	// CleanOff leaves records unchecked
+	CleanOff = "off"
+	// CleanReport flags implausible records, leaving them as they are
+	CleanReport = "report"
+	// CleanDrop removes implausible records
+	CleanDrop = "drop"
+	// CleanClamp brings times going backwards up to the previous time and absurd
+	// complexities down to their limit. Records with non increasing heights
+	// cannot be fixed, so they are dropped.
+	CleanClamp = "clamp"
+)
+
+var CleanModes = []string{CleanOff, CleanReport, CleanDrop, CleanClamp}
+
+// outlierQuantile is the quantile of each dimension complexities that,
+// scaled by the outlier factor, bounds plausible complexities
+const outlierQuantile = 0.999
+
+// Reasons records are flagged as implausible
+const (
+	OutlierHeight     = "height not increasing"
+	OutlierTime       = "time going backwards"
+	OutlierComplexity = "absurd complexity"
+)
+
+// Outlier is an implausible record found by CleanRecords
+type Outlier struct {
+	BlkHeightTime
+	Reason string `json:"reason"`
+	// Detail tells which values made the record implausible
+	Detail string `json:"detail"`
+}
+
+// OutlierLimits returns, for each dimension, the complexity above which records
+// are deemed absurd: [factor] times the dimension complexity at [outlierQuantile].
+// A factor of zero returns no limits.
+func OutlierLimits(records []Record, factor float64) (commonfee.Dimensions, bool) {
+	if factor == 0 || len(records) == 0 {
+		return commonfee.Dimensions{}, false
+	}
+	var res commonfee.Dimensions
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		values := PullComplexityFromRecords(records, d)
+		slices.Sort(values)
+		// limits are at least [factor], so that dimensions mostly at zero
+		// do not flag every non empty block
+		q := max(1, values[quantileIndex(len(values), outlierQuantile)])
+		res[d] = uint64(factor * float64(q))
+	}
+	return res, true
+}
+
+// CleanRecords checks [records] for heights not increasing, times going backwards
+// and complexities above [limits], as returned by OutlierLimits, if [hasLimits].
+// Records are compared with the last record kept, so that a single bad row does not
+// flag the following ones. Implausible records are handled as [mode] tells, see [CleanModes],
+// and returned along with the records kept. Assumes [mode] is one of [CleanModes].
+func CleanRecords(records []Record, mode string, limits commonfee.Dimensions, hasLimits bool) ([]Record, []Outlier) {
+	if mode == CleanOff {
+		return records, nil
+	}
+
+	var (
+		res      = make([]Record, 0, len(records))
+		outliers = make([]Outlier, 0)
+	)
+	for _, r := range records {
+		var last *Record
+		if len(res) > 0 {
+			last = &res[len(res)-1]
+		}
+
+		if last != nil && r.Height <= last.Height {
+			outliers = append(outliers, Outlier{
+				BlkHeightTime: r.BlkHeightTime,
+				Reason:        OutlierHeight,
+				Detail:        fmt.Sprintf("previous height %d", last.Height),
+			})
+			if mode != CleanReport {
+				continue
+			}
+		}
+
+		flagged := false
+		if last != nil && r.Time < last.Time {
+			outliers = append(outliers, Outlier{
+				BlkHeightTime: r.BlkHeightTime,
+				Reason:        OutlierTime,
+				Detail:        fmt.Sprintf("previous time %d", last.Time),
+			})
+			flagged = true
+			if mode == CleanClamp {
+				r.Time = last.Time
+			}
+		}
+		for d := commonfee.Dimension(0); hasLimits && d < commonfee.NumDimensions; d++ {
+			if r.Complexity[d] <= limits[d] {
+				continue
+			}
+			outliers = append(outliers, Outlier{
+				BlkHeightTime: r.BlkHeightTime,
+				Reason:        OutlierComplexity,
+				Detail:        fmt.Sprintf("%s %d above %d", DimensionStrings[d], r.Complexity[d], limits[d]),
+			})
+			flagged = true
+			if mode == CleanClamp {
+				r.Complexity[d] = limits[d]
+			}
+		}
+		if flagged && mode == CleanDrop {
+			continue
+		}
+		res = append(res, r)
+	}
+	return res, outliers
+}
@@ -0,0 +1,116 @@
+package complexity
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files under testdata with current results")
+
+// fixturePath holds a small dataset with two bursts, laid out as
+// id, height, time and the complexity of each dimension
+var fixturePath = filepath.Join("testdata", "complexities.csv")
+
+// loadFixture reads the records of [fixturePath]
+func loadFixture(tb testing.TB) []Record {
+	tb.Helper()
+
+	f, err := os.Open(fixturePath)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		tb.Fatalf("failed reading %s: %v", fixturePath, err)
+	}
+
+	res := make([]Record, 0, len(rows)-1)
+	for ri, row := range rows[1:] {
+		if len(row) != 3+commonfee.NumDimensions {
+			tb.Fatalf("unexpected line %d lenght: %d", ri+2, len(row))
+		}
+		var (
+			r      Record
+			values = make([]uint64, len(row)-1)
+		)
+		if r.ID, err = ids.FromString(row[0]); err != nil {
+			tb.Fatalf("failed parsing block ID, line %d: %v", ri+2, err)
+		}
+		for i, field := range row[1:] {
+			if values[i], err = strconv.ParseUint(field, 10, 64); err != nil {
+				tb.Fatalf("failed parsing line %d: %v", ri+2, err)
+			}
+		}
+		r.Height, r.Time = values[0], values[1]
+		copy(r.Complexity[:], values[2:])
+		res = append(res, r)
+	}
+	return res
+}
+
+// benchmarkBlocks is the size of benchmark datasets, about a week of P-chain blocks
+const benchmarkBlocks = 300_000
+
+// benchmarkRecords returns [n] synthetic records whose complexities are drawn from
+// the fixture dataset, with bursts as found on chain
+func benchmarkRecords(tb testing.TB, n int) []Record {
+	tb.Helper()
+
+	records := loadFixture(tb)
+	sampler, err := FitComplexities(records)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cfg := SyntheticConfig{
+		Blocks:           n,
+		MeanBlockDelay:   2,
+		BurstProbability: 0.01,
+		BurstBlocks:      20,
+		BurstFactor:      5,
+		Seed:             1,
+	}
+	for d := range cfg.Scale {
+		cfg.Scale[d] = 1
+	}
+	return GenerateRecords(sampler, cfg, records[0].BlkHeightTime)
+}
+
+// checkGolden compares [got], marshaled as JSON, with testdata/[name].golden.json.
+// Run tests with -update to rewrite the golden file once a change of results is intended.
+func checkGolden(t *testing.T, name string, got any) {
+	t.Helper()
+
+	data, err := json.MarshalIndent(got, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = append(data, '\n')
+
+	path := filepath.Join("testdata", name+".golden.json")
+	if *updateGolden {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed reading golden file, run with -update to create it: %v", err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("%s differs from %s, run with -update if the change is intended\ngot:\n%s", name, path, data)
+	}
+}
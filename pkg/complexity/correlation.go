@@ -0,0 +1,142 @@
+package complexity
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// CorrelationMatrix holds the correlation of each pair of dimensions,
+// indexed by dimension. The diagonal is 1 unless a dimension is constant,
+// in which case its correlations are NaN.
+type CorrelationMatrix [commonfee.NumDimensions][commonfee.NumDimensions]float64
+
+// DimensionCorrelations holds Pearson and Spearman correlations among dimensions
+// of per block complexities and of complexity rates
+type DimensionCorrelations struct {
+	Blocks int
+
+	ComplexityPearson  CorrelationMatrix
+	ComplexitySpearman CorrelationMatrix
+	RatePearson        CorrelationMatrix
+	RateSpearman       CorrelationMatrix
+}
+
+// CorrelationSamples returns, for each dimension, the per block complexities and
+// complexity rates of the blocks TargetComplexityRate accounts for, in height order,
+// so that values of a block are found at the same index across dimensions.
+// The first accounted block has no rate, so it is left out of both.
+func CorrelationSamples(derived Derived, minHeight uint64) ([commonfee.NumDimensions][]float64, [commonfee.NumDimensions][]float64, error) {
+	var complexities [commonfee.NumDimensions][]float64
+	_, rates, err := blockRates(derived, minHeight)
+	if err != nil {
+		return complexities, rates, err
+	}
+
+	for d := range complexities {
+		complexities[d] = make([]float64, 0, len(rates[d]))
+	}
+	first := true
+	for i, ht := range derived.HeightsAndTimes {
+		if ht.Height < minHeight || derived.isEmpty(i) {
+			continue
+		}
+		if first {
+			first = false
+			continue
+		}
+		for d := range complexities {
+			complexities[d] = append(complexities[d], float64(derived.Traces[d][i]))
+		}
+	}
+	return complexities, rates, nil
+}
+
+// CorrelateDimensions returns Pearson and Spearman correlations among dimensions
+// of the samples returned by CorrelationSamples
+func CorrelateDimensions(derived Derived, minHeight uint64) (DimensionCorrelations, error) {
+	complexities, rates, err := CorrelationSamples(derived, minHeight)
+	if err != nil {
+		return DimensionCorrelations{}, err
+	}
+	return DimensionCorrelations{
+		Blocks:             len(rates[0]),
+		ComplexityPearson:  correlationMatrix(complexities, Pearson),
+		ComplexitySpearman: correlationMatrix(complexities, Spearman),
+		RatePearson:        correlationMatrix(rates, Pearson),
+		RateSpearman:       correlationMatrix(rates, Spearman),
+	}, nil
+}
+
+func correlationMatrix(samples [commonfee.NumDimensions][]float64, correlate func(x, y []float64) float64) CorrelationMatrix {
+	var res CorrelationMatrix
+	for i := range samples {
+		for j := i; j < len(samples); j++ {
+			res[i][j] = correlate(samples[i], samples[j])
+			res[j][i] = res[i][j]
+		}
+	}
+	return res
+}
+
+// Pearson returns the linear correlation of [x] and [y], or NaN if either is constant.
+// Assumes [x] and [y] have the same length.
+func Pearson(x, y []float64) float64 {
+	if len(x) == 0 {
+		return math.NaN()
+	}
+	var meanX, meanY float64
+	for i := range x {
+		meanX += x[i]
+		meanY += y[i]
+	}
+	meanX /= float64(len(x))
+	meanY /= float64(len(y))
+
+	var cov, varX, varY float64
+	for i := range x {
+		dx, dy := x[i]-meanX, y[i]-meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return math.NaN()
+	}
+	return cov / math.Sqrt(varX*varY)
+}
+
+// Spearman returns the rank correlation of [x] and [y], i.e. the Pearson correlation
+// of their ranks, tied values sharing their average rank. It is NaN if either is constant.
+// Assumes [x] and [y] have the same length.
+func Spearman(x, y []float64) float64 {
+	return Pearson(ranks(x), ranks(y))
+}
+
+// ranks returns the rank of each of [values], from 1, tied values sharing their average rank
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(lhs, rhs int) int {
+		return cmp.Compare(values[lhs], values[rhs])
+	})
+
+	res := make([]float64, len(values))
+	for start := 0; start < len(order); {
+		end := start + 1
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			end++
+		}
+		// ranks start + 1 to end, both included, are shared
+		rank := float64(start+1+end) / 2
+		for _, i := range order[start:end] {
+			res[i] = rank
+		}
+		start = end
+	}
+	return res
+}
@@ -0,0 +1,44 @@
+package complexity
+
+import (
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// Derived holds the per block traces most analyses work on, pulled from
+// records in a single pass so that they are not recomputed by each analysis.
+type Derived struct {
+	IDs             []ids.ID
+	HeightsAndTimes []BlkHeightTime
+	Traces          [commonfee.NumDimensions][]uint64
+}
+
+// Derive pulls IDs, heights, times and per dimension complexities out of [records]
+func Derive(records []Record) Derived {
+	res := Derived{
+		IDs:             make([]ids.ID, len(records)),
+		HeightsAndTimes: make([]BlkHeightTime, len(records)),
+	}
+	for d := range res.Traces {
+		res.Traces[d] = make([]uint64, len(records))
+	}
+	for i, r := range records {
+		res.IDs[i] = r.ID
+		res.HeightsAndTimes[i] = r.BlkHeightTime
+		for d := range res.Traces {
+			res.Traces[d][i] = r.Complexity[d]
+		}
+	}
+	return res
+}
+
+// isEmpty tells whether block [i] has no complexity at all
+func (d Derived) isEmpty(i int) bool {
+	for _, trace := range d.Traces {
+		if trace[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package complexity
+
+import (
+	"context"
+	"testing"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+func TestDeriveMatchesPulledTraces(t *testing.T) {
+	var (
+		records = loadFixture(t)
+		derived = Derive(records)
+	)
+	for i, ht := range PullTimesHeightsFromRecords(records) {
+		if derived.HeightsAndTimes[i] != ht || derived.IDs[i] != records[i].ID {
+			t.Fatalf("block %d: unexpected height, time or ID", i)
+		}
+	}
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		for i, v := range PullComplexityFromRecords(records, d) {
+			if derived.Traces[d][i] != v {
+				t.Fatalf("%s, block %d: expected %d, got %d", DimensionStrings[d], i, v, derived.Traces[d][i])
+			}
+		}
+	}
+}
+
+// BenchmarkDerived compares deriving traces once for both target and peak analyses
+// with pulling them out of records for each analysis, as analyses used to do
+func BenchmarkDerived(b *testing.B) {
+	var (
+		records       = benchmarkRecords(b, benchmarkBlocks)
+		maxComplexity = MaxComplexity(records)
+	)
+	order, err := NewPeakOrder(SortByComplexity, SortDescending)
+	if err != nil {
+		b.Fatal(err)
+	}
+	analyze := func(b *testing.B, forTargets, forPeaks Derived) {
+		_, rates, err := TargetComplexityRate(forTargets, MinBanffHeight, 0.5, 0.5)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := FindAllDimensionPeaks(context.Background(), ThresholdDetector{}, forPeaks, maxComplexity, rates, 10, 1, 1, order); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	b.Run("shared", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			derived := Derive(records)
+			analyze(b, derived, derived)
+		}
+	})
+	b.Run("per_analysis", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			forPeaks := Derived{
+				IDs:             PullIDsFromRecords(records),
+				HeightsAndTimes: PullTimesHeightsFromRecords(records),
+			}
+			for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+				forPeaks.Traces[d] = PullComplexityFromRecords(records, d)
+			}
+			analyze(b, Derive(records), forPeaks)
+		}
+	})
+}
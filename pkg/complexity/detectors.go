@@ -0,0 +1,163 @@
+package complexity
+
+import (
+	"context"
+	"math"
+)
+
+const (
+	DetectThreshold  = "threshold"
+	DetectHysteresis = "hysteresis"
+	DetectZScore     = "zscore"
+)
+
+var DetectorModes = []string{DetectThreshold, DetectHysteresis, DetectZScore}
+
+// PeakDetector tells which blocks of a trace are part of a peak
+type PeakDetector interface {
+	// Detect returns, for each block of [trace], whether it is part of a peak.
+	// [thresholds] holds the target value of each block, as computed by FindPeaks.
+	// The first block is never part of a peak, since it has no parent to measure time from.
+	Detect(ctx context.Context, heightsAndTimes []BlkHeightTime, trace, thresholds []uint64) ([]bool, error)
+}
+
+// ThresholdDetector flags blocks whose trace value reaches their threshold
+type ThresholdDetector struct{}
+
+func (ThresholdDetector) Detect(ctx context.Context, _ []BlkHeightTime, trace, thresholds []uint64) ([]bool, error) {
+	res := make([]bool, len(trace))
+	for i := 1; i < len(trace); i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		res[i] = trace[i] >= thresholds[i]
+	}
+	return res, nil
+}
+
+// HysteresisDetector starts a peak once trace reaches Start times the threshold
+// and keeps it going until trace falls below Stop times the threshold,
+// so that traces oscillating around the threshold do not split peaks.
+// Stop is expected not to exceed Start.
+type HysteresisDetector struct {
+	Start float64
+	Stop  float64
+}
+
+func (h HysteresisDetector) Detect(ctx context.Context, _ []BlkHeightTime, trace, thresholds []uint64) ([]bool, error) {
+	res := make([]bool, len(trace))
+	for i := 1; i < len(trace); i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		level := h.Start
+		if res[i-1] {
+			level = h.Stop
+		}
+		res[i] = float64(trace[i]) >= level*float64(thresholds[i])
+	}
+	return res, nil
+}
+
+// ZScoreDetector flags blocks whose trace value is at least Z standard deviations
+// above the mean of the Window preceding blocks. Thresholds are not used, so that
+// peaks are relative to recent load rather than to target rates.
+type ZScoreDetector struct {
+	Z      float64
+	Window int
+}
+
+func (z ZScoreDetector) Detect(ctx context.Context, _ []BlkHeightTime, trace, _ []uint64) ([]bool, error) {
+	var (
+		res      = make([]bool, len(trace))
+		sum, sq  float64
+		inWindow = 0
+	)
+	for i := 1; i < len(trace); i++ {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		// the window holds blocks [i-Window, i)
+		prev := float64(trace[i-1])
+		sum, sq = sum+prev, sq+prev*prev
+		inWindow++
+		if inWindow > z.Window {
+			out := float64(trace[i-1-z.Window])
+			sum, sq = sum-out, sq-out*out
+			inWindow--
+		}
+
+		var (
+			n        = float64(inWindow)
+			mean     = sum / n
+			variance = max(0, sq/n-mean*mean)
+			std      = math.Sqrt(variance)
+		)
+		res[i] = std > 0 && (float64(trace[i])-mean)/std >= z.Z
+	}
+	return res, nil
+}
+
+// SmoothedDetector applies an exponential moving average with weight Alpha
+// on the latest value to the trace, then detects peaks with Detector.
+// Peaks cumulated complexity is still computed on the unsmoothed trace.
+type SmoothedDetector struct {
+	Alpha    float64
+	Detector PeakDetector
+}
+
+func (s SmoothedDetector) Detect(ctx context.Context, heightsAndTimes []BlkHeightTime, trace, thresholds []uint64) ([]bool, error) {
+	var (
+		smoothed = make([]uint64, len(trace))
+		ema      = float64(0)
+	)
+	for i, v := range trace {
+		if i == 0 {
+			ema = float64(v)
+		} else {
+			ema = s.Alpha*float64(v) + (1-s.Alpha)*ema
+		}
+		smoothed[i] = uint64(math.Round(ema))
+	}
+	return s.Detector.Detect(ctx, heightsAndTimes, smoothed, thresholds)
+}
+
+// MinDurationDetector drops the peaks found by Detector which last less than
+// MinDuration seconds, measured up to the first block past the peak
+type MinDurationDetector struct {
+	MinDuration uint64
+	Detector    PeakDetector
+}
+
+func (m MinDurationDetector) Detect(ctx context.Context, heightsAndTimes []BlkHeightTime, trace, thresholds []uint64) ([]bool, error) {
+	res, err := m.Detector.Detect(ctx, heightsAndTimes, trace, thresholds)
+	if err != nil {
+		return nil, err
+	}
+	for start := 0; start < len(res); {
+		if !res[start] {
+			start++
+			continue
+		}
+		end := start
+		for end < len(res) && res[end] {
+			end++
+		}
+		last := end
+		if last == len(res) {
+			last-- // peak running up to the last block
+		}
+		if TimeDelta(heightsAndTimes[start].Time, heightsAndTimes[last].Time) < m.MinDuration {
+			clear(res[start:end])
+		}
+		start = end
+	}
+	return res, nil
+}
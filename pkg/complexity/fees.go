@@ -0,0 +1,294 @@
+package complexity
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	safemath "github.com/ava-labs/avalanchego/utils/math"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// FeeData is the state of the fee mechanism once a block is accepted
+type FeeData struct {
+	BlkHeightTime
+	GasPrice  commonfee.GasPrice `json:"gas_price"`
+	ExcessGas commonfee.Gas      `json:"excess_gas"` // excess gas once the block is accepted
+	Fee       float64            `json:"fee"`        // in the fee unit passed to CalculateFeeData
+}
+
+// CalculateFeeData replays [records] through the dynamic fees algorithm.
+// Fees are expressed in [feeUnit], e.g. units.Avax.
+func CalculateFeeData(ctx context.Context, records []Record, feeCfg commonfee.Config, feeUnit uint64) ([]FeeData, error) {
+	return CalculateFeeDataFrom(ctx, records, feeCfg, feeUnit, 0)
+}
+
+// CalculateFeeDataFrom works as CalculateFeeData, starting from [excessGas]
+// rather than from no excess gas, see NewFeeSimulatorFrom
+func CalculateFeeDataFrom(ctx context.Context, records []Record, feeCfg commonfee.Config, feeUnit uint64, excessGas commonfee.Gas) ([]FeeData, error) {
+	var (
+		res = make([]FeeData, 0, len(records))
+		sim = NewFeeSimulatorFrom(feeCfg, feeUnit, excessGas)
+	)
+	for i, r := range records {
+		if i%ctxCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := sim.Next(r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, data)
+	}
+	return res, nil
+}
+
+// FeeSimulator replays blocks through the dynamic fees algorithm one at a time,
+// so that fees can be tracked as blocks are produced
+type FeeSimulator struct {
+	feeCfg  commonfee.Config
+	feeUnit uint64
+
+	started       bool
+	parentBlkTime uint64
+	excessGas     commonfee.Gas
+}
+
+// NewFeeSimulator returns a simulator with no excess gas.
+// Fees are expressed in [feeUnit], e.g. units.Avax.
+func NewFeeSimulator(feeCfg commonfee.Config, feeUnit uint64) *FeeSimulator {
+	return NewFeeSimulatorFrom(feeCfg, feeUnit, 0)
+}
+
+// NewFeeSimulatorFrom returns a simulator with [excessGas], as if the first
+// record was accepted while the chain is already loaded, e.g. amid a surge
+func NewFeeSimulatorFrom(feeCfg commonfee.Config, feeUnit uint64, excessGas commonfee.Gas) *FeeSimulator {
+	return &FeeSimulator{
+		feeCfg:    feeCfg,
+		feeUnit:   feeUnit,
+		excessGas: excessGas,
+	}
+}
+
+// Next accepts [r], which is expected to follow the previously accepted
+// record, and returns its fee data. The first record has no parent, so that
+// it pays the min gas price unless the simulator starts with some excess gas.
+// Blocks are replayed as accepted on chain, so that gas capacity does not bound them.
+func (s *FeeSimulator) Next(r Record) (FeeData, error) {
+	if !s.started {
+		// no time elapses before the first record, so that initial excess gas does not drop
+		s.started, s.parentBlkTime = true, r.Time
+	}
+
+	state := commonfee.State{
+		Capacity: math.MaxUint64,
+		Excess:   s.excessGas,
+	}
+	state = state.AdvanceTime(
+		math.MaxUint64,
+		s.feeCfg.MaxGasPerSecond,
+		s.feeCfg.TargetGasPerSecond,
+		TimeDelta(s.parentBlkTime, r.Time),
+	)
+	gasPrice := s.feeCfg.MinGasPrice.MulExp(state.Excess, s.feeCfg.ExcessConversionConstant)
+
+	gas, err := r.Complexity.ToGas(s.feeCfg.Weights)
+	if err != nil {
+		return FeeData{}, fmt.Errorf("failed cumulating gas, height %d: %w", r.Height, err)
+	}
+	fee, err := safemath.Mul(uint64(gas), uint64(gasPrice))
+	if err != nil {
+		return FeeData{}, fmt.Errorf("failed computing fee from gas prices, height %d: %w", r.Height, err)
+	}
+	if state, err = state.ConsumeGas(gas); err != nil {
+		return FeeData{}, fmt.Errorf("failed consuming gas, height %d: %w", r.Height, err)
+	}
+
+	s.parentBlkTime, s.excessGas = r.Time, state.Excess
+	return FeeData{
+		BlkHeightTime: r.BlkHeightTime,
+		GasPrice:      gasPrice,
+		ExcessGas:     state.Excess,
+		Fee:           float64(fee) / float64(s.feeUnit),
+	}, nil
+}
+
+// PullFees returns the fees of blocks with height within [low, up]
+func PullFees(allFeeRates []FeeData, low, up uint64) []float64 {
+	res := make([]float64, 0, min(len(allFeeRates), int(up-low)))
+	for _, data := range allFeeRates {
+		if data.Height < low || data.Height > up {
+			continue
+		}
+		res = append(res, data.Fee)
+	}
+	return res
+}
+
+// PullGasPrices returns the gas price trace, the state variable of the fee
+// mechanism, independently of how much gas each block consumed
+func PullGasPrices(allFeeRates []FeeData) []uint64 {
+	res := make([]uint64, 0, len(allFeeRates))
+	for _, data := range allFeeRates {
+		res = append(res, uint64(data.GasPrice))
+	}
+	return res
+}
+
+// PullExcessGas returns the excess gas trace
+func PullExcessGas(allFeeRates []FeeData) []uint64 {
+	res := make([]uint64, 0, len(allFeeRates))
+	for _, data := range allFeeRates {
+		res = append(res, uint64(data.ExcessGas))
+	}
+	return res
+}
+
+// TotalFees returns the sum of [fees] and their mean per block.
+// Both are zero if [fees] is empty.
+func TotalFees(fees []float64) (float64, float64) {
+	if len(fees) == 0 {
+		return 0, 0
+	}
+
+	total := float64(0)
+	for _, f := range fees {
+		total += f
+	}
+	return total, total / float64(len(fees))
+}
+
+// FeeSummary condenses a fee trace into the figures used to compare fee configs
+type FeeSummary struct {
+	MaxFee    float64
+	MedianFee float64
+
+	// TimeAboveThreshold is the time, in seconds, spent with gas price above the threshold
+	TimeAboveThreshold uint64
+}
+
+// SummarizeFees returns max and median fee of [fees], along with the time spent
+// with gas price above [priceThreshold]. Each block accounts for the time elapsed
+// since its parent, so the first block accounts for no time.
+func SummarizeFees(fees []FeeData, priceThreshold commonfee.GasPrice) FeeSummary {
+	res := FeeSummary{}
+	if len(fees) == 0 {
+		return res
+	}
+
+	values := make([]float64, 0, len(fees))
+	for i, f := range fees {
+		values = append(values, f.Fee)
+		if i > 0 && f.GasPrice > priceThreshold {
+			res.TimeAboveThreshold += TimeDelta(fees[i-1].Time, f.Time)
+		}
+	}
+	slices.Sort(values)
+	res.MaxFee = values[len(values)-1]
+	res.MedianFee = values[quantileIndex(len(values), 0.5)]
+	return res
+}
+
+// PeakResponse tells how a fee config reacted to a gas peak
+type PeakResponse struct {
+	// MaxFee is the highest fee paid by blocks within the peak
+	MaxFee float64
+
+	// RecoveryTime is the time, in seconds, elapsed from the last block of the peak
+	// to the first block paying the min gas price again. Recovered is false if
+	// gas price never goes back to its minimum, in which case RecoveryTime is
+	// measured up to the last block of the trace.
+	RecoveryTime uint64
+	Recovered    bool
+}
+
+// RespondToPeak returns how [fees] reacted to [peak], assuming they
+// are sorted by height
+func RespondToPeak(fees []FeeData, peak Peak, minGasPrice commonfee.GasPrice) PeakResponse {
+	var (
+		res        = PeakResponse{}
+		endIdx     = -1
+		lastHeight = peak.StartHeight + uint64(peak.BlocksCount) - 1
+	)
+	for i, f := range fees {
+		if f.Height < peak.StartHeight {
+			continue
+		}
+		if f.Height > lastHeight {
+			break
+		}
+		res.MaxFee = max(res.MaxFee, f.Fee)
+		endIdx = i
+	}
+	if endIdx < 0 {
+		return res
+	}
+
+	end := fees[endIdx]
+	for _, f := range fees[endIdx:] {
+		if f.GasPrice <= minGasPrice {
+			res.RecoveryTime = TimeDelta(end.Time, f.Time)
+			res.Recovered = true
+			return res
+		}
+	}
+	res.RecoveryTime = TimeDelta(end.Time, fees[len(fees)-1].Time)
+	return res
+}
+
+// ControllerResponse tells how a fee config reacted to an idealized input, see GenerateWaveform
+type ControllerResponse struct {
+	MaxGasPrice  commonfee.GasPrice
+	MaxExcessGas commonfee.Gas
+
+	// RiseTime is the time, in seconds, elapsed from the input onset to the first block
+	// whose gas price reaches e times the min gas price, i.e. the time constant of the
+	// exponential price rise. Risen is false if gas price never gets there.
+	RiseTime uint64
+	Risen    bool
+
+	// RecoveryTime is measured from the last excited block, as PeakResponse does
+	RecoveryTime uint64
+	Recovered    bool
+}
+
+// RespondToInput returns how [fees] reacted to an input exciting blocks
+// from [onset] to [last], assuming they are sorted by height
+func RespondToInput(fees []FeeData, onset, last BlkHeightTime, minGasPrice commonfee.GasPrice) ControllerResponse {
+	var (
+		res    = ControllerResponse{}
+		riseAt = commonfee.GasPrice(math.Ceil(math.E * float64(minGasPrice)))
+	)
+	for _, f := range fees {
+		res.MaxGasPrice = max(res.MaxGasPrice, f.GasPrice)
+		res.MaxExcessGas = max(res.MaxExcessGas, f.ExcessGas)
+		if !res.Risen && f.Height >= onset.Height && f.GasPrice >= riseAt {
+			res.RiseTime = TimeDelta(onset.Time, f.Time)
+			res.Risen = true
+		}
+	}
+
+	input := Peak{StartHeight: onset.Height, BlocksCount: int(last.Height - onset.Height + 1)}
+	recovery := RespondToPeak(fees, input, minGasPrice)
+	res.RecoveryTime, res.Recovered = recovery.RecoveryTime, recovery.Recovered
+	return res
+}
+
+// PeakMidpointExcess returns the excess gas once the middle block of [peak] is accepted,
+// as found in [fees], along with its height. It returns false if [fees] does not cover that block.
+func PeakMidpointExcess(fees []FeeData, peak Peak) (commonfee.Gas, uint64, bool) {
+	height := peak.StartHeight + uint64(peak.BlocksCount/2)
+	idx, found := slices.BinarySearchFunc(fees, height, func(f FeeData, h uint64) int {
+		return cmp.Compare(f.Height, h)
+	})
+	if !found {
+		return 0, height, false
+	}
+	return fees[idx].ExcessGas, height, true
+}
@@ -0,0 +1,38 @@
+package complexity
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/utils/units"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+func TestCalculateFeeDataGolden(t *testing.T) {
+	var (
+		records = loadFixture(t)
+		weights commonfee.Dimensions
+	)
+	for d := range weights {
+		weights[d] = 1
+	}
+
+	// quiet fixture blocks stay below the gas target rate while bursts go well
+	// above it, so that gas price rises during bursts and drops back after them
+	feeCfg := commonfee.Config{
+		MinGasPrice:              commonfee.GasPrice(10 * units.NanoAvax),
+		ExcessConversionConstant: commonfee.Gas(20_000),
+		TargetGasPerSecond:       commonfee.Gas(1_000),
+		Weights:                  weights,
+		MaxGasPerSecond:          commonfee.Gas(1_000_000),
+	}
+	fees, err := CalculateFeeData(context.Background(), records, feeCfg, units.NanoAvax)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fees) != len(records) {
+		t.Fatalf("expected %d fees, got %d", len(records), len(fees))
+	}
+	checkGolden(t, "fees", fees)
+}
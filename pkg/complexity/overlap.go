@@ -0,0 +1,54 @@
+package complexity
+
+import commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+
+// DimensionOverlap tells how much a dimension exceeded its target
+// while a peak of another dimension was ongoing
+type DimensionOverlap struct {
+	// BlocksAboveTarget counts the peak blocks whose complexity exceeds the target
+	// granted for the time elapsed since their parent, i.e. rate * max(1, elapsed)
+	BlocksAboveTarget int `json:"blocks_above_target"`
+
+	// ExcessComplexity sums the complexity above target of those blocks
+	ExcessComplexity uint64 `json:"excess_complexity"`
+
+	// MaxUtilization is the highest complexity over target percentage within the peak
+	MaxUtilization float64 `json:"max_utilization"`
+}
+
+// PeakOverlaps returns, for each dimension, how much it exceeded [targetRate]
+// over the blocks of [peak]. The dimension [peak] was detected on is included,
+// so that its own figures can be compared with the others.
+func PeakOverlaps(derived Derived, peak Peak, targetRate commonfee.Dimensions) [commonfee.NumDimensions]DimensionOverlap {
+	var (
+		res        [commonfee.NumDimensions]DimensionOverlap
+		lastHeight = peak.StartHeight + uint64(peak.BlocksCount) - 1
+	)
+	for i, ht := range derived.HeightsAndTimes {
+		if ht.Height < peak.StartHeight {
+			continue
+		}
+		if ht.Height > lastHeight {
+			break
+		}
+
+		elapsed := uint64(1)
+		if i > 0 {
+			elapsed = max(1, TimeDelta(derived.HeightsAndTimes[i-1].Time, ht.Time))
+		}
+		for d := range res {
+			var (
+				consumed = derived.Traces[d][i]
+				target   = targetRate[d] * elapsed
+			)
+			if target != 0 {
+				res[d].MaxUtilization = max(res[d].MaxUtilization, 100*float64(consumed)/float64(target))
+			}
+			if consumed > target {
+				res[d].BlocksAboveTarget++
+				res[d].ExcessComplexity += consumed - target
+			}
+		}
+	}
+	return res
+}
@@ -0,0 +1,309 @@
+package complexity
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var (
+	errUnevenTrace = errors.New("time and trace have different length")
+	errUnevenIDs   = errors.New("time and block IDs have different length")
+)
+
+// Peak is a run of consecutive blocks consuming at least their target complexity
+type Peak struct {
+	LowTimestamp uint64 `json:"start_time"`
+	UpTimestamp  uint64 `json:"end_time"`
+
+	CumulatedComplexity uint64 `json:"cumulated_complexity"`
+	StartHeight         uint64 `json:"start_height"`
+	BlocksCount         int    `json:"peak_width"`
+	ElapsedTime         uint64 `json:"peak_duration"`
+
+	// BlockIDs lists the blocks making up the peak, in height order
+	BlockIDs []ids.ID `json:"block_ids"`
+}
+
+// Power returns how concentrated in time the peak is, i.e. its cumulated
+// complexity over its duration. Peaks lasting no time count as lasting one
+// second, as FindPeaks floors durations.
+func (p Peak) Power() float64 {
+	return float64(p.CumulatedComplexity) / float64(max(1, p.ElapsedTime))
+}
+
+// ContainsHeight returns whether the block at [height] is part of the peak
+func (p Peak) ContainsHeight(height uint64) bool {
+	return height >= p.StartHeight && height < p.StartHeight+uint64(p.BlocksCount)
+}
+
+// ContainsTime returns whether [t] falls within the peak, both ends included
+func (p Peak) ContainsTime(t uint64) bool {
+	return t >= p.LowTimestamp && t <= p.UpTimestamp
+}
+
+// Peak sort keys, see [PeakOrder]
+const (
+	SortByComplexity = "complexity"
+	SortByPower      = "power"
+	SortByDuration   = "duration"
+	SortByBlocks     = "blocks"
+)
+
+var SortModes = []string{SortByComplexity, SortByPower, SortByDuration, SortByBlocks}
+
+// Peak sort directions, see [PeakOrder]
+const (
+	SortDescending = "desc"
+	SortAscending  = "asc"
+)
+
+var SortOrders = []string{SortDescending, SortAscending}
+
+// tieBreaks are the keys breaking ties of a single sort key: cumulated complexity
+// ties are broken by power, other ties by cumulated complexity
+var tieBreaks = map[string]string{
+	SortByComplexity: SortByPower,
+	SortByPower:      SortByComplexity,
+	SortByDuration:   SortByComplexity,
+	SortByBlocks:     SortByComplexity,
+}
+
+// PeakOrder ranks peaks by [Keys], each one breaking ties of the previous ones.
+// The top peak has the highest values, or the lowest ones if [Ascending] is set,
+// e.g. the shortest peaks rank first when sorting by ascending duration.
+type PeakOrder struct {
+	Keys      []string
+	Ascending bool
+}
+
+// NewPeakOrder parses comma separated sort [keys], see [SortModes], and sort [direction],
+// see [SortOrders]. A single key gets its default tie break, see [tieBreaks].
+func NewPeakOrder(keys, direction string) (PeakOrder, error) {
+	if !slices.Contains(SortOrders, direction) {
+		return PeakOrder{}, fmt.Errorf("unsupported sort order %q, supported values are %v", direction, SortOrders)
+	}
+	res := PeakOrder{Ascending: direction == SortAscending}
+	for _, k := range strings.Split(keys, ",") {
+		k = strings.TrimSpace(k)
+		if !slices.Contains(SortModes, k) {
+			return PeakOrder{}, fmt.Errorf("unsupported sort key %q, supported values are %v", k, SortModes)
+		}
+		if slices.Contains(res.Keys, k) {
+			return PeakOrder{}, fmt.Errorf("sort key %q repeated", k)
+		}
+		res.Keys = append(res.Keys, k)
+	}
+	if len(res.Keys) == 1 {
+		res.Keys = append(res.Keys, tieBreaks[res.Keys[0]])
+	}
+	return res, nil
+}
+
+// compare compares [lhs] and [rhs] along [o] keys, in increasing rank
+func (o PeakOrder) compare(lhs, rhs Peak) int {
+	for _, k := range o.Keys {
+		var c int
+		switch k {
+		case SortByPower:
+			c = cmp.Compare(lhs.Power(), rhs.Power())
+		case SortByDuration:
+			c = cmp.Compare(lhs.ElapsedTime, rhs.ElapsedTime)
+		case SortByBlocks:
+			c = cmp.Compare(lhs.BlocksCount, rhs.BlocksCount)
+		default:
+			c = cmp.Compare(lhs.CumulatedComplexity, rhs.CumulatedComplexity)
+		}
+		if c == 0 {
+			continue
+		}
+		if o.Ascending {
+			return -c
+		}
+		return c
+	}
+	return 0
+}
+
+// returns for each dimension, the start and stop indexes of each peaks
+// sorted according to [order], see FindPeaks.
+// Dimensions are independent, so they are processed concurrently.
+// Traces are smoothed with a moving average over [smoothWindow] blocks before
+// detection; a window of 1 leaves them unchanged.
+// Thresholds are computed over [thresholdWindow] blocks, see FindPeaks.
+func FindAllDimensionPeaks(
+	ctx context.Context,
+	detector PeakDetector,
+	derived Derived,
+	maxComplexities, medianComplexityRate commonfee.Dimensions,
+	peaksCount int,
+	smoothWindow int,
+	thresholdWindow int,
+	order PeakOrder,
+) ([][]Peak, error) {
+	var (
+		res  = make([][]Peak, commonfee.NumDimensions)
+		errs = make([]error, commonfee.NumDimensions)
+		wg   sync.WaitGroup
+	)
+
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			trace := MovingAverage(derived.Traces[d], smoothWindow)
+			intervals, err := FindPeaks(ctx, detector, derived.HeightsAndTimes, derived.IDs, trace, maxComplexities[d], medianComplexityRate[d], thresholdWindow, order)
+			if err != nil {
+				errs[d] = err
+				return
+			}
+			res[d] = intervals[max(0, len(intervals)-peaksCount):]
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// FindTotalGasPeaks finds the top peaks of the weighted gas trace, using [targetRate]
+// as threshold rate and the historical max gas as cap.
+// Peaks are sorted and thresholds computed as in FindPeaks, smoothing works as in FindAllDimensionPeaks.
+func FindTotalGasPeaks(
+	ctx context.Context,
+	detector PeakDetector,
+	records []Record,
+	weights commonfee.Dimensions,
+	targetRate uint64,
+	peaksCount int,
+	smoothWindow int,
+	thresholdWindow int,
+	order PeakOrder,
+) ([]Peak, error) {
+	var (
+		heightsAndTimes = PullTimesHeightsFromRecords(records)
+		blkIDs          = PullIDsFromRecords(records)
+		gas             = MovingAverage(PullGasFromRecords(records, weights), smoothWindow)
+	)
+	if len(gas) == 0 {
+		return nil, nil
+	}
+
+	peaks, err := FindPeaks(ctx, detector, heightsAndTimes, blkIDs, gas, slices.Max(gas), targetRate, thresholdWindow, order)
+	if err != nil {
+		return nil, err
+	}
+	return peaks[max(0, len(peaks)-peaksCount):], nil
+}
+
+// Peaks are defined by [detector], by default as follows:
+// - They start when trace reaches or goes above target value
+// - They finish when trace goes below the target value
+// so that a trace holding exactly at target value starts and continues a peak alike.
+// Note that target value are target rate * elapsed time among blocks.
+// Elapsed time is averaged over the last [thresholdWindow] blocks, so that a single
+// long inter-block gap does not inflate the threshold and mask a peak. A window of 1
+// uses the elapsed time since the parent block only. Target value is never below
+// target rate, as if at least one second elapsed, and never above [cap].
+// Peaks are sorted by increasing rank along [order], so that the top peak is the last one.
+func FindPeaks(ctx context.Context, detector PeakDetector, heightsAndTimes []BlkHeightTime, blkIDs []ids.ID, trace []uint64, cap, medianRate uint64, thresholdWindow int, order PeakOrder) ([]Peak, error) {
+	if len(heightsAndTimes) != len(trace) {
+		return nil, errUnevenTrace
+	}
+	if len(heightsAndTimes) != len(blkIDs) {
+		return nil, errUnevenIDs
+	}
+
+	thresholds := make([]uint64, len(trace))
+	for i := 1; i < len(trace); i++ {
+		var (
+			k       = min(i, max(1, thresholdWindow))
+			elapsed = TimeDelta(heightsAndTimes[i-k].Time, heightsAndTimes[i].Time)
+		)
+		thresholds[i] = min(cap, max(medianRate, medianRate*elapsed/uint64(k)))
+	}
+	inPeak, err := detector.Detect(ctx, heightsAndTimes, trace, thresholds)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res         = make([]Peak, 0)
+		peakStarted = false
+	)
+	for i := 1; i < len(trace); i++ {
+		v := trace[i]
+		switch {
+		case !peakStarted && !inPeak[i]:
+			continue // nothing to do
+		case !peakStarted && inPeak[i]:
+			peakStarted = true
+			res = append(
+				res,
+				Peak{
+					LowTimestamp:        heightsAndTimes[i].Time,
+					UpTimestamp:         heightsAndTimes[i].Time,
+					CumulatedComplexity: v,
+					StartHeight:         heightsAndTimes[i].Height,
+					BlocksCount:         1,
+					ElapsedTime:         0,
+					BlockIDs:            []ids.ID{blkIDs[i]},
+				},
+			)
+		case peakStarted && inPeak[i]: // peak continuing
+			interval := res[len(res)-1]
+			interval.UpTimestamp = heightsAndTimes[i].Time
+			interval.CumulatedComplexity += v
+			interval.BlocksCount += 1
+			interval.BlockIDs = append(interval.BlockIDs, blkIDs[i])
+			interval.ElapsedTime = TimeDelta(interval.LowTimestamp, heightsAndTimes[i].Time)
+			res[len(res)-1] = interval
+
+		case peakStarted && !inPeak[i]:
+			interval := res[len(res)-1]
+			interval.ElapsedTime = max(1, TimeDelta(interval.LowTimestamp, heightsAndTimes[i].Time))
+			res[len(res)-1] = interval
+			peakStarted = false
+		}
+	}
+	if peakStarted {
+		// the trace ends mid-peak, whose duration is floored as closed peaks' are
+		res[len(res)-1].ElapsedTime = max(1, res[len(res)-1].ElapsedTime)
+	}
+
+	slices.SortFunc(res, order.compare)
+
+	return res, nil
+}
+
+// MovingAverage returns the trailing average of [trace] over the last [window]
+// values, including the current one. Leading values are averaged over the
+// available ones. A window of 1 or less returns a copy of [trace].
+func MovingAverage(trace []uint64, window int) []uint64 {
+	res := make([]uint64, len(trace))
+	if window <= 1 {
+		copy(res, trace)
+		return res
+	}
+
+	sum := uint64(0)
+	for i, v := range trace {
+		sum += v
+		if i >= window {
+			sum -= trace[i-window]
+		}
+		res[i] = sum / uint64(min(i+1, window))
+	}
+	return res
+}
@@ -0,0 +1,318 @@
+package complexity
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// findFixturePeaks returns the peaks of dimension [d] of the fixture dataset, sorted along [order]
+func findFixturePeaks(tb testing.TB, d commonfee.Dimension, order PeakOrder) []Peak {
+	tb.Helper()
+
+	var (
+		records = loadFixture(tb)
+		derived = Derive(records)
+	)
+	_, rates, err := TargetComplexityRate(derived, MinBanffHeight, 0.5, 0.5)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	maxComplexity := MaxComplexity(records)
+	peaks, err := FindPeaks(context.Background(), ThresholdDetector{}, derived.HeightsAndTimes, derived.IDs, derived.Traces[d], maxComplexity[d], rates[d], 1, order)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return peaks
+}
+
+func TestFindPeaksGolden(t *testing.T) {
+	order, err := NewPeakOrder(SortByComplexity, SortDescending)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := make(map[string][]Peak)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		res[DimensionStrings[d]] = findFixturePeaks(t, d, order)
+	}
+	checkGolden(t, "peaks", res)
+}
+
+// traceBlocks returns heights and times of blocks one second apart, along with
+// empty IDs, to run FindPeaks on [trace]
+func traceBlocks(trace []uint64) ([]BlkHeightTime, []ids.ID) {
+	var (
+		heightsAndTimes = make([]BlkHeightTime, len(trace))
+		blkIDs          = make([]ids.ID, len(trace))
+	)
+	for i := range trace {
+		heightsAndTimes[i] = BlkHeightTime{Height: MinBanffHeight + uint64(i), Time: 1_700_000_000 + uint64(i)}
+	}
+	return heightsAndTimes, blkIDs
+}
+
+func TestFindPeaksPlateauAtTarget(t *testing.T) {
+	const targetRate = 100
+
+	var (
+		trace                   = []uint64{0, 50, 100, 100, 100, 100, 100, 50, 0}
+		heightsAndTimes, blkIDs = traceBlocks(trace)
+	)
+	order, err := NewPeakOrder(SortByComplexity, SortDescending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	peaks, err := FindPeaks(context.Background(), ThresholdDetector{}, heightsAndTimes, blkIDs, trace, 1_000, targetRate, 1, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(peaks) != 1 {
+		t.Fatalf("expected a single peak, got %d: %+v", len(peaks), peaks)
+	}
+	peak := peaks[0]
+	if peak.StartHeight != heightsAndTimes[2].Height || peak.BlocksCount != 5 {
+		t.Fatalf("expected peak of 5 blocks from height %d, got %d blocks from height %d", heightsAndTimes[2].Height, peak.BlocksCount, peak.StartHeight)
+	}
+	if peak.CumulatedComplexity != 5*targetRate {
+		t.Fatalf("expected cumulated complexity %d, got %d", 5*targetRate, peak.CumulatedComplexity)
+	}
+}
+
+func TestFindPeaksTraceEndingMidPeak(t *testing.T) {
+	const targetRate = 100
+
+	tests := []struct {
+		name             string
+		trace            []uint64
+		expectedBlocks   int
+		expectedDuration uint64
+	}{
+		{name: "single block", trace: []uint64{0, 50, 50, 400}, expectedBlocks: 1, expectedDuration: 1},
+		{name: "several blocks", trace: []uint64{0, 50, 400, 400, 400}, expectedBlocks: 3, expectedDuration: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			heightsAndTimes, blkIDs := traceBlocks(tt.trace)
+			order, err := NewPeakOrder(SortByPower, SortDescending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			peaks, err := FindPeaks(context.Background(), ThresholdDetector{}, heightsAndTimes, blkIDs, tt.trace, 1_000, targetRate, 1, order)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(peaks) != 1 {
+				t.Fatalf("expected a single peak, got %d: %+v", len(peaks), peaks)
+			}
+			peak := peaks[0]
+			if peak.BlocksCount != tt.expectedBlocks || peak.ElapsedTime != tt.expectedDuration {
+				t.Fatalf("expected peak of %d blocks lasting %ds, got %d blocks lasting %ds", tt.expectedBlocks, tt.expectedDuration, peak.BlocksCount, peak.ElapsedTime)
+			}
+			if power := peak.Power(); math.IsInf(power, 0) || math.IsNaN(power) {
+				t.Fatalf("expected finite power, got %f", power)
+			}
+		})
+	}
+}
+
+func TestPeakPowerZeroDuration(t *testing.T) {
+	p := Peak{CumulatedComplexity: 400, BlocksCount: 1}
+	if got := p.Power(); got != 400 {
+		t.Fatalf("expected power 400, got %f", got)
+	}
+}
+
+// sortedHeights sorts [peaks] along [order] and returns their start heights,
+// from the lowest ranked peak to the top one
+func sortedHeights(peaks []Peak, order PeakOrder) []uint64 {
+	peaks = slices.Clone(peaks)
+	slices.SortFunc(peaks, order.compare)
+
+	res := make([]uint64, 0, len(peaks))
+	for _, p := range peaks {
+		res = append(res, p.StartHeight)
+	}
+	return res
+}
+
+func TestPeakOrder(t *testing.T) {
+	// peak 1 is the largest, peak 2 the most intense, peak 3 the longest
+	peaks := []Peak{
+		{StartHeight: 1, CumulatedComplexity: 1_000, ElapsedTime: 100, BlocksCount: 50},
+		{StartHeight: 2, CumulatedComplexity: 600, ElapsedTime: 10, BlocksCount: 5},
+		{StartHeight: 3, CumulatedComplexity: 800, ElapsedTime: 200, BlocksCount: 30},
+	}
+
+	tests := []struct {
+		keys      string
+		direction string
+		expected  []uint64
+	}{
+		{keys: SortByComplexity, direction: SortDescending, expected: []uint64{2, 3, 1}},
+		{keys: SortByPower, direction: SortDescending, expected: []uint64{3, 1, 2}},
+		{keys: SortByDuration, direction: SortDescending, expected: []uint64{2, 1, 3}},
+		{keys: SortByBlocks, direction: SortDescending, expected: []uint64{2, 3, 1}},
+		{keys: SortByDuration, direction: SortAscending, expected: []uint64{3, 1, 2}},
+		{keys: SortByPower + "," + SortByComplexity, direction: SortAscending, expected: []uint64{2, 1, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.keys+"_"+tt.direction, func(t *testing.T) {
+			order, err := NewPeakOrder(tt.keys, tt.direction)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sortedHeights(peaks, order); !slices.Equal(got, tt.expected) {
+				t.Fatalf("expected peaks %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPeakOrderDefault(t *testing.T) {
+	order, err := NewPeakOrder(SortByComplexity, SortDescending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(order.Keys, []string{SortByComplexity, SortByPower}) || order.Ascending {
+		t.Fatalf("unexpected default order %+v", order)
+	}
+
+	// start heights of the fixture peaks, as ranked by cumulated complexity then power
+	// before sort keys could be chosen
+	expected := map[commonfee.Dimension][]uint64{
+		commonfee.Bandwidth: {2723864, 2723848, 2723858, 2723851},
+		commonfee.DBRead:    {2723846},
+		commonfee.DBWrite:   {2723864, 2723846, 2723856, 2723848, 2723866, 2723858, 2723850},
+		commonfee.Compute:   {2723864, 2723846, 2723867, 2723856, 2723858, 2723848},
+	}
+	for d, heights := range expected {
+		peaks := findFixturePeaks(t, d, order)
+		got := make([]uint64, 0, len(peaks))
+		for _, p := range peaks {
+			got = append(got, p.StartHeight)
+		}
+		if !slices.Equal(got, heights) {
+			t.Fatalf("%s: expected peaks %v, got %v", DimensionStrings[d], heights, got)
+		}
+	}
+}
+
+func TestPeakOrderTieBreaks(t *testing.T) {
+	tests := []struct {
+		key   string
+		peaks []Peak // tied along key, the second one winning the tie break
+	}{
+		{
+			key: SortByComplexity,
+			peaks: []Peak{
+				{StartHeight: 1, CumulatedComplexity: 100, ElapsedTime: 10},
+				{StartHeight: 2, CumulatedComplexity: 100, ElapsedTime: 5},
+			},
+		},
+		{
+			key: SortByPower,
+			peaks: []Peak{
+				{StartHeight: 1, CumulatedComplexity: 100, ElapsedTime: 10},
+				{StartHeight: 2, CumulatedComplexity: 200, ElapsedTime: 20},
+			},
+		},
+		{
+			key: SortByDuration,
+			peaks: []Peak{
+				{StartHeight: 1, CumulatedComplexity: 100, ElapsedTime: 10},
+				{StartHeight: 2, CumulatedComplexity: 200, ElapsedTime: 10},
+			},
+		},
+		{
+			key: SortByBlocks,
+			peaks: []Peak{
+				{StartHeight: 1, CumulatedComplexity: 100, ElapsedTime: 10, BlocksCount: 3},
+				{StartHeight: 2, CumulatedComplexity: 200, ElapsedTime: 20, BlocksCount: 3},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			order, err := NewPeakOrder(tt.key, SortDescending)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(order.Keys, []string{tt.key, tieBreaks[tt.key]}) {
+				t.Fatalf("expected keys %v, got %v", []string{tt.key, tieBreaks[tt.key]}, order.Keys)
+			}
+			// peaks are given in reverse order, so that sorting must swap them
+			reversed := []Peak{tt.peaks[1], tt.peaks[0]}
+			if got := sortedHeights(reversed, order); !slices.Equal(got, []uint64{1, 2}) {
+				t.Fatalf("expected peaks [1 2], got %v", got)
+			}
+		})
+	}
+}
+
+func TestNewPeakOrderErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      string
+		direction string
+		err       string
+	}{
+		{name: "repeated key", keys: "power,duration,power", direction: SortDescending, err: `sort key "power" repeated`},
+		{name: "unsupported key", keys: "height", direction: SortDescending, err: `unsupported sort key "height"`},
+		{name: "unsupported direction", keys: SortByPower, direction: "up", err: `unsupported sort order "up"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewPeakOrder(tt.keys, tt.direction)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// BenchmarkFindAllDimensionPeaks compares the concurrent detection of peaks of all
+// dimensions with detecting them one dimension after the other
+func BenchmarkFindAllDimensionPeaks(b *testing.B) {
+	var (
+		records       = benchmarkRecords(b, benchmarkBlocks)
+		derived       = Derive(records)
+		maxComplexity = MaxComplexity(records)
+	)
+	_, rates, err := TargetComplexityRate(derived, MinBanffHeight, 0.5, 0.5)
+	if err != nil {
+		b.Fatal(err)
+	}
+	order, err := NewPeakOrder(SortByComplexity, SortDescending)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("concurrent", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := FindAllDimensionPeaks(context.Background(), ThresholdDetector{}, derived, maxComplexity, rates, 10, 1, 1, order); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for d := range derived.Traces {
+				trace := MovingAverage(derived.Traces[d], 1)
+				if _, err := FindPeaks(context.Background(), ThresholdDetector{}, derived.HeightsAndTimes, derived.IDs, trace, maxComplexity[d], rates[d], 1, order); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
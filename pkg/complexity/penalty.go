@@ -0,0 +1,46 @@
+package complexity
+
+import commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+
+// PenaltyPeriod is a run of consecutive blocks, both ends included,
+// paying a gas price above the minimum one
+type PenaltyPeriod struct {
+	From         BlkHeightTime `json:"from"`
+	To           BlkHeightTime `json:"to"`
+	MaxExcessGas commonfee.Gas `json:"max_excess_gas"`
+}
+
+// Duration returns the seconds elapsed from the first to the last block of [p]
+func (p PenaltyPeriod) Duration() uint64 {
+	return TimeDelta(p.From.Time, p.To.Time)
+}
+
+// PenaltyPeriods returns the runs of [fees] whose gas price is above [minGasPrice],
+// i.e. the periods the exponential controller spends recovering from gas peaks,
+// in the order they occur. A run still open at the end of [fees] is included.
+func PenaltyPeriods(fees []FeeData, minGasPrice commonfee.GasPrice) []PenaltyPeriod {
+	var (
+		res       = make([]PenaltyPeriod, 0)
+		inPenalty = false
+	)
+	for _, f := range fees {
+		if f.GasPrice <= minGasPrice {
+			inPenalty = false
+			continue
+		}
+
+		if inPenalty {
+			last := &res[len(res)-1]
+			last.To = f.BlkHeightTime
+			last.MaxExcessGas = max(last.MaxExcessGas, f.ExcessGas)
+			continue
+		}
+		inPenalty = true
+		res = append(res, PenaltyPeriod{
+			From:         f.BlkHeightTime,
+			To:           f.BlkHeightTime,
+			MaxExcessGas: f.ExcessGas,
+		})
+	}
+	return res
+}
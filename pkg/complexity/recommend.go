@@ -0,0 +1,45 @@
+package complexity
+
+import (
+	"math"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// RecommendWeights returns fee dimension weights such that each dimension, consumed
+// at its [targetRate], accrues about the same gas. The dimension with the highest
+// target rate weighs 1. Dimensions with no target rate keep their [fallback] weight.
+func RecommendWeights(targetRate, fallback commonfee.Dimensions) commonfee.Dimensions {
+	maxRate := uint64(0)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		maxRate = max(maxRate, targetRate[d])
+	}
+
+	res := fallback
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		if targetRate[d] == 0 {
+			continue
+		}
+		res[d] = max(1, uint64(math.Round(float64(maxRate)/float64(targetRate[d]))))
+	}
+	return res
+}
+
+// WeightedRate returns the gas rate of [targetRate] complexities weighted by [weights]
+func WeightedRate(targetRate, weights commonfee.Dimensions) uint64 {
+	rate := uint64(0)
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		rate += targetRate[d] * weights[d]
+	}
+	return rate
+}
+
+// PeakExcessGas returns the gas cumulated over [p] beyond [targetRate] per second,
+// i.e. the excess gas the fee mechanism accrues over the peak
+func PeakExcessGas(p Peak, targetRate uint64) uint64 {
+	budget := targetRate * p.ElapsedTime
+	if p.CumulatedComplexity <= budget {
+		return 0
+	}
+	return p.CumulatedComplexity - budget
+}
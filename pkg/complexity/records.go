@@ -0,0 +1,185 @@
+// Package complexity holds the analysis of historical block complexities:
+// target complexity rates, complexity peaks and dynamic fees.
+package complexity
+
+import (
+	"slices"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+const (
+	// not exactly the height of the first banff block, but close enough
+	MinBanffHeight = 2_723_845
+
+	// long running loops check for context cancellation every ctxCheckInterval iterations
+	ctxCheckInterval = 1024
+)
+
+// DimensionStrings names the dimensions of the fee package, indexed by dimension
+var DimensionStrings = [commonfee.NumDimensions]string{
+	commonfee.Bandwidth: "Bandwidth",
+	commonfee.DBRead:    "DBRead",
+	commonfee.DBWrite:   "DBWrite",
+	commonfee.Compute:   "Compute",
+}
+
+// BlkHeightTime locates a block in the chain and in time.
+// Time is a Unix timestamp in seconds.
+type BlkHeightTime struct {
+	Height uint64 `json:"height"`
+	Time   uint64 `json:"time"`
+}
+
+// Record holds a block complexity, as read from input data
+type Record struct {
+	ID ids.ID
+	BlkHeightTime
+	Complexity commonfee.Dimensions
+
+	// ObservedFee is the fee actually paid by the block, in nAvax.
+	// It is meaningful only if HasObservedFee is set.
+	ObservedFee    uint64
+	HasObservedFee bool
+
+	// TxType tags the kind of transactions the block carries, e.g. AddValidatorTx,
+	// if the input maps it. It is empty otherwise.
+	TxType string
+}
+
+// PullTimesHeightsFromRecords returns heights and times of [records]
+func PullTimesHeightsFromRecords(records []Record) []BlkHeightTime {
+	res := make([]BlkHeightTime, 0, len(records))
+	for _, r := range records {
+		res = append(res, r.BlkHeightTime)
+	}
+	return res
+}
+
+// PullIDsFromRecords returns the block IDs of [records]
+func PullIDsFromRecords(records []Record) []ids.ID {
+	res := make([]ids.ID, 0, len(records))
+	for _, r := range records {
+		res = append(res, r.ID)
+	}
+	return res
+}
+
+// PullComplexityFromRecords returns the complexity trace of dimension [d]
+func PullComplexityFromRecords(records []Record, d commonfee.Dimension) []uint64 {
+	res := make([]uint64, 0, len(records))
+	for _, r := range records {
+		res = append(res, r.Complexity[d])
+	}
+	return res
+}
+
+// WeightedGas returns the gas consumed by [r],
+// i.e. the sum of its complexities weighted by [weights]
+func WeightedGas(r Record, weights commonfee.Dimensions) uint64 {
+	gas := uint64(0)
+	for d := 0; d < commonfee.NumDimensions; d++ {
+		gas += r.Complexity[d] * weights[d]
+	}
+	return gas
+}
+
+// PullGasFromRecords returns the weighted gas trace, see WeightedGas
+func PullGasFromRecords(records []Record, weights commonfee.Dimensions) []uint64 {
+	res := make([]uint64, 0, len(records))
+	for _, r := range records {
+		res = append(res, WeightedGas(r, weights))
+	}
+	return res
+}
+
+// ToGas converts complexities of [records] into the gas each dimension accounts for,
+// i.e. complexities scaled by [weights], so that the gas of a record, as the fee
+// algorithm aggregates dimensions, is the plain sum of its converted dimensions
+func ToGas(records []Record, weights commonfee.Dimensions) []Record {
+	res := make([]Record, len(records))
+	for i, r := range records {
+		for d := range r.Complexity {
+			r.Complexity[d] *= weights[d]
+		}
+		res[i] = r
+	}
+	return res
+}
+
+// SkipEmptyRecords drops records with no complexity in any dimension
+func SkipEmptyRecords(records []Record) []Record {
+	res := make([]Record, 0, len(records))
+	for _, r := range records {
+		if r.Complexity != (commonfee.Dimensions{}) {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// FilterRecordsByHeight returns records with height within [minHeight, maxHeight]
+func FilterRecordsByHeight(records []Record, minHeight, maxHeight uint64) []Record {
+	res := make([]Record, 0)
+	for _, r := range records {
+		if r.Height >= minHeight && r.Height <= maxHeight {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// FilterRecordsByTime returns records whose time is within [minTime, maxTime],
+// both ends included
+func FilterRecordsByTime(records []Record, minTime, maxTime uint64) []Record {
+	res := make([]Record, 0)
+	for _, r := range records {
+		if r.Time >= minTime && r.Time <= maxTime {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+// MergeRecords concatenates [sets], stable sorts them by height and drops
+// records whose ID or height was already seen. It returns the merged records
+// and the number of dropped duplicates.
+func MergeRecords(sets ...[]Record) ([]Record, int) {
+	size := 0
+	for _, set := range sets {
+		size += len(set)
+	}
+	all := make([]Record, 0, size)
+	for _, set := range sets {
+		all = append(all, set...)
+	}
+	slices.SortStableFunc(all, func(lhs, rhs Record) int {
+		switch {
+		case lhs.Height < rhs.Height:
+			return -1
+		case lhs.Height > rhs.Height:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	var (
+		res     = make([]Record, 0, len(all))
+		seenIDs = make(map[ids.ID]struct{}, len(all))
+	)
+	for _, r := range all {
+		if _, seen := seenIDs[r.ID]; seen {
+			continue
+		}
+		if len(res) > 0 && res[len(res)-1].Height == r.Height {
+			continue
+		}
+		seenIDs[r.ID] = struct{}{}
+		res = append(res, r)
+	}
+	return res, len(all) - len(res)
+}
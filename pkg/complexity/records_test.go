@@ -0,0 +1,50 @@
+package complexity
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFilterRecordsByTime(t *testing.T) {
+	records := []Record{
+		{BlkHeightTime: BlkHeightTime{Height: 1, Time: 100}},
+		{BlkHeightTime: BlkHeightTime{Height: 2, Time: 102}},
+		{BlkHeightTime: BlkHeightTime{Height: 3, Time: 102}},
+		{BlkHeightTime: BlkHeightTime{Height: 4, Time: 105}},
+		{BlkHeightTime: BlkHeightTime{Height: 5, Time: 110}},
+	}
+
+	tests := []struct {
+		name     string
+		minTime  uint64
+		maxTime  uint64
+		expected []uint64 // heights of the records kept
+	}{
+		{name: "whole range", minTime: 100, maxTime: 110, expected: []uint64{1, 2, 3, 4, 5}},
+		{name: "bounds included", minTime: 102, maxTime: 105, expected: []uint64{2, 3, 4}},
+		{name: "single timestamp", minTime: 102, maxTime: 102, expected: []uint64{2, 3}},
+		{name: "between blocks", minTime: 106, maxTime: 109, expected: []uint64{}},
+		{name: "before first block", minTime: 0, maxTime: 99, expected: []uint64{}},
+		{name: "after last block", minTime: 111, maxTime: 200, expected: []uint64{}},
+		{name: "inverted range", minTime: 105, maxTime: 102, expected: []uint64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := FilterRecordsByTime(records, tt.minTime, tt.maxTime)
+			heights := make([]uint64, 0, len(res))
+			for _, r := range res {
+				heights = append(heights, r.Height)
+			}
+			if !slices.Equal(heights, tt.expected) {
+				t.Fatalf("expected heights %v, got %v", tt.expected, heights)
+			}
+		})
+	}
+}
+
+func TestFilterRecordsByTimeEmptyInput(t *testing.T) {
+	res := FilterRecordsByTime(nil, 0, 1<<64-1)
+	if len(res) != 0 {
+		t.Fatalf("expected no records, got %v", res)
+	}
+}
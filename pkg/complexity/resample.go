@@ -0,0 +1,36 @@
+package complexity
+
+// Resample aggregates [records] into fixed wall-clock buckets of [width] seconds,
+// aligned on Unix time, so that rates are computed over steady time steps rather
+// than among blocks, many of which may share a timestamp.
+// Each bucket is a record carrying the summed complexities of its blocks, timed
+// at the bucket start and identified by its first block ID and height, so that
+// heights keep increasing and explorer links point to where buckets start.
+// Observed fees are summed only if all blocks of a bucket carry them.
+// Buckets with no blocks are left out. Assumes [records] are sorted by time and [width] is positive.
+func Resample(records []Record, width uint64) []Record {
+	res := make([]Record, 0)
+	for _, r := range records {
+		start := r.Time / width * width
+		if len(res) == 0 || res[len(res)-1].Time != start {
+			res = append(res, Record{
+				ID:             r.ID,
+				BlkHeightTime:  BlkHeightTime{Height: r.Height, Time: start},
+				HasObservedFee: true,
+			})
+		}
+
+		b := &res[len(res)-1]
+		for d := range b.Complexity {
+			b.Complexity[d] += r.Complexity[d]
+		}
+		b.ObservedFee += r.ObservedFee
+		b.HasObservedFee = b.HasObservedFee && r.HasObservedFee
+	}
+	for i := range res {
+		if !res[i].HasObservedFee {
+			res[i].ObservedFee = 0
+		}
+	}
+	return res
+}
@@ -0,0 +1,82 @@
+package complexity
+
+import (
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// Revenue periods fees are summed over, see [RevenueByPeriod]
+const (
+	RevenueDay  = "day"
+	RevenueWeek = "week"
+)
+
+var RevenuePeriods = []string{RevenueDay, RevenueWeek}
+
+const (
+	secondsPerDay = 24 * 60 * 60
+
+	// weeks start on Monday, the first one of Unix time being January 5th 1970
+	weekOffset = 4 * secondsPerDay
+)
+
+// Revenue sums the fees paid by blocks
+type Revenue struct {
+	// Start is the Unix time the period starts at, it is zero for whole datasets
+	Start  uint64  `json:"start"`
+	Blocks int     `json:"blocks"`
+	Fees   float64 `json:"fees"`
+
+	// BaseFees is the share of Fees blocks would have paid at the min gas price,
+	// the rest is paid because of congestion
+	BaseFees float64 `json:"base_fees"`
+}
+
+// CongestionFees returns the fees paid above the min gas price
+func (r Revenue) CongestionFees() float64 {
+	return r.Fees - r.BaseFees
+}
+
+// TotalRevenue sums [fees], computed by replaying [records] with [feeCfg],
+// in the same fee unit [feeUnit] they are expressed in.
+// Assumes [fees] and [records] are indexed alike.
+func TotalRevenue(records []Record, fees []FeeData, feeCfg commonfee.Config, feeUnit uint64) Revenue {
+	res := Revenue{}
+	for i, f := range fees {
+		res.add(records[i], f, feeCfg, feeUnit)
+	}
+	return res
+}
+
+// RevenueByPeriod works as TotalRevenue, summing fees by UTC day or week,
+// as [period] tells. Weeks start on Monday. Periods with no blocks are left out.
+// Assumes [period] is one of [RevenuePeriods] and [fees] are sorted by time.
+func RevenueByPeriod(records []Record, fees []FeeData, feeCfg commonfee.Config, feeUnit uint64, period string) []Revenue {
+	res := make([]Revenue, 0)
+	for i, f := range fees {
+		start := periodStart(f.Time, period)
+		if len(res) == 0 || res[len(res)-1].Start != start {
+			res = append(res, Revenue{Start: start})
+		}
+		res[len(res)-1].add(records[i], f, feeCfg, feeUnit)
+	}
+	return res
+}
+
+func (r *Revenue) add(record Record, f FeeData, feeCfg commonfee.Config, feeUnit uint64) {
+	gas := WeightedGas(record, feeCfg.Weights)
+	r.Blocks++
+	r.Fees += f.Fee
+	r.BaseFees += float64(gas) * float64(feeCfg.MinGasPrice) / float64(feeUnit)
+}
+
+// periodStart returns the Unix time the day or week including [t] starts at
+func periodStart(t uint64, period string) uint64 {
+	if period == RevenueWeek {
+		const week = 7 * secondsPerDay
+		if t < weekOffset {
+			return 0
+		}
+		return (t-weekOffset)/week*week + weekOffset
+	}
+	return t / secondsPerDay * secondsPerDay
+}
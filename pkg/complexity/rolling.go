@@ -0,0 +1,73 @@
+package complexity
+
+import (
+	"errors"
+	"slices"
+)
+
+var errZeroWindow = errors.New("rolling window and step must be positive")
+
+// RollingRate summarizes complexity rates over the window ending at a block
+type RollingRate struct {
+	BlkHeightTime
+
+	// Mean is the complexity accumulated over the window divided by its
+	// duration, i.e. the sustained complexity rate
+	Mean float64
+
+	// Quantile is the chosen quantile of per block complexity rates within
+	// the window, i.e. how spiky load was while sustained at Mean
+	Quantile float64
+}
+
+// RollingRates evaluates complexity rates of [trace] over windows of [window] seconds,
+// once every [step] seconds. Each evaluation covers the blocks whose time is within
+// (t - window, t], where t is the time of the block evaluation happens at.
+// Per block rates are computed as in TargetComplexityRate.
+// Assumes [heightsAndTimes] and [trace] are sorted by height and have the same length.
+func RollingRates(heightsAndTimes []BlkHeightTime, trace []uint64, window, step uint64, quantile float64) ([]RollingRate, error) {
+	if len(heightsAndTimes) != len(trace) {
+		return nil, errUnevenTrace
+	}
+	if window == 0 || step == 0 {
+		return nil, errZeroWindow
+	}
+
+	var (
+		res     = make([]RollingRate, 0)
+		rates   = make([]float64, len(trace))
+		cumSums = make([]uint64, len(trace)+1)
+	)
+	for i, c := range trace {
+		dt := uint64(1)
+		if i > 0 {
+			dt = max(1, TimeDelta(heightsAndTimes[i-1].Time, heightsAndTimes[i].Time))
+		}
+		rates[i] = float64(c) / float64(dt)
+		cumSums[i+1] = cumSums[i] + c
+	}
+
+	var (
+		start    = 0 // first block within the window
+		nextEval = uint64(0)
+		sorted   = make([]float64, 0)
+	)
+	for i, ht := range heightsAndTimes {
+		for start < i && TimeDelta(heightsAndTimes[start].Time, ht.Time) >= window {
+			start++
+		}
+		if i > 0 && ht.Time < nextEval {
+			continue
+		}
+		nextEval = ht.Time + step
+
+		sorted = append(sorted[:0], rates[start:i+1]...)
+		slices.Sort(sorted)
+		res = append(res, RollingRate{
+			BlkHeightTime: ht,
+			Mean:          float64(cumSums[i+1]-cumSums[start]) / float64(window),
+			Quantile:      sorted[quantileIndex(len(sorted), quantile)],
+		})
+	}
+	return res, nil
+}
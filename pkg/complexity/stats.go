@@ -0,0 +1,61 @@
+package complexity
+
+import (
+	"slices"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// DimensionStats summarizes per block complexity of a single dimension
+type DimensionStats struct {
+	Count  int
+	Mean   float64
+	Median uint64
+	P95    uint64
+	P99    uint64
+	Max    uint64
+}
+
+// DatasetStats summarizes a whole dataset, with stats indexed by dimension
+type DatasetStats struct {
+	Dimensions       [commonfee.NumDimensions]DimensionStats
+	MedianBlockDelay uint64
+}
+
+// Summarize computes per dimension statistics of [records], along with the
+// median inter-block delay. Quantiles are picked as in TargetComplexityRate.
+func Summarize(records []Record) DatasetStats {
+	res := DatasetStats{}
+	if len(records) == 0 {
+		return res
+	}
+
+	for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+		trace := PullComplexityFromRecords(records, d)
+		slices.Sort(trace)
+
+		sum := float64(0)
+		for _, v := range trace {
+			sum += float64(v)
+		}
+
+		res.Dimensions[d] = DimensionStats{
+			Count:  len(trace),
+			Mean:   sum / float64(len(trace)),
+			Median: trace[quantileIndex(len(trace), 0.5)],
+			P95:    trace[quantileIndex(len(trace), 0.95)],
+			P99:    trace[quantileIndex(len(trace), 0.99)],
+			Max:    trace[len(trace)-1],
+		}
+	}
+
+	if len(records) > 1 {
+		timeSteps := make([]uint64, 0, len(records)-1)
+		for i := 1; i < len(records); i++ {
+			timeSteps = append(timeSteps, TimeDelta(records[i-1].Time, records[i].Time))
+		}
+		slices.Sort(timeSteps)
+		res.MedianBlockDelay = timeSteps[quantileIndex(len(timeSteps), 0.5)]
+	}
+	return res
+}
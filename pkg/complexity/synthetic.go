@@ -0,0 +1,167 @@
+package complexity
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/rand/v2"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var errNoSamples = errors.New("no non-empty records to sample complexities from")
+
+// SyntheticConfig drives the generation of synthetic block traces
+type SyntheticConfig struct {
+	Blocks int
+
+	// MeanBlockDelay is the mean time, in seconds, among Poisson block arrivals
+	MeanBlockDelay float64
+
+	// A burst starts at each block outside bursts with BurstProbability
+	// and lasts BurstBlocks blocks, whose complexities are scaled by BurstFactor
+	BurstProbability float64
+	BurstBlocks      int
+	BurstFactor      float64
+
+	// Scale multiplies sampled complexities of each dimension
+	Scale [commonfee.NumDimensions]float64
+
+	Seed uint64
+}
+
+// ComplexitySampler draws block complexities from the empirical
+// distribution of historical blocks
+type ComplexitySampler struct {
+	samples []commonfee.Dimensions
+}
+
+// FitComplexities returns a sampler of the complexities of non-empty [records].
+// Whole blocks are sampled, so that correlations among dimensions are preserved.
+func FitComplexities(records []Record) (ComplexitySampler, error) {
+	samples := make([]commonfee.Dimensions, 0, len(records))
+	for _, r := range records {
+		if r.Complexity != (commonfee.Dimensions{}) {
+			samples = append(samples, r.Complexity)
+		}
+	}
+	if len(samples) == 0 {
+		return ComplexitySampler{}, errNoSamples
+	}
+	return ComplexitySampler{samples: samples}, nil
+}
+
+// GenerateRecords returns [cfg].Blocks synthetic records, starting at [start].
+// Blocks arrive as a Poisson process, so that several of them may share a timestamp,
+// and their complexities are drawn from [sampler], scaled by [cfg].Scale and,
+// within bursts, by [cfg].BurstFactor. Same [cfg] yields the same records.
+func GenerateRecords(sampler ComplexitySampler, cfg SyntheticConfig, start BlkHeightTime) []Record {
+	var (
+		rng         = rand.New(rand.NewPCG(cfg.Seed, cfg.Seed))
+		res         = make([]Record, 0, cfg.Blocks)
+		elapsed     = float64(0)
+		burstBlocks = 0
+	)
+	for i := 0; i < cfg.Blocks; i++ {
+		if i > 0 {
+			elapsed += rng.ExpFloat64() * cfg.MeanBlockDelay
+		}
+
+		factor := float64(1)
+		if burstBlocks == 0 && rng.Float64() < cfg.BurstProbability {
+			burstBlocks = cfg.BurstBlocks
+		}
+		if burstBlocks > 0 {
+			factor = cfg.BurstFactor
+			burstBlocks--
+		}
+
+		sample := sampler.samples[rng.IntN(len(sampler.samples))]
+		var complexity commonfee.Dimensions
+		for d := range complexity {
+			complexity[d] = uint64(math.Round(float64(sample[d]) * cfg.Scale[d] * factor))
+		}
+
+		var id ids.ID
+		for j := range id {
+			id[j] = byte(rng.Uint32())
+		}
+		res = append(res, Record{
+			ID: id,
+			BlkHeightTime: BlkHeightTime{
+				Height: start.Height + uint64(i),
+				Time:   start.Time + uint64(elapsed),
+			},
+			Complexity: complexity,
+		})
+	}
+	return res
+}
+
+// Waveforms of idealized complexity inputs, see WaveformConfig
+const (
+	WaveformStep    = "step"
+	WaveformImpulse = "impulse"
+	WaveformSquare  = "square"
+)
+
+var Waveforms = []string{WaveformStep, WaveformImpulse, WaveformSquare}
+
+// WaveformConfig drives the generation of idealized block traces, whose blocks are
+// evenly spaced by BlockDelay seconds and carry Baseline complexities, plus Amplitude
+// while excited. Excitation starts at block Onset and lasts for good with step waveforms,
+// a single block with impulse ones, and the first half of each Period blocks with square ones.
+type WaveformConfig struct {
+	Waveform   string
+	Blocks     int
+	BlockDelay float64
+	Onset      int
+	Period     int
+	Baseline   commonfee.Dimensions
+	Amplitude  commonfee.Dimensions
+}
+
+// Excited tells whether the [i]-th block of the trace carries Amplitude.
+// Assumes [cfg].Waveform is one of Waveforms.
+func (cfg WaveformConfig) Excited(i int) bool {
+	if i < cfg.Onset {
+		return false
+	}
+	switch cfg.Waveform {
+	case WaveformImpulse:
+		return i == cfg.Onset
+	case WaveformSquare:
+		return (i-cfg.Onset)%cfg.Period < (cfg.Period+1)/2
+	default:
+		return true
+	}
+}
+
+// GenerateWaveform returns the [cfg].Blocks records of an idealized trace, starting at [start].
+// Block IDs are derived from heights, so that same [cfg] yields the same records.
+func GenerateWaveform(cfg WaveformConfig, start BlkHeightTime) []Record {
+	res := make([]Record, 0, cfg.Blocks)
+	for i := 0; i < cfg.Blocks; i++ {
+		complexity := cfg.Baseline
+		if cfg.Excited(i) {
+			for d := range complexity {
+				complexity[d] += cfg.Amplitude[d]
+			}
+		}
+
+		height := start.Height + uint64(i)
+		var id ids.ID
+		binary.BigEndian.PutUint64(id[len(id)-8:], height)
+		res = append(res, Record{
+			ID: id,
+			BlkHeightTime: BlkHeightTime{
+				Height: height,
+				Time:   start.Time + uint64(float64(i)*cfg.BlockDelay),
+			},
+			Complexity: complexity,
+		})
+	}
+	return res
+}
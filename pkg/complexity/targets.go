@@ -0,0 +1,360 @@
+package complexity
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+var (
+	errNotEnoughRecords = errors.New("at least two non-empty records are needed")
+	errUnevenTarget     = errors.New("records and target have different length")
+)
+
+// TargetComplexityRate calculates target time among blocks and complexity rate at chosen quantiles.
+// Block delay and complexity rates use separate quantiles since they answer different questions:
+// [blockDelayQuantile] picks the inter-block delay we size capacity on (e.g. median or p75 delay),
+// while [quantile] picks how much of the historical complexity rate the target should accommodate.
+func TargetComplexityRate(derived Derived, minHeight uint64, quantile, blockDelayQuantile float64) (uint64, commonfee.Dimensions, error) {
+	// We return:
+	// - target time among blocks
+	// - target complexity rates
+	var (
+		targetBlockDelay   = uint64(0)
+		targetComplexities = commonfee.Dimensions{}
+	)
+	timeSteps, rates, err := sortedRates(derived, minHeight)
+	if err != nil {
+		return 0, commonfee.Dimensions{}, err
+	}
+
+	q := quantileIndex(len(timeSteps), blockDelayQuantile)
+	targetBlockDelay = timeSteps[q]
+
+	for d := range rates {
+		q = quantileIndex(len(rates[d]), quantile)
+		targetComplexities[d] = uint64(rates[d][q])
+	}
+
+	return targetBlockDelay, targetComplexities, nil
+}
+
+// QuantileTargets holds the block delay and the complexity rates
+// found at the same quantile of historical data
+type QuantileTargets struct {
+	Quantile   float64
+	BlockDelay uint64
+	Rates      commonfee.Dimensions
+}
+
+// TargetsByQuantile returns, for each of [quantiles], block delay and complexity rates
+// as TargetComplexityRate would, so that the sensitivity of targets to the chosen quantile
+// can be assessed. Results follow the order of [quantiles].
+func TargetsByQuantile(derived Derived, minHeight uint64, quantiles []float64) ([]QuantileTargets, error) {
+	timeSteps, rates, err := sortedRates(derived, minHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]QuantileTargets, 0, len(quantiles))
+	for _, quantile := range quantiles {
+		t := QuantileTargets{
+			Quantile:   quantile,
+			BlockDelay: timeSteps[quantileIndex(len(timeSteps), quantile)],
+		}
+		for d := range rates {
+			t.Rates[d] = uint64(rates[d][quantileIndex(len(rates[d]), quantile)])
+		}
+		res = append(res, t)
+	}
+	return res, nil
+}
+
+// ComplexityDistribution holds, sorted increasingly, the per block complexities and
+// complexity rates of a dimension, along with their values at the target quantile
+type ComplexityDistribution struct {
+	Complexities []uint64
+	Rates        []float64
+
+	ComplexityCutoff uint64
+	RateCutoff       uint64 // matches the target complexity rate at the same quantile
+}
+
+// ComplexityDistributions returns the distribution of each dimension over the blocks
+// TargetComplexityRate accounts for, cut at [quantile]
+func ComplexityDistributions(derived Derived, minHeight uint64, quantile float64) ([commonfee.NumDimensions]ComplexityDistribution, error) {
+	var res [commonfee.NumDimensions]ComplexityDistribution
+	_, rates, err := sortedRates(derived, minHeight)
+	if err != nil {
+		return res, err
+	}
+
+	for d := range res {
+		complexities := make([]uint64, 0, len(derived.HeightsAndTimes))
+		for i, ht := range derived.HeightsAndTimes {
+			if ht.Height < minHeight || derived.isEmpty(i) {
+				continue
+			}
+			complexities = append(complexities, derived.Traces[d][i])
+		}
+		slices.Sort(complexities)
+
+		res[d] = ComplexityDistribution{
+			Complexities:     complexities,
+			Rates:            rates[d],
+			ComplexityCutoff: complexities[quantileIndex(len(complexities), quantile)],
+			RateCutoff:       uint64(rates[d][quantileIndex(len(rates[d]), quantile)]),
+		}
+	}
+	return res, nil
+}
+
+// sortedRates returns, sorted increasingly, the time elapsed among blocks and
+// the complexity rates of each dimension.
+func sortedRates(derived Derived, minHeight uint64) ([]uint64, [commonfee.NumDimensions][]float64, error) {
+	timeSteps, rates, err := blockRates(derived, minHeight)
+	if err != nil {
+		return nil, rates, err
+	}
+
+	sort.Slice(timeSteps, func(i, j int) bool { return timeSteps[i] < timeSteps[j] })
+	for d := range rates {
+		sort.Float64s(rates[d])
+	}
+	return timeSteps, rates, nil
+}
+
+// blockRates returns, in height order, the time elapsed among blocks and
+// the complexity rates of each dimension, so that rates of a block are
+// found at the same index across dimensions.
+func blockRates(derived Derived, minHeight uint64) ([]uint64, [commonfee.NumDimensions][]float64, error) {
+	// We drop empty blocks, with no complexity, since they would skew down
+	// target complexity.
+	// We can skip pre-Banff blocks, whose timestamp is not in the block really
+	var (
+		timeSteps = make([]uint64, 0, len(derived.HeightsAndTimes))
+		rates     [commonfee.NumDimensions][]float64
+		prev      = -1
+	)
+	for d := range rates {
+		rates[d] = make([]float64, 0, len(derived.HeightsAndTimes))
+	}
+
+	// rates are computed among consecutive processed blocks, as if
+	// skipped ones were not there
+	for i, ht := range derived.HeightsAndTimes {
+		if ht.Height < minHeight || derived.isEmpty(i) {
+			continue
+		}
+		if prev >= 0 {
+			dX := max(1, TimeDelta(derived.HeightsAndTimes[prev].Time, ht.Time))
+			timeSteps = append(timeSteps, dX)
+			for d := range rates {
+				rates[d] = append(rates[d], float64(derived.Traces[d][i])/float64(dX))
+			}
+		}
+		prev = i
+	}
+	if len(timeSteps) == 0 {
+		return nil, rates, errNotEnoughRecords
+	}
+	return timeSteps, rates, nil
+}
+
+// quantileIndex returns the index of quantile [q] in a sorted slice of length [n],
+// clamped to a valid index so that q == 1 does not overflow the slice.
+// Assumes n > 0.
+func quantileIndex(n int, q float64) int {
+	return min(max(0, int(float64(n)*q)), n-1)
+}
+
+// MaxComplexity returns the max complexity of [records] per dimension,
+// or empty dimensions if there are no records
+func MaxComplexity(records []Record) commonfee.Dimensions {
+	res := commonfee.Dimensions{}
+	if len(records) == 0 {
+		return res
+	}
+	for i := 0; i < commonfee.NumDimensions; i++ {
+		max := slices.MaxFunc(records, func(lhs, rhs Record) int {
+			switch {
+			case lhs.Complexity[i] < rhs.Complexity[i]:
+				return -1
+			case lhs.Complexity[i] == rhs.Complexity[i]:
+				return 0
+			default:
+				return 1
+			}
+		})
+		res[i] = max.Complexity[i]
+	}
+	return res
+}
+
+// TopBlock is a block with one of the highest complexities of a dimension
+type TopBlock struct {
+	ID ids.ID `json:"id"`
+	BlkHeightTime
+	Complexity uint64 `json:"complexity"`
+}
+
+// TopComplexityBlocks returns, for each dimension, the [n] blocks of [records]
+// with the highest complexity, highest first. Ties are broken by height, lowest first.
+func TopComplexityBlocks(records []Record, n int) [commonfee.NumDimensions][]TopBlock {
+	var (
+		res     [commonfee.NumDimensions][]TopBlock
+		indexes = make([]int, len(records))
+		count   = min(n, len(records))
+	)
+	for d := range res {
+		for i := range indexes {
+			indexes[i] = i
+		}
+		slices.SortFunc(indexes, func(lhs, rhs int) int {
+			if c := cmp.Compare(records[rhs].Complexity[d], records[lhs].Complexity[d]); c != 0 {
+				return c
+			}
+			return cmp.Compare(records[lhs].Height, records[rhs].Height)
+		})
+
+		res[d] = make([]TopBlock, 0, count)
+		for _, i := range indexes[:count] {
+			r := records[i]
+			res[d] = append(res[d], TopBlock{
+				ID:            r.ID,
+				BlkHeightTime: r.BlkHeightTime,
+				Complexity:    r.Complexity[d],
+			})
+		}
+	}
+	return res
+}
+
+// Derivatives returns the time elapsed since the parent block, floored at one second,
+// and the complexity rates of each dimension of each block but the first one,
+// which has no parent. Rates are indexed by dimension.
+func Derivatives(records []Record) ([]uint64, [commonfee.NumDimensions][]float64) {
+	var rates [commonfee.NumDimensions][]float64
+	if len(records) == 0 {
+		return nil, rates
+	}
+	timeSteps := make([]uint64, 0, len(records)-1)
+	for d := range rates {
+		rates[d] = make([]float64, 0, len(records)-1)
+	}
+
+	for i := 1; i < len(records); i++ {
+		dX := TimeDelta(records[i-1].Time, records[i].Time)
+		if dX == 0 {
+			dX = 1
+		}
+		timeSteps = append(timeSteps, dX)
+		for d := range rates {
+			rates[d] = append(rates[d], float64(records[i].Complexity[d])/float64(dX))
+		}
+	}
+
+	return timeSteps, rates
+}
+
+const (
+	// SameTimeFloor grants each block at least one second worth of target,
+	// even when it shares its timestamp with its parent.
+	SameTimeFloor = "floor"
+	// SameTimeSpread grants a run of blocks sharing a timestamp the target
+	// of the time elapsed since the run's parent (at least one second),
+	// split evenly among the blocks of the run.
+	SameTimeSpread = "spread"
+)
+
+var SameTimeModes = []string{SameTimeFloor, SameTimeSpread}
+
+// TargetComplexityTrace returns, for each block, the target complexity given
+// the time elapsed since its parent, capped at [cap].
+// [mode] tells how blocks sharing a timestamp are handled, see [SameTimeModes].
+// With [SameTimeFloor] a burst of same-second blocks gets a flat target per block,
+// so the burst is granted way more than [rate] per second overall; with [SameTimeSpread]
+// the whole burst is granted [rate] per elapsed second.
+// First block has no parent, so it gets the same target of the second one.
+// Assumes [mode] is one of [SameTimeModes]
+func TargetComplexityTrace(records []Record, cap, rate uint64, mode string) []uint64 {
+	target := make([]uint64, len(records))
+	for i := 1; i < len(records); {
+		budget := rate * max(1, TimeDelta(records[i-1].Time, records[i].Time))
+		if mode != SameTimeSpread {
+			target[i] = min(cap, budget)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(records) && records[end].Time == records[i].Time {
+			end++
+		}
+		share := budget / uint64(end-i)
+		for j := i; j < end; j++ {
+			target[j] = min(cap, share)
+		}
+		i = end
+	}
+	if len(target) > 1 {
+		target[0] = target[1]
+	}
+	return target
+}
+
+// Exceedance counts blocks whose complexity exceeded their target capacity
+type Exceedance struct {
+	Count    int
+	Fraction float64
+}
+
+// CapacityExceedances returns, for each dimension, how many blocks consumed more than
+// their target capacity min(maxComplexities[d], targetRate[d] * elapsed time since parent),
+// with elapsed time floored at one second as in TargetComplexityTrace.
+// Unlike peaks, blocks are counted regardless of whether they are contiguous.
+// First block has no parent, hence no capacity, so it is not counted, nor included in fractions.
+func CapacityExceedances(derived Derived, maxComplexities, targetRate commonfee.Dimensions) [commonfee.NumDimensions]Exceedance {
+	var (
+		res    [commonfee.NumDimensions]Exceedance
+		blocks = len(derived.HeightsAndTimes) - 1
+	)
+	if blocks <= 0 {
+		return res
+	}
+
+	for i := 1; i < len(derived.HeightsAndTimes); i++ {
+		dt := max(1, TimeDelta(derived.HeightsAndTimes[i-1].Time, derived.HeightsAndTimes[i].Time))
+		for d := range res {
+			if derived.Traces[d][i] > min(maxComplexities[d], targetRate[d]*dt) {
+				res[d].Count++
+			}
+		}
+	}
+	for d := range res {
+		res[d].Fraction = float64(res[d].Count) / float64(blocks)
+	}
+	return res
+}
+
+// Utilization returns, for each block, the consumed complexity of dimension [d]
+// as a percentage of [target]. Blocks with zero target have zero utilization.
+func Utilization(records []Record, target []uint64, d commonfee.Dimension) ([]float64, error) {
+	if len(records) != len(target) {
+		return nil, errUnevenTarget
+	}
+
+	res := make([]float64, len(records))
+	for i, r := range records {
+		if target[i] == 0 {
+			continue
+		}
+		res[i] = 100 * float64(r.Complexity[d]) / float64(target[i])
+	}
+	return res, nil
+}
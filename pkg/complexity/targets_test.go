@@ -0,0 +1,112 @@
+package complexity
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTargetComplexityRateGolden(t *testing.T) {
+	records := loadFixture(t)
+
+	blockDelay, rates, err := TargetComplexityRate(Derive(records), MinBanffHeight, 0.5, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "target_complexity_rate", struct {
+		BlockDelay uint64   `json:"block_delay"`
+		Rates      []uint64 `json:"rates"`
+	}{
+		BlockDelay: blockDelay,
+		Rates:      rates[:],
+	})
+}
+
+func TestTargetComplexityRateNotEnoughRecords(t *testing.T) {
+	records := loadFixture(t)[:1]
+
+	if _, _, err := TargetComplexityRate(Derive(records), MinBanffHeight, 0.5, 0.5); err != errNotEnoughRecords {
+		t.Fatalf("expected %v, got %v", errNotEnoughRecords, err)
+	}
+}
+
+func TestMaxComplexityGolden(t *testing.T) {
+	records := loadFixture(t)
+
+	maxComplexity := MaxComplexity(records)
+	checkGolden(t, "max_complexity", maxComplexity[:])
+}
+
+func TestDerivativesGolden(t *testing.T) {
+	records := loadFixture(t)
+
+	timeSteps, rates := Derivatives(records)
+	if len(timeSteps) != len(records)-1 {
+		t.Fatalf("expected %d time steps, got %d", len(records)-1, len(timeSteps))
+	}
+	checkGolden(t, "derivatives", struct {
+		TimeSteps []uint64    `json:"time_steps"`
+		Rates     [][]float64 `json:"rates"`
+	}{
+		TimeSteps: timeSteps,
+		Rates:     rates[:],
+	})
+}
+
+func TestTargetComplexityTraceSameTime(t *testing.T) {
+	// blocks at heights 2 to 4 share a timestamp, two seconds after their parent
+	records := []Record{
+		{BlkHeightTime: BlkHeightTime{Height: 1, Time: 100}},
+		{BlkHeightTime: BlkHeightTime{Height: 2, Time: 102}},
+		{BlkHeightTime: BlkHeightTime{Height: 3, Time: 102}},
+		{BlkHeightTime: BlkHeightTime{Height: 4, Time: 102}},
+		{BlkHeightTime: BlkHeightTime{Height: 5, Time: 105}},
+		{BlkHeightTime: BlkHeightTime{Height: 6, Time: 106}},
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		cap      uint64
+		expected []uint64
+	}{
+		{
+			name:     "floor grants a second to each block",
+			mode:     SameTimeFloor,
+			cap:      1_000,
+			expected: []uint64{60, 60, 30, 30, 90, 30},
+		},
+		{
+			name:     "spread splits elapsed time among the run",
+			mode:     SameTimeSpread,
+			cap:      1_000,
+			expected: []uint64{20, 20, 20, 20, 90, 30},
+		},
+		{
+			name:     "spread shares are capped",
+			mode:     SameTimeSpread,
+			cap:      25,
+			expected: []uint64{20, 20, 20, 20, 25, 25},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TargetComplexityTrace(records, tt.cap, 30, tt.mode); !slices.Equal(got, tt.expected) {
+				t.Fatalf("expected target %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTargetComplexityTraceSpreadRunOnly(t *testing.T) {
+	// all blocks share a timestamp, so that those following the first one split a single second of target
+	records := make([]Record, 5)
+	for i := range records {
+		records[i].Height = uint64(i)
+		records[i].Time = 100
+	}
+
+	target := TargetComplexityTrace(records, 1_000, 40, SameTimeSpread)
+	if !slices.Equal(target, []uint64{10, 10, 10, 10, 10}) {
+		t.Fatalf("expected target of 10 per block, got %v", target)
+	}
+}
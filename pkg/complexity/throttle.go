@@ -0,0 +1,58 @@
+package complexity
+
+import commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+
+// ThrottledRange is a run of consecutive throttled blocks, both ends included
+type ThrottledRange struct {
+	From      uint64
+	To        uint64
+	ExcessGas uint64
+}
+
+// Throttling summarizes which blocks a gas cap would have rejected
+type Throttling struct {
+	Blocks    int
+	ExcessGas uint64
+	Ranges    []ThrottledRange
+}
+
+// SimulateThrottling flags blocks whose weighted gas exceeds what [feeCfg] allows
+// over the time elapsed since their parent, i.e. MaxGasPerSecond * elapsed time,
+// with elapsed time floored at one second. First block has no parent and is never flagged.
+// Excess gas is the gas consumed above the cap, summed over throttled blocks.
+func SimulateThrottling(records []Record, feeCfg commonfee.Config) Throttling {
+	var (
+		res           = Throttling{}
+		prevThrottled = false
+	)
+	for i := 1; i < len(records); i++ {
+		var (
+			r       = records[i]
+			elapsed = max(1, TimeDelta(records[i-1].Time, r.Time))
+			gasCap  = uint64(feeCfg.MaxGasPerSecond) * elapsed
+			gas     = WeightedGas(r, feeCfg.Weights)
+		)
+		if gas <= gasCap {
+			prevThrottled = false
+			continue
+		}
+
+		excess := gas - gasCap
+		res.Blocks++
+		res.ExcessGas += excess
+
+		if prevThrottled {
+			last := &res.Ranges[len(res.Ranges)-1]
+			last.To = r.Height
+			last.ExcessGas += excess
+			continue
+		}
+		prevThrottled = true
+		res.Ranges = append(res.Ranges, ThrottledRange{
+			From:      r.Height,
+			To:        r.Height,
+			ExcessGas: excess,
+		})
+	}
+	return res
+}
@@ -0,0 +1,67 @@
+package complexity
+
+import (
+	"cmp"
+	"slices"
+
+	commonfee "github.com/ava-labs/avalanchego/vms/components/fee"
+)
+
+// UntaggedTxType labels blocks with no tx type
+const UntaggedTxType = "untagged"
+
+// TxTypeShare sums the complexity of the blocks carrying a tx type
+type TxTypeShare struct {
+	TxType     string               `json:"tx_type"`
+	Blocks     int                  `json:"blocks"`
+	Complexity commonfee.Dimensions `json:"complexity"`
+	Gas        uint64               `json:"gas"`
+}
+
+// TxTypeBreakdown sums complexities of [records], and their gas weighted by [weights],
+// by tx type. Blocks with no tx type are summed as [UntaggedTxType].
+// Shares are sorted by decreasing gas, ties broken by tx type.
+func TxTypeBreakdown(records []Record, weights commonfee.Dimensions) []TxTypeShare {
+	byType := make(map[string]*TxTypeShare)
+	for _, r := range records {
+		txType := r.TxType
+		if txType == "" {
+			txType = UntaggedTxType
+		}
+		share, ok := byType[txType]
+		if !ok {
+			share = &TxTypeShare{TxType: txType}
+			byType[txType] = share
+		}
+		share.Blocks++
+		for d := commonfee.Dimension(0); d < commonfee.NumDimensions; d++ {
+			share.Complexity[d] += r.Complexity[d]
+		}
+		share.Gas += WeightedGas(r, weights)
+	}
+
+	res := make([]TxTypeShare, 0, len(byType))
+	for _, share := range byType {
+		res = append(res, *share)
+	}
+	slices.SortFunc(res, func(lhs, rhs TxTypeShare) int {
+		if c := cmp.Compare(rhs.Gas, lhs.Gas); c != 0 {
+			return c
+		}
+		return cmp.Compare(lhs.TxType, rhs.TxType)
+	})
+	return res
+}
+
+// PeakTxTypes breaks down by tx type the blocks of [records] making up [peak],
+// see TxTypeBreakdown
+func PeakTxTypes(records []Record, peak Peak, weights commonfee.Dimensions) []TxTypeShare {
+	start, _ := slices.BinarySearchFunc(records, peak.StartHeight, func(r Record, h uint64) int {
+		return cmp.Compare(r.Height, h)
+	})
+	end := start
+	for end < len(records) && peak.ContainsHeight(records[end].Height) {
+		end++
+	}
+	return TxTypeBreakdown(records[start:end], weights)
+}
@@ -0,0 +1,73 @@
+package complexity
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxReportedIssues caps the number of offending records listed in errors
+const maxReportedIssues = 10
+
+// HeightGap represents a range of missing heights, both ends included
+type HeightGap struct {
+	From uint64
+	To   uint64
+}
+
+// ValidateOrdering checks that [records] have strictly increasing heights and
+// non-decreasing times, as assumed by the analysis. It returns an error listing
+// the indexes of the offending records, if any.
+func ValidateOrdering(records []Record) error {
+	var (
+		issues []string
+		count  int
+	)
+	for i := 1; i < len(records); i++ {
+		prev, curr := records[i-1], records[i]
+		var issue string
+		switch {
+		case curr.Height == prev.Height:
+			issue = fmt.Sprintf("record %d: duplicate height %d", i, curr.Height)
+		case curr.Height < prev.Height:
+			issue = fmt.Sprintf("record %d: height %d lower than previous %d", i, curr.Height, prev.Height)
+		case curr.Time < prev.Time:
+			issue = fmt.Sprintf("record %d: time %d lower than previous %d", i, curr.Time, prev.Time)
+		default:
+			continue
+		}
+
+		count++
+		if len(issues) < maxReportedIssues {
+			issues = append(issues, issue)
+		}
+	}
+
+	if count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d records out of order: %s", count, strings.Join(issues, "; "))
+}
+
+// FindHeightGaps returns the ranges of heights missing from [records].
+// Assumes [records] are sorted by height.
+func FindHeightGaps(records []Record) []HeightGap {
+	res := make([]HeightGap, 0)
+	for i := 1; i < len(records); i++ {
+		if records[i].Height > records[i-1].Height+1 {
+			res = append(res, HeightGap{
+				From: records[i-1].Height + 1,
+				To:   records[i].Height - 1,
+			})
+		}
+	}
+	return res
+}
+
+// TimeDelta returns the time elapsed from [prev] to [next], or zero
+// if [next] comes before [prev], to avoid uint64 underflows.
+func TimeDelta(prev, next uint64) uint64 {
+	if next < prev {
+		return 0
+	}
+	return next - prev
+}
@@ -0,0 +1,81 @@
+package complexity
+
+import (
+	"errors"
+	"math"
+)
+
+var errUnevenFees = errors.New("records and fees have different length")
+
+// FeeDiff compares the fee computed for a block with the one it actually paid
+type FeeDiff struct {
+	BlkHeightTime
+	Computed float64
+	Observed float64
+	AbsDiff  float64
+	RelDiff  float64 // relative to Observed
+}
+
+// FeeVerification holds per block differences among computed
+// and observed fees, along with their root mean square and
+// the revenue each of them would collect over the verified blocks
+type FeeVerification struct {
+	Diffs         []FeeDiff
+	RMSE          float64
+	ComputedTotal float64
+	ObservedTotal float64
+}
+
+// RevenueDelta returns how much more revenue computed fees
+// collect compared to observed ones. It is negative if they collect less.
+func (v FeeVerification) RevenueDelta() float64 {
+	return v.ComputedTotal - v.ObservedTotal
+}
+
+// VerifyFees compares [fees], computed over [records] and expressed in [feeUnit],
+// with the fees observed on chain. Records without an observed fee are skipped,
+// so that Diffs is empty if none of them carries one.
+func VerifyFees(records []Record, fees []FeeData, feeUnit uint64) (FeeVerification, error) {
+	if len(records) != len(fees) {
+		return FeeVerification{}, errUnevenFees
+	}
+
+	var (
+		res         = FeeVerification{Diffs: make([]FeeDiff, 0)}
+		sumSquaredE = float64(0)
+	)
+	for i, r := range records {
+		if !r.HasObservedFee {
+			continue
+		}
+
+		var (
+			computed = fees[i].Fee
+			observed = float64(r.ObservedFee) / float64(feeUnit)
+			diff     = computed - observed
+			relDiff  = float64(0)
+		)
+		switch {
+		case observed != 0:
+			relDiff = diff / observed
+		case computed != 0:
+			relDiff = math.Inf(1)
+		}
+
+		res.Diffs = append(res.Diffs, FeeDiff{
+			BlkHeightTime: r.BlkHeightTime,
+			Computed:      computed,
+			Observed:      observed,
+			AbsDiff:       math.Abs(diff),
+			RelDiff:       relDiff,
+		})
+		sumSquaredE += diff * diff
+		res.ComputedTotal += computed
+		res.ObservedTotal += observed
+	}
+
+	if len(res.Diffs) > 0 {
+		res.RMSE = math.Sqrt(sumSquaredE / float64(len(res.Diffs)))
+	}
+	return res, nil
+}